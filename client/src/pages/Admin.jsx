@@ -4,6 +4,7 @@ import { useNavigate } from 'react-router-dom';
 function Admin() {
     const [bookings, setBookings] = useState([]);
     const [blockedSpots, setBlockedSpots] = useState([]);
+    const [spots, setSpots] = useState([]);
     const [loading, setLoading] = useState(false);
     const [message, setMessage] = useState('');
     const navigate = useNavigate();
@@ -16,10 +17,27 @@ function Admin() {
             return;
         }
         fetchBookings();
+        fetchSpots();
         fetchBlockedSpots();
         fetchUsers();
     }, [navigate]);
 
+    const fetchSpots = async () => {
+        try {
+            const response = await fetch('http://localhost:8080/api/admin/spots', {
+                headers: {
+                    'Authorization': `Bearer ${localStorage.getItem('authToken')}`
+                }
+            });
+            if (response.ok) {
+                const data = await response.json();
+                setSpots((data.spots || []).filter((spot) => !spot.retiredAt));
+            }
+        } catch (error) {
+            console.error('Error fetching spots:', error);
+        }
+    };
+
     const fetchBookings = async () => {
         try {
             const response = await fetch('http://localhost:8080/api/admin/bookings', {
@@ -248,8 +266,8 @@ function Admin() {
             <div>
                 <h2 className="text-2xl font-montserrat font-medium mb-4">Управление парковочными местами</h2>
                 <div className="grid grid-cols-4 gap-4">
-                    {[...Array(16)].map((_, index) => {
-                        const spotNumber = index + 1;
+                    {spots.map((spot) => {
+                        const spotNumber = spot.number;
                         const isBlocked = (blockedSpots || []).includes(spotNumber);
                         return (
                             <button
@@ -262,7 +280,7 @@ function Admin() {
                                         : 'bg-[#9E7758] hover:bg-[#6E5A42]'
                                 } ${loading ? 'opacity-50 cursor-not-allowed' : ''}`}
                             >
-                                Место {spotNumber}
+                                Место {spot.label}
                                 <br />
                                 {isBlocked ? 'Заблокировано' : 'Активно'}
                             </button>
@@ -5,10 +5,12 @@ import { useNavigate } from 'react-router-dom';
 function Booking() {
     const [carNumber, setCarNumber] = useState("");
     const [hours, setHours] = useState(1);
-    const [totalPrice, setTotalPrice] = useState(100);
+    const [totalPrice, setTotalPrice] = useState(null);
     const [parkingSpot, setParkingSpot] = useState("");
+    const [promoCode, setPromoCode] = useState("");
     const [message, setMessage] = useState("");
     const [occupiedSpots, setOccupiedSpots] = useState([]);
+    const [spots, setSpots] = useState([]);
     const [loading, setLoading] = useState(false);
     const navigate = useNavigate();
 
@@ -18,9 +20,48 @@ function Booking() {
             navigate('/login');
             return;
         }
+        fetchSpots();
         fetchOccupiedSpots();
     }, [navigate]);
 
+    // Стоимость считает сервер по тарифу выбранного места с учетом промокода
+    useEffect(() => {
+        if (!parkingSpot || !hours) {
+            setTotalPrice(null);
+            return;
+        }
+        const fetchQuote = async () => {
+            try {
+                const promo = promoCode.trim() ? `&promoCode=${encodeURIComponent(promoCode.trim())}` : '';
+                const response = await fetch(`http://localhost:8080/api/quote?parkingSpot=${parkingSpot}&hours=${hours}${promo}`, {
+                    headers: {
+                        'Authorization': `Bearer ${localStorage.getItem('authToken')}`
+                    }
+                });
+                setTotalPrice(response.ok ? (await response.json()).quote.amount / 100 : null);
+            } catch (error) {
+                console.error('Error fetching quote:', error);
+            }
+        };
+        fetchQuote();
+    }, [parkingSpot, hours, promoCode]);
+
+    const fetchSpots = async () => {
+        try {
+            const response = await fetch('http://localhost:8080/api/spots', {
+                headers: {
+                    'Authorization': `Bearer ${localStorage.getItem('authToken')}`
+                }
+            });
+            if (response.ok) {
+                const data = await response.json();
+                setSpots(data.spots || []);
+            }
+        } catch (error) {
+            console.error('Error fetching spots:', error);
+        }
+    };
+
     const fetchOccupiedSpots = async () => {
         try {
             const response = await fetch('http://localhost:8080/api/bookings', {
@@ -58,12 +99,34 @@ function Booking() {
                 body: JSON.stringify({
                     parkingSpot: parseInt(parkingSpot),
                     carNumber,
-                    hours: parseInt(hours)
+                    hours: parseInt(hours),
+                    promoCode: promoCode.trim() || undefined
                 })
             });
 
             const data = await response.json();
 
+            if (response.ok && data.payment && data.payment.confirmationUrl) {
+                const confirmationUrl = new URL(data.payment.confirmationUrl, 'http://localhost:8080').toString();
+                if (!data.payment.confirmationUrl.startsWith('/api/payments/fake/')) {
+                    // Платная бронь станет активной после оплаты на странице провайдера
+                    window.location.href = confirmationUrl;
+                    return;
+                }
+                // Локальный провайдер для разработки: оплата подтверждается запросом от имени пользователя
+                const paid = window.confirm(`Оплатить ${data.payment.amount / 100} ${data.payment.currency}?`);
+                const paymentResponse = await fetch(confirmationUrl, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json',
+                        'Authorization': `Bearer ${localStorage.getItem('authToken')}`
+                    },
+                    body: JSON.stringify({ status: paid ? 'succeeded' : 'failed' })
+                });
+                setMessage(paymentResponse.ok && paid ? "Бронирование оплачено" : "Оплата не прошла, бронь отменена");
+                await fetchOccupiedSpots();
+                return;
+            }
             if (response.ok) {
                 const endTime = new Date(data.endTime);
                 setMessage(`
@@ -75,8 +138,8 @@ function Booking() {
                 await fetchOccupiedSpots();
                 setCarNumber("");
                 setParkingSpot("");
+                setPromoCode("");
                 setHours(1);
-                setTotalPrice(100);
             } else {
                 setMessage(data.message || "Ошибка при бронировании");
             }
@@ -140,7 +203,6 @@ function Booking() {
                         onChange={(e) => {
                             const value = parseInt(e.target.value);
                             setHours(value);
-                            setTotalPrice(value * 100);
                         }}
                     />
                     <label
@@ -151,6 +213,24 @@ function Booking() {
                     </label>
                 </div>
 
+                {/* Промокод */}
+                <div className="relative mb-6">
+                    <input
+                        type="text"
+                        value={promoCode}
+                        id="promoCode"
+                        placeholder=" "
+                        className="peer w-full px-4 py-3 bg-[#3e3f3a] text-white rounded-lg border-2 border-[#9E7758] focus:outline-none focus:ring-2 focus:ring-[#9E7758] focus:border-[#9E7758]"
+                        onChange={(e) => setPromoCode(e.target.value)}
+                    />
+                    <label
+                        htmlFor="promoCode"
+                        className="absolute left-4 top-1/2 transform -translate-y-1/2 text-sm text-gray-400 peer-placeholder-shown:text-base peer-placeholder-shown:text-gray-500 peer-focus:text-sm peer-focus:text-[#9E7758] peer-focus:transform peer-focus:-translate-y-5 transition-all duration-200"
+                    >
+                        Промокод
+                    </label>
+                </div>
+
                 {/* Выбор парковочного места */}
                 <div className="relative mb-6">
                     <select
@@ -159,8 +239,8 @@ function Booking() {
                         className="w-full px-4 py-3 bg-[#3e3f3a] text-white rounded-lg border-2 border-[#9E7758] focus:outline-none focus:ring-2 focus:ring-[#9E7758] focus:border-[#9E7758]"
                     >
                         <option value="">Выберите парковочное место</option>
-                        {[...Array(16)].map((_, index) => {
-                            const spotNumber = index + 1;
+                        {spots.map((spot) => {
+                            const spotNumber = spot.number;
                             const isOccupied = occupiedSpots.includes(spotNumber);
                             return (
                                 <option
@@ -168,7 +248,7 @@ function Booking() {
                                     value={spotNumber}
                                     disabled={isOccupied}
                                 >
-                                    Место №{spotNumber} {isOccupied ? '(занято)' : ''}
+                                    Место №{spot.label} {isOccupied ? '(занято)' : ''}
                                 </option>
                             );
                         })}
@@ -178,7 +258,7 @@ function Booking() {
                 {/* Итоговая стоимость */}
                 <div className="mb-6">
                     <p className="text-lg font-montserrat font-medium text-white">
-                        Итоговая стоимость: <span className="font-semibold">{totalPrice} р.</span>
+                        Итоговая стоимость: <span className="font-semibold">{totalPrice === null ? '—' : `${totalPrice} р.`}</span>
                     </p>
                 </div>
 
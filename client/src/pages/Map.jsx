@@ -1,7 +1,155 @@
-import React from 'react';
+import React, { useState, useEffect } from 'react';
 import { Link } from "react-router-dom";
 
+const statusColors = {
+    free: '#4CAF50',
+    occupied: '#E53935',
+    blocked: '#757575',
+    retired: '#BDBDBD',
+};
+
+const SCHEME_WIDTH = 600;
+const SCHEME_HEIGHT = 400;
+const SCHEME_PADDING = 20;
+
+// Собирает все позиции геометрии, чтобы вычислить границы схемы
+function positions(geometry) {
+    switch (geometry.type) {
+        case 'Point':
+            return [geometry.coordinates];
+        case 'LineString':
+            return geometry.coordinates;
+        case 'MultiLineString':
+        case 'Polygon':
+            return geometry.coordinates.flat();
+        default:
+            return [];
+    }
+}
+
+// Возвращает функцию, переводящую координаты схемы в координаты SVG (ось y направлена вверх)
+function makeProjection(features) {
+    const all = features.flatMap((feature) => positions(feature.geometry));
+    if (all.length === 0) {
+        return () => [0, 0];
+    }
+    const xs = all.map((p) => p[0]);
+    const ys = all.map((p) => p[1]);
+    const minX = Math.min(...xs), maxX = Math.max(...xs);
+    const minY = Math.min(...ys), maxY = Math.max(...ys);
+    const scale = Math.min(
+        (SCHEME_WIDTH - 2 * SCHEME_PADDING) / (maxX - minX || 1),
+        (SCHEME_HEIGHT - 2 * SCHEME_PADDING) / (maxY - minY || 1),
+    );
+    return ([x, y]) => [
+        SCHEME_PADDING + (x - minX) * scale,
+        SCHEME_HEIGHT - SCHEME_PADDING - (y - minY) * scale,
+    ];
+}
+
+function LotScheme({ layout }) {
+    const project = makeProjection(layout.features);
+    const points = (line) => line.map((p) => project(p).join(',')).join(' ');
+
+    return (
+        <svg viewBox={`0 0 ${SCHEME_WIDTH} ${SCHEME_HEIGHT}`} className="w-full border rounded-lg bg-white">
+            {layout.features.map((feature, i) => {
+                const { kind, status, label } = feature.properties || {};
+                const geometry = feature.geometry;
+
+                if (kind === 'lane') {
+                    const lines = geometry.type === 'LineString' ? [geometry.coordinates] : geometry.coordinates;
+                    return lines.map((line, j) => (
+                        <polyline key={`${i}-${j}`} points={points(line)} fill="none" stroke="#9E7758" strokeWidth="4" strokeDasharray="8 6" />
+                    ));
+                }
+                if (kind === 'entrance') {
+                    const [x, y] = project(geometry.coordinates);
+                    return (
+                        <g key={i}>
+                            <circle cx={x} cy={y} r="8" fill="#11120e" />
+                            <text x={x + 12} y={y + 4} className="font-montserrat" fontSize="12">Въезд</text>
+                        </g>
+                    );
+                }
+
+                const color = statusColors[status] || statusColors.free;
+                if (geometry.type === 'Point') {
+                    const [x, y] = project(geometry.coordinates);
+                    return (
+                        <g key={i}>
+                            <circle cx={x} cy={y} r="12" fill={color} />
+                            <text x={x} y={y + 4} textAnchor="middle" fontSize="10" fill="white">{label}</text>
+                        </g>
+                    );
+                }
+                const ring = geometry.coordinates[0];
+                const center = project([
+                    ring.reduce((sum, p) => sum + p[0], 0) / ring.length,
+                    ring.reduce((sum, p) => sum + p[1], 0) / ring.length,
+                ]);
+                return (
+                    <g key={i}>
+                        <polygon points={points(ring)} fill={color} stroke="white" strokeWidth="2" />
+                        <text x={center[0]} y={center[1] + 4} textAnchor="middle" fontSize="10" fill="white">{label}</text>
+                    </g>
+                );
+            })}
+        </svg>
+    );
+}
+
 function Map() {
+    const [lots, setLots] = useState([]);
+    const [lotId, setLotId] = useState(null);
+    const [layout, setLayout] = useState(null);
+
+    useEffect(() => {
+        const fetchLots = async () => {
+            try {
+                const response = await fetch('http://localhost:8080/api/lots', {
+                    headers: {
+                        'Authorization': `Bearer ${localStorage.getItem('authToken')}`
+                    }
+                });
+                if (response.ok) {
+                    const data = await response.json();
+                    setLots(data.lots || []);
+                    if (data.lots && data.lots.length > 0) {
+                        setLotId(data.lots[0].id);
+                    }
+                }
+            } catch (error) {
+                console.error('Error fetching lots:', error);
+            }
+        };
+        if (localStorage.getItem('authToken')) {
+            fetchLots();
+        }
+    }, []);
+
+    useEffect(() => {
+        if (!lotId) {
+            return;
+        }
+        const fetchMap = async () => {
+            try {
+                const response = await fetch(`http://localhost:8080/api/lots/${lotId}/map`, {
+                    headers: {
+                        'Authorization': `Bearer ${localStorage.getItem('authToken')}`
+                    }
+                });
+                setLayout(response.ok ? (await response.json()).layout : null);
+            } catch (error) {
+                console.error('Error fetching lot map:', error);
+            }
+        };
+        fetchMap();
+        // Состояние мест меняется со временем, поэтому схема периодически обновляется
+        const timer = setInterval(fetchMap, 30000);
+        return () => clearInterval(timer);
+    }, [lotId]);
+
     return (
         <div className="min-h-screen flex flex-col pt-16"> {/* Добавили pt-16 для отступа от шапки */}
             <h1 className="font-montserrat font-medium mb-2">
@@ -26,6 +174,28 @@ function Map() {
                 ></iframe>
             </div>
 
+            {lots.length > 0 && (
+                <div className="mt-6">
+                    <h2 className="font-montserrat font-medium text-xl mb-2">Схема парковки</h2>
+                    {lots.length > 1 && (
+                        <select
+                            value={lotId || ''}
+                            onChange={(e) => setLotId(parseInt(e.target.value))}
+                            className="font-montserrat mb-4 p-2 border rounded-lg"
+                        >
+                            {lots.map((lot) => (
+                                <option key={lot.id} value={lot.id}>{lot.name}</option>
+                            ))}
+                        </select>
+                    )}
+                    {layout ? (
+                        <LotScheme layout={layout} />
+                    ) : (
+                        <p className="font-montserrat">Схема этой парковки пока не загружена.</p>
+                    )}
+                </div>
+            )}
+
             <div className="flex justify-center mt-6">
                 <Link to="/booking">
                     <button className="font-montserrat font-medium py-3 px-6 bg-[#9E7758] text-white rounded-lg hover:bg-[#11120e] transition-transform duration-300 hover:scale-105 focus:outline-none">
@@ -38,4 +208,4 @@ function Map() {
     );
 }
 
-export default Map;
\ No newline at end of file
+export default Map;
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BookingConfig содержит настройки политики бронирования
+type BookingConfig struct {
+	// Horizon — насколько далеко вперед можно бронировать место
+	Horizon time.Duration
+	// CancelCutoff — владелец не может отменить бронь позже чем за это время до ее начала
+	CancelCutoff time.Duration
+	// RefundFullBefore — при отмене не позже чем за это время до начала брони возвращается вся стоимость
+	RefundFullBefore time.Duration
+	// RefundLatePercent — процент стоимости, возвращаемый при более поздней отмене до начала брони
+	RefundLatePercent int
+	// MaxDuration — максимальная длительность одной брони, включая продления
+	MaxDuration time.Duration
+	// MinDuration — минимальная длительность брони
+	MinDuration time.Duration
+	// SlotGranularity — шаг сетки, по которой выравниваются начало и окончание брони
+	SlotGranularity time.Duration
+	// SlotRounding — режим выравнивания: expand, nearest или strict
+	SlotRounding string
+	// MaxConcurrent — сколько броней одного пользователя может пересекаться по времени (0 — без ограничения)
+	MaxConcurrent int
+	// MaxPerCar — сколько пересекающихся броней может быть на один номер машины (0 — без ограничения)
+	MaxPerCar int
+	// MaxWeekly — суммарная длительность броней пользователя за неделю (0 — без ограничения)
+	MaxWeekly time.Duration
+	// MinGap — минимальный перерыв между бронями одного пользователя
+	MinGap time.Duration
+	// CheckInEarly — насколько раньше начала брони можно зарегистрироваться
+	CheckInEarly time.Duration
+	// NoShowGrace — сколько ждать регистрации после начала брони, прежде чем отметить неявку
+	NoShowGrace time.Duration
+	// NoShowInterval — как часто фоновый процесс ищет неявки
+	NoShowInterval time.Duration
+	// SeriesMaxLength — максимальная продолжительность повторяющейся брони
+	SeriesMaxLength time.Duration
+	// WaitlistOfferTTL — сколько удерживается место, предложенное из листа ожидания
+	WaitlistOfferTTL time.Duration
+	// WaitlistInterval — как часто фоновый процесс обрабатывает лист ожидания
+	WaitlistInterval time.Duration
+	// AssignmentStrategy — стратегия подбора места, когда клиент его не указал
+	AssignmentStrategy string
+	// Currency — валюта цен по ISO 4217; суммы хранятся в минимальных единицах (копейках)
+	Currency string
+	// Location — часовой пояс парковки, в котором задаются дни и время повторяющихся броней
+	Location *time.Location
+}
+
+// Booking — текущие настройки бронирования, заполняются в Load
+var Booking = BookingConfig{
+	Horizon:            14 * 24 * time.Hour,
+	CancelCutoff:       15 * time.Minute,
+	RefundFullBefore:   time.Hour,
+	RefundLatePercent:  50,
+	MaxDuration:        24 * time.Hour,
+	MinDuration:        15 * time.Minute,
+	SlotGranularity:    15 * time.Minute,
+	SlotRounding:       "expand",
+	MaxConcurrent:      1,
+	MaxPerCar:          1,
+	CheckInEarly:       15 * time.Minute,
+	NoShowGrace:        15 * time.Minute,
+	NoShowInterval:     time.Minute,
+	SeriesMaxLength:    180 * 24 * time.Hour,
+	WaitlistOfferTTL:   15 * time.Minute,
+	WaitlistInterval:   time.Minute,
+	AssignmentStrategy: "fill_from_entrance",
+	Currency:           "RUB",
+	Location:           time.UTC,
+}
+
+// PaymentsConfig содержит настройки оплаты броней
+type PaymentsConfig struct {
+	// Provider — платежный провайдер; fake — локальная реализация для разработки и тестов.
+	// Пустое значение отключает оплату через провайдера
+	Provider string
+	// CallbackSecret — секрет, которым провайдер подписывает уведомления о платежах; без него сервер не запускается
+	CallbackSecret string
+	// FakeCheckout — открыть страницу оплаты локального провайдера; только для разработки и тестов
+	FakeCheckout bool
+	// Timeout — сколько платная бронь ждет подтверждения оплаты, прежде чем отмениться
+	Timeout time.Duration
+	// CheckInterval — как часто фоновый процесс отменяет неоплаченные брони
+	CheckInterval time.Duration
+	// AllowanceInterval — как часто фоновый процесс зачисляет ежемесячные суммы групп
+	AllowanceInterval time.Duration
+	// RefundInterval — как часто фоновый процесс повторяет возвраты, которые провайдер не принял
+	RefundInterval time.Duration
+}
+
+// Payments — текущие настройки оплаты, заполняются в Load
+var Payments = PaymentsConfig{
+	Timeout:           15 * time.Minute,
+	CheckInterval:     time.Minute,
+	AllowanceInterval: time.Hour,
+	RefundInterval:    time.Minute,
+}
+
+// Load читает настройки из переменных окружения, оставляя значения по умолчанию для отсутствующих
+func Load() {
+	Booking.Horizon = envDuration("BOOKING_HORIZON_DAYS", Booking.Horizon, 24*time.Hour)
+	Booking.CancelCutoff = envDuration("CANCELLATION_CUTOFF_MINUTES", Booking.CancelCutoff, time.Minute)
+	Booking.RefundFullBefore = envDuration("REFUND_FULL_BEFORE_MINUTES", Booking.RefundFullBefore, time.Minute)
+	Booking.RefundLatePercent = envInt("REFUND_LATE_PERCENT", Booking.RefundLatePercent)
+	if Booking.RefundLatePercent > 100 {
+		log.Printf("Invalid value for REFUND_LATE_PERCENT: %d, using 100", Booking.RefundLatePercent)
+		Booking.RefundLatePercent = 100
+	}
+	Booking.MaxDuration = envDuration("MAX_BOOKING_HOURS", Booking.MaxDuration, time.Hour)
+	Booking.MinDuration = envDuration("MIN_BOOKING_MINUTES", Booking.MinDuration, time.Minute)
+	Booking.SlotGranularity = envDuration("SLOT_MINUTES", Booking.SlotGranularity, time.Minute)
+	Booking.SlotRounding = envString("SLOT_ROUNDING", Booking.SlotRounding, "expand", "nearest", "strict")
+	Booking.MaxConcurrent = envInt("MAX_CONCURRENT_BOOKINGS", Booking.MaxConcurrent)
+	Booking.MaxPerCar = envInt("MAX_BOOKINGS_PER_CAR", Booking.MaxPerCar)
+	Booking.MaxWeekly = envDuration("MAX_WEEKLY_HOURS", Booking.MaxWeekly, time.Hour)
+	Booking.MinGap = envDuration("MIN_BOOKING_GAP_MINUTES", Booking.MinGap, time.Minute)
+	Booking.CheckInEarly = envDuration("CHECK_IN_EARLY_MINUTES", Booking.CheckInEarly, time.Minute)
+	Booking.NoShowGrace = envDuration("NO_SHOW_GRACE_MINUTES", Booking.NoShowGrace, time.Minute)
+	Booking.NoShowInterval = envDuration("NO_SHOW_CHECK_INTERVAL_SECONDS", Booking.NoShowInterval, time.Second)
+	Booking.SeriesMaxLength = envDuration("SERIES_MAX_DAYS", Booking.SeriesMaxLength, 24*time.Hour)
+	Booking.WaitlistOfferTTL = envDuration("WAITLIST_OFFER_MINUTES", Booking.WaitlistOfferTTL, time.Minute)
+	Booking.WaitlistInterval = envDuration("WAITLIST_CHECK_INTERVAL_SECONDS", Booking.WaitlistInterval, time.Second)
+	Booking.AssignmentStrategy = envString("ASSIGNMENT_STRATEGY", Booking.AssignmentStrategy,
+		"fill_from_entrance", "spread_out", "preferred", "attribute_match")
+	Booking.Currency = envCurrency("CURRENCY", Booking.Currency)
+	Booking.Location = envLocation("TIMEZONE", Booking.Location)
+
+	Payments.Provider = envString("PAYMENT_PROVIDER", Payments.Provider, "fake")
+	if secret := os.Getenv("PAYMENT_CALLBACK_SECRET"); secret != "" {
+		Payments.CallbackSecret = secret
+	}
+	Payments.FakeCheckout = envBool("PAYMENT_FAKE_CHECKOUT", Payments.FakeCheckout)
+	Payments.Timeout = envDuration("PAYMENT_TIMEOUT_MINUTES", Payments.Timeout, time.Minute)
+	Payments.CheckInterval = envDuration("PAYMENT_CHECK_INTERVAL_SECONDS", Payments.CheckInterval, time.Second)
+	Payments.AllowanceInterval = envDuration("ALLOWANCE_CHECK_INTERVAL_MINUTES", Payments.AllowanceInterval, time.Minute)
+	Payments.RefundInterval = envDuration("REFUND_RETRY_INTERVAL_SECONDS", Payments.RefundInterval, time.Second)
+}
+
+// envInt читает неотрицательное целое из переменной окружения
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		log.Printf("Invalid value for %s: %q, using default", key, value)
+		return def
+	}
+	return number
+}
+
+// envString читает строку из переменной окружения, допуская только перечисленные значения
+func envString(key, def string, allowed ...string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	for _, candidate := range allowed {
+		if value == candidate {
+			return value
+		}
+	}
+	log.Printf("Invalid value for %s: %q, using default", key, value)
+	return def
+}
+
+// envBool читает флаг true или false из переменной окружения
+func envBool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default", key, value)
+		return def
+	}
+	return flag
+}
+
+// envDuration читает длительность, заданную целым числом единиц unit
+func envDuration(key string, def, unit time.Duration) time.Duration {
+	return time.Duration(envInt(key, int(def/unit))) * unit
+}
+
+// envCurrency читает трехбуквенный код валюты ISO 4217, например RUB
+func envCurrency(key, def string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	if len(value) != 3 || strings.Trim(value, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		log.Printf("Invalid value for %s: %q, using default", key, value)
+		return def
+	}
+	return value
+}
+
+// envLocation читает часовой пояс в формате IANA, например Asia/Tbilisi
+func envLocation(key string, def *time.Location) *time.Location {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default", key, value)
+		return def
+	}
+	return location
+}
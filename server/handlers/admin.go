@@ -3,9 +3,11 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"github.com/gorilla/mux"
 	"log"
 	"net/http"
+	"server/models"
 	"server/utils"
 	"strconv"
 )
@@ -36,7 +38,7 @@ func GetUsersHandler(db *sql.DB) http.HandlerFunc {
 		}
 
 		// Получаем список пользователей из базы данных
-		rows, err := db.Query("SELECT id, email, account_type FROM users")
+		rows, err := db.Query("SELECT id, email, account_type, accessibility_permit, user_group FROM users")
 		if err != nil {
 			http.Error(w, "Database error", http.StatusInternalServerError)
 			return
@@ -47,14 +49,18 @@ func GetUsersHandler(db *sql.DB) http.HandlerFunc {
 		for rows.Next() {
 			var id int
 			var email, accountType string
-			if err := rows.Scan(&id, &email, &accountType); err != nil {
+			var permit bool
+			var group *string
+			if err := rows.Scan(&id, &email, &accountType, &permit, &group); err != nil {
 				http.Error(w, "Database error", http.StatusInternalServerError)
 				return
 			}
 			users = append(users, map[string]interface{}{
-				"id":           id,
-				"email":        email,
-				"account_type": accountType,
+				"id":                   id,
+				"email":                email,
+				"account_type":         accountType,
+				"accessibility_permit": permit,
+				"user_group":           group,
 			})
 		}
 
@@ -113,6 +119,55 @@ func UpdateUserRoleHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// UpdateAccessibilityPermitHandler выдает или отзывает разрешение на места для людей с инвалидностью
+func UpdateAccessibilityPermitHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		userID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		var requestBody struct {
+			Permit *bool `json:"permit"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil || requestBody.Permit == nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		err = models.SetAccessibilityPermit(db, userID, *requestBody.Permit)
+		if errors.Is(err, models.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Update accessibility permit error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Accessibility permit for user %d set to %v", userID, *requestBody.Permit)
+
+		json.NewEncoder(w).Encode(AdminResponse{
+			Success: true,
+			Message: "Accessibility permit updated successfully",
+		})
+	}
+}
+
 // Обработчик для получения всех бронирований
 func GetBookingsHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -131,11 +186,12 @@ func GetBookingsHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Получаем список активных бронирований
+		// Получаем список бронирований, которые занимают место
 		rows, err := db.Query(`
-            SELECT id, parking_spot, car_number, reserved_at, hours
+            SELECT id, parking_spot, car_number, reserved_at, ends_at,
+                EXTRACT(EPOCH FROM ends_at - reserved_at) / 3600
             FROM bookings
-            WHERE status = 'active'
+            WHERE ` + models.OccupyingStatusCondition + `
         `)
 		if err != nil {
 			http.Error(w, "Database error", http.StatusInternalServerError)
@@ -145,10 +201,11 @@ func GetBookingsHandler(db *sql.DB) http.HandlerFunc {
 
 		var bookings []map[string]interface{}
 		for rows.Next() {
-			var id, parkingSpot, hours int
+			var id, parkingSpot int
+			var hours float64
 			var carNumber string
-			var reservedAt string
-			if err := rows.Scan(&id, &parkingSpot, &carNumber, &reservedAt, &hours); err != nil {
+			var reservedAt, endsAt string
+			if err := rows.Scan(&id, &parkingSpot, &carNumber, &reservedAt, &endsAt, &hours); err != nil {
 				http.Error(w, "Database error", http.StatusInternalServerError)
 				return
 			}
@@ -157,6 +214,7 @@ func GetBookingsHandler(db *sql.DB) http.HandlerFunc {
 				"parking_spot": parkingSpot,
 				"car_number":   carNumber,
 				"reserved_at":  reservedAt,
+				"ends_at":      endsAt,
 				"hours":        hours,
 			})
 		}
@@ -193,16 +251,29 @@ func CancelBookingHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Отменяем бронирование
-		_, err = db.Exec("UPDATE bookings SET status = 'cancelled' WHERE id = $1", bookingID)
+		// Отменяем бронирование с полным возвратом
+		adminID, _ := userIDFromClaims(claims)
+		booking, refund, err := adminCancelBooking(db, bookingID, adminID, "")
+		if errors.Is(err, models.ErrBookingNotFound) {
+			http.Error(w, "Booking not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrInvalidTransition) {
+			http.Error(w, "Booking cannot be cancelled in its current status", http.StatusConflict)
+			return
+		}
 		if err != nil {
+			log.Printf("Cancel booking error: %v", err)
 			http.Error(w, "Database error", http.StatusInternalServerError)
 			return
 		}
 
+		offerReleasedSpots(db, booking.ParkingSpot)
+
 		json.NewEncoder(w).Encode(AdminResponse{
 			Success: true,
 			Message: "Booking cancelled successfully",
+			Data:    refund,
 		})
 	}
 }
@@ -224,11 +295,19 @@ func GetBlockedSpots(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		lotID, ok := parseLotID(r.URL.Query().Get("lotId"))
+		if !ok {
+			http.Error(w, "Invalid lot ID", http.StatusBadRequest)
+			return
+		}
+
 		rows, err := db.Query(`
-			SELECT spot_number
-			FROM blocked_spots
-			WHERE is_blocked = true
-		`)
+			SELECT b.spot_number
+			FROM blocked_spots b
+			JOIN spots s ON s.number = b.spot_number
+			WHERE b.is_blocked = true
+			AND ($1 = 0 OR s.lot_id = $1)
+		`, lotID)
 
 		if err != nil {
 			log.Printf("Database query error: %v", err) // Добавьте это
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"server/config"
+	"server/models"
+)
+
+// assignmentStrategy возвращает стратегию из запроса или настроек; при ошибке — текст для клиента
+func assignmentStrategy(name string) (models.AssignmentStrategy, string) {
+	if name == "" {
+		name = config.Booking.AssignmentStrategy
+	}
+	strategy, ok := models.GetAssignmentStrategy(name)
+	if !ok {
+		return nil, "Unknown assignment strategy: " + name
+	}
+	return strategy, ""
+}
+
+// assignSpots подбирает места для брони без указанного места: свободные на всем интервале,
+// открытые в это время и подходящие пользователю и машине, от лучшего к худшему.
+// Для неизвестной парковки возвращает models.ErrLotNotFound
+func assignSpots(tx *sql.Tx, booking models.Booking, req BookingRequest, strategy models.AssignmentStrategy) ([]int, error) {
+	lotID := 0
+	if req.LotID != nil {
+		if _, err := models.GetLot(tx, *req.LotID); err != nil {
+			return nil, err
+		}
+		lotID = *req.LotID
+	}
+	free, err := models.FreeSpots(tx, lotID, req.Features, booking.ReservedAt, booking.PlannedEndsAt)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []models.Spot{}
+	for _, spot := range free {
+		message, err := checkSpotOpen(tx, spot.Number, booking.ReservedAt, booking.PlannedEndsAt)
+		if err != nil {
+			return nil, err
+		}
+		if message != "" {
+			continue
+		}
+
+		booking.ParkingSpot = spot.Number
+		err = models.CheckSpotEligibility(tx, booking)
+		var violation *models.PolicyViolation
+		if errors.As(err, &violation) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, spot)
+	}
+
+	ranked, err := strategy.Rank(tx, candidates, models.AssignmentRequest{
+		UserID:    booking.UserID,
+		Start:     booking.ReservedAt,
+		End:       booking.PlannedEndsAt,
+		Features:  req.Features,
+		Preferred: req.PreferredSpots,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	spots := make([]int, 0, len(ranked))
+	for _, spot := range ranked {
+		spots = append(spots, spot.Number)
+	}
+	return spots, nil
+}
+
+// createOnFirstFree создает бронь на первом из мест, которое не заняли параллельные запросы.
+// Каждая попытка откатывается до точки сохранения; если заняты все места, возвращает ErrBookingConflict.
+// Промокод promo (nil — без промокода) учитывается в стоимости и записывается как примененный
+func createOnFirstFree(tx *sql.Tx, booking *models.Booking, spots []int, promo *models.PromoCode) (int, *models.Quote, error) {
+	for _, spot := range spots {
+		if _, err := tx.Exec("SAVEPOINT assignment"); err != nil {
+			return 0, nil, err
+		}
+		booking.ParkingSpot = spot
+		quote, err := priceBooking(tx, booking, promo)
+		if err != nil {
+			return 0, nil, err
+		}
+		holdUntilPaid(booking)
+		bookingID, err := models.CreateBooking(tx, booking)
+		if errors.Is(err, models.ErrBookingConflict) {
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT assignment"); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		if promo != nil {
+			if err := models.RedeemPromoCode(tx, promo.ID, booking.UserID, bookingID, quote.PromoDiscount); err != nil {
+				return 0, nil, err
+			}
+		}
+		_, err = tx.Exec("RELEASE SAVEPOINT assignment")
+		return bookingID, quote, err
+	}
+	return 0, nil, models.ErrBookingConflict
+}
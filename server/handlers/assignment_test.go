@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"server/models"
+	"server/testutil"
+)
+
+func TestCreateOnFirstFreeAfterConflict(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userID := testutil.CreateUser(t, db, "driver@example.com")
+	otherID := testutil.CreateUser(t, db, "other@example.com")
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+
+	// Первое место платное и уже занято, второе бесплатное по тарифу для места с зарядкой
+	if _, err := db.Exec(`UPDATE spots SET features = '{ev_charger}' WHERE number = 2`); err != nil {
+		t.Fatalf("set spot features: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO tariffs (name, spot_feature, hourly_rate, active) VALUES ('Free EV', 'ev_charger', 0, TRUE)`); err != nil {
+		t.Fatalf("create tariff: %v", err)
+	}
+	_, err := models.CreateBooking(db, &models.Booking{
+		UserID: otherID, ParkingSpot: 1, CarNumber: "BB456CC",
+		ReservedAt: start, PlannedEndsAt: start.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("create conflicting booking: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer tx.Rollback()
+	booking := &models.Booking{
+		UserID: userID, CarNumber: "AA123BB",
+		ReservedAt: start, PlannedEndsAt: start.Add(time.Hour),
+	}
+	bookingID, quote, err := createOnFirstFree(tx, booking, []int{1, 2}, nil)
+	if err != nil {
+		t.Fatalf("createOnFirstFree: %v", err)
+	}
+	if quote.Amount != 0 {
+		t.Fatalf("fallback spot costs %d, want 0", quote.Amount)
+	}
+
+	created, err := models.GetBooking(tx, bookingID)
+	if err != nil {
+		t.Fatalf("get booking: %v", err)
+	}
+	if created.ParkingSpot != 2 {
+		t.Fatalf("booked spot %d, want 2", created.ParkingSpot)
+	}
+	if created.Status != models.StatusActive {
+		t.Fatalf("free fallback booking is %s, want active", created.Status)
+	}
+}
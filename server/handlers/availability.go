@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"server/models"
+	"server/utils"
+	"strings"
+	"time"
+)
+
+// Максимальная длина окна, по которому можно запросить доступность
+const maxAvailabilityWindow = 31 * 24 * time.Hour
+
+// parseTimeWindow читает окно ?from=&to= в формате RFC 3339; при ошибке возвращает текст для клиента
+func parseTimeWindow(query url.Values) (time.Time, time.Time, string) {
+	from, err := time.Parse(time.RFC3339, query.Get("from"))
+	if err != nil {
+		return from, from, "Invalid or missing from date"
+	}
+	to, err := time.Parse(time.RFC3339, query.Get("to"))
+	if err != nil {
+		return from, from, "Invalid or missing to date"
+	}
+	if !from.Before(to) {
+		return from, to, "from must be before to"
+	}
+	if to.Sub(from) > maxAvailabilityWindow {
+		return from, to, "Requested window is too long"
+	}
+	return from, to, ""
+}
+
+// parseFeatures читает список требуемых характеристик места: ?features=a,b
+func parseFeatures(value string) []string {
+	features := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if feature := strings.TrimSpace(item); feature != "" {
+			features = append(features, feature)
+		}
+	}
+	return features
+}
+
+// checkFeatures проверяет, что все характеристики места известны; при ошибке возвращает текст для клиента
+func checkFeatures(features []string) string {
+	for _, feature := range features {
+		if !models.IsValidFeature(feature) {
+			return "Unknown spot feature: " + feature
+		}
+	}
+	return ""
+}
+
+// GetAvailability возвращает для каждого места, свободно ли оно на всем окне,
+// свободные промежутки внутри окна и признак блокировки; ?features= оставляет только места
+// со всеми перечисленными характеристиками
+func GetAvailability(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if _, err := utils.GetAndValidateTokenClaims(r); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		from, to, message := parseTimeWindow(query)
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+
+		features := parseFeatures(query.Get("features"))
+		if message := checkFeatures(features); message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+
+		lotID, ok := parseLotID(query.Get("lotId"))
+		if !ok {
+			http.Error(w, "Invalid lot ID", http.StatusBadRequest)
+			return
+		}
+
+		numbers, err := models.ActiveSpotNumbers(db, lotID, features)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		spots, err := models.GetAvailability(db, numbers, from, to)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"from":  from,
+			"to":    to,
+			"spots": spots,
+		})
+	}
+}
@@ -3,25 +3,145 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gorilla/mux"
+	"io"
 	"log"
 	"net/http"
+	"server/config"
+	"server/models"
 	"server/utils"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type BookingRequest struct {
 	ParkingSpot int    `json:"parkingSpot"`
 	CarNumber   string `json:"carNumber"`
-	Hours       int    `json:"hours"`
+	// CarCountry — код страны регистрации CarNumber; по умолчанию Грузия
+	CarCountry string `json:"carCountry,omitempty"`
+	// VehicleID — машина из реестра пользователя; если указана, CarNumber не нужен
+	VehicleID *int `json:"vehicleId,omitempty"`
+	// LotID — выбранная клиентом парковка; место должно к ней относиться
+	LotID *int `json:"lotId,omitempty"`
+	// Features — характеристики, которые нужны от автоматически подобранного места
+	Features []string `json:"features,omitempty"`
+	// PreferredSpots — места, которые стратегия preferred предлагает в первую очередь
+	PreferredSpots []int `json:"preferredSpots,omitempty"`
+	// Strategy — стратегия подбора места; пустая означает стратегию из настроек
+	Strategy string `json:"strategy,omitempty"`
+	// Hours — устаревший способ задать длительность; вместо него передается EndsAt
+	Hours int `json:"hours,omitempty"`
+	// StartsAt — время начала брони; если не указано, бронь начинается сейчас
+	StartsAt *time.Time `json:"startsAt,omitempty"`
+	// EndsAt — время окончания брони
+	EndsAt *time.Time `json:"endsAt,omitempty"`
+	// PaymentMethod — wallet или provider; пустой способ выбирает баланс, если на нем есть средства
+	PaymentMethod string `json:"paymentMethod,omitempty"`
+	// PromoCode — промокод на скидку; регистр не важен
+	PromoCode string `json:"promoCode,omitempty"`
 }
 
 type BookingResponse struct {
-	ID         int       `json:"id"`
-	ReservedAt time.Time `json:"reservedAt"`
-	EndTime    time.Time `json:"endTime"`
-	Message    string    `json:"message"`
+	ID          int       `json:"id"`
+	ParkingSpot int       `json:"parkingSpot"`
+	ReservedAt  time.Time `json:"reservedAt"`
+	StartsAt    time.Time `json:"startsAt"`
+	EndTime     time.Time `json:"endTime"`
+	// Price — стоимость брони в минимальных единицах валюты (копейках)
+	Price    int64  `json:"price"`
+	Currency string `json:"currency"`
+	// PromoCode и PromoDiscount — примененный промокод и скидка по нему
+	PromoCode     string               `json:"promoCode,omitempty"`
+	PromoDiscount int64                `json:"promoDiscount,omitempty"`
+	Status        models.BookingStatus `json:"status"`
+	// Payment — платеж, после подтверждения которого бронь станет активной
+	Payment *models.Payment `json:"payment,omitempty"`
+	Message string          `json:"message"`
+}
+
+// Допустимое отставание startsAt от текущего времени (рассинхронизация часов клиента)
+const startsAtClockSkew = time.Minute
+
+// unalignedSlotMessage возвращает текст ошибки для границ брони, не попадающих на сетку слотов
+func unalignedSlotMessage() string {
+	return fmt.Sprintf("Booking times must align to %d-minute slots", int(config.Booking.SlotGranularity/time.Minute))
+}
+
+// resolveBookingWindow определяет интервал брони по startsAt и endsAt (или устаревшему hours),
+// выравнивает границы по слотам в часовом поясе loc и проверяет минимальную длительность;
+// при ошибке возвращает текст для клиента
+func resolveBookingWindow(startsAt, endsAt *time.Time, hours int, now time.Time, loc *time.Location) (time.Time, time.Time, string) {
+	slot, mode := config.Booking.SlotGranularity, config.Booking.SlotRounding
+
+	start := now
+	if startsAt != nil {
+		if startsAt.Before(now.Add(-startsAtClockSkew)) {
+			return start, start, "Start time must not be in the past"
+		}
+		if startsAt.After(now.Add(config.Booking.Horizon)) {
+			return start, start, "Start time is beyond the booking horizon"
+		}
+		aligned, err := models.AlignStart(*startsAt, slot, mode, loc)
+		if err != nil {
+			return start, start, unalignedSlotMessage()
+		}
+		// Бронь не может начинаться в прошлом, даже если слот начался чуть раньше
+		if aligned.After(now) {
+			start = aligned
+		}
+	}
+
+	var end time.Time
+	switch {
+	case endsAt != nil && hours != 0:
+		return start, start, "Specify either endsAt or hours, not both"
+	case endsAt != nil:
+		aligned, err := models.AlignEnd(*endsAt, slot, mode, loc)
+		if err != nil {
+			return start, start, unalignedSlotMessage()
+		}
+		end = aligned
+	case hours > 0:
+		// Окончание, вычисленное от текущего момента, всегда дотягивается до границы слота
+		if mode == models.RoundStrict {
+			mode = models.RoundExpand
+		}
+		end, _ = models.AlignEnd(start.Add(time.Duration(hours)*time.Hour), slot, mode, loc)
+	case hours < 0:
+		return start, start, "Hours must be greater than 0"
+	default:
+		return start, start, "End time is required"
+	}
+
+	if !end.After(start) {
+		return start, end, "End time must be after the start time"
+	}
+	if end.Sub(start) < config.Booking.MinDuration {
+		return start, end, "Booking is shorter than the minimum duration"
+	}
+	return start, end, ""
+}
+
+// bookingPolicy собирает правила бронирования из настроек
+func bookingPolicy() models.BookingPolicy {
+	return models.BookingPolicy{
+		MaxConcurrent: config.Booking.MaxConcurrent,
+		MaxPerCar:     config.Booking.MaxPerCar,
+		MaxDuration:   config.Booking.MaxDuration,
+		MaxWeekly:     config.Booking.MaxWeekly,
+		MinGap:        config.Booking.MinGap,
+		Location:      config.Booking.Location,
+	}
+}
+
+// writePolicyViolation отвечает клиенту кодом нарушенного правила бронирования
+func writePolicyViolation(w http.ResponseWriter, violation *models.PolicyViolation) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(violation)
 }
 
 func BookParkingSpot(db *sql.DB) http.HandlerFunc {
@@ -63,34 +183,99 @@ func BookParkingSpot(db *sql.DB) http.HandlerFunc {
 		}
 		log.Printf("Booking data received: %+v", bookingData)
 
-		available, err := IsParkingSpotAvailable(db, bookingData.ParkingSpot)
+		// Валидация данных; без номера места сервер подберет его сам
+		autoAssign := bookingData.ParkingSpot == 0
+		var strategy models.AssignmentStrategy
+		if autoAssign {
+			if message := checkFeatures(bookingData.Features); message != "" {
+				http.Error(w, message, http.StatusBadRequest)
+				return
+			}
+			var message string
+			if strategy, message = assignmentStrategy(bookingData.Strategy); message != "" {
+				http.Error(w, message, http.StatusBadRequest)
+				return
+			}
+		} else {
+			validSpot, err := isValidParkingSpot(db, bookingData.ParkingSpot)
+			if err != nil {
+				log.Printf("Database query error: %v", err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if !validSpot {
+				log.Printf("Invalid parking spot: %d", bookingData.ParkingSpot)
+				http.Error(w, "Invalid parking spot number", http.StatusBadRequest)
+				return
+			}
+			message, err := checkSpotInLot(db, bookingData.ParkingSpot, bookingData.LotID)
+			if err != nil {
+				log.Printf("Database query error: %v", err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if message != "" {
+				http.Error(w, message, http.StatusBadRequest)
+				return
+			}
+		}
+		if message := checkPaymentMethod(bookingData.PaymentMethod); message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		carNumber, message, err := resolveCarNumber(db, userIDInt, bookingData.VehicleID, bookingData.CarNumber, bookingData.CarCountry)
 		if err != nil {
-			log.Printf("Error checking parking spot availability: %v", err)
+			log.Printf("Database query error: %v", err)
 			http.Error(w, "Database error", http.StatusInternalServerError)
 			return
 		}
-		if !available {
-			log.Printf("Parking spot %d is not available", bookingData.ParkingSpot)
-			http.Error(w, "Parking spot is not available", http.StatusConflict)
+		if message != "" {
+			log.Printf("Invalid vehicle: %s", message)
+			http.Error(w, message, http.StatusBadRequest)
 			return
 		}
 
-		// Валидация данных
-		if bookingData.ParkingSpot < 1 || bookingData.ParkingSpot > 16 {
-			log.Printf("Invalid parking spot: %d", bookingData.ParkingSpot)
-			http.Error(w, "Invalid parking spot number", http.StatusBadRequest)
+		// Определяем окно бронирования по часовому поясу парковки
+		loc, err := bookingLocation(db, bookingData.ParkingSpot, bookingData.LotID)
+		if errors.Is(err, models.ErrLotNotFound) {
+			http.Error(w, "Lot not found", http.StatusNotFound)
 			return
 		}
-		if bookingData.Hours < 1 {
-			log.Printf("Invalid hours: %d", bookingData.Hours)
-			http.Error(w, "Hours must be greater than 0", http.StatusBadRequest)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
 			return
 		}
-		if bookingData.CarNumber == "" {
-			log.Printf("Empty car number")
-			http.Error(w, "Car number is required", http.StatusBadRequest)
+		reservedAt, endTime, message := resolveBookingWindow(bookingData.StartsAt, bookingData.EndsAt, bookingData.Hours, time.Now(), loc)
+		if message != "" {
+			log.Printf("Invalid booking window: %s", message)
+			http.Error(w, message, http.StatusBadRequest)
 			return
 		}
+		if !autoAssign {
+			message, err = checkSpotOpen(db, bookingData.ParkingSpot, reservedAt, endTime)
+			if err != nil {
+				log.Printf("Database query error: %v", err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if message != "" {
+				http.Error(w, message, http.StatusUnprocessableEntity)
+				return
+			}
+
+			available, err := IsParkingSpotAvailable(db, bookingData.ParkingSpot, reservedAt, endTime)
+			if err != nil {
+				log.Printf("Error checking parking spot availability: %v", err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if !available {
+				log.Printf("Parking spot %d is not available", bookingData.ParkingSpot)
+				http.Error(w, "Parking spot is not available", http.StatusConflict)
+				return
+			}
+		}
 
 		// Начинаем транзакцию
 		tx, err := db.Begin()
@@ -101,41 +286,119 @@ func BookParkingSpot(db *sql.DB) http.HandlerFunc {
 		}
 		defer tx.Rollback()
 
-		// Проверяем, не занято ли место
-		var count int
-		err = tx.QueryRow(`
-            SELECT COUNT(*)
-            FROM bookings
-            WHERE parking_spot = $1
-            AND reserved_at + (hours * interval '1 hour') > NOW()
-        `, bookingData.ParkingSpot).Scan(&count)
-
+		wallet, err := useWallet(tx, userIDInt, bookingData.PaymentMethod)
 		if err != nil {
-			log.Printf("Checking occupied spots error: %v", err)
+			log.Printf("Database query error: %v", err)
 			http.Error(w, "Database error", http.StatusInternalServerError)
 			return
 		}
 
-		if count > 0 {
-			log.Printf("Parking spot %d is already occupied", bookingData.ParkingSpot)
-			http.Error(w, "Parking spot is already booked", http.StatusConflict)
+		var violation *models.PolicyViolation
+		promo, err := usePromoCode(tx, bookingData.PromoCode, userIDInt)
+		if errors.As(err, &violation) {
+			log.Printf("Promo code of user %d rejected: %s", userIDInt, violation.Code)
+			writePolicyViolation(w, violation)
 			return
 		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		booking := models.Booking{
+			UserID:        userIDInt,
+			ParkingSpot:   bookingData.ParkingSpot,
+			CarNumber:     carNumber,
+			VehicleID:     bookingData.VehicleID,
+			ReservedAt:    reservedAt,
+			PlannedEndsAt: endTime,
+		}
 
-		// Создаем бронирование
-		reservedAt := time.Now()
-		var bookingID int
-		err = tx.QueryRow(`
-            INSERT INTO bookings (user_id, parking_spot, car_number, reserved_at, hours)
-            VALUES ($1, $2, $3, $4, $5)
-            RETURNING id
-        `, userIDInt, bookingData.ParkingSpot, bookingData.CarNumber, reservedAt, bookingData.Hours).Scan(&bookingID)
+		spots := []int{bookingData.ParkingSpot}
+		if autoAssign {
+			spots, err = assignSpots(tx, booking, bookingData, strategy)
+			if errors.Is(err, models.ErrLotNotFound) {
+				http.Error(w, "Lot not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("Spot assignment error: %v", err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if len(spots) == 0 {
+				http.Error(w, "No parking spot is available for the requested time", http.StatusConflict)
+				return
+			}
+			booking.ParkingSpot = spots[0]
+
+			// Подобранные места сужаются до тех, к которым относится промокод; если таких нет,
+			// расчет стоимости первого места вернет нарушение promo_code_not_applicable
+			if promo != nil {
+				applicable, err := models.FilterPromoSpots(tx, promo, spots)
+				if err != nil {
+					log.Printf("Database query error: %v", err)
+					http.Error(w, "Database error", http.StatusInternalServerError)
+					return
+				}
+				if len(applicable) > 0 {
+					spots = applicable
+					booking.ParkingSpot = spots[0]
+				}
+			}
+		}
 
+		// Проверяем лимиты пользователя
+		err = models.CheckBookingPolicy(tx, bookingPolicy(), booking)
+		if errors.As(err, &violation) {
+			log.Printf("Booking policy violation for user %d: %s", userIDInt, violation.Code)
+			writePolicyViolation(w, violation)
+			return
+		}
+		if err != nil {
+			log.Printf("Booking policy check error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		// Создаем бронирование; пересечение с другой бронью отсекается ограничением в базе,
+		// а подобранное место при гонке заменяется следующим по стратегии
+		bookingID, quote, err := createOnFirstFree(tx, &booking, spots, promo)
+		if errors.Is(err, models.ErrBookingConflict) {
+			log.Printf("Parking spots %v are already booked for %v - %v", spots, reservedAt, endTime)
+			http.Error(w, "Parking spot is already booked", http.StatusConflict)
+			return
+		}
+		if errors.As(err, &violation) {
+			log.Printf("Promo code of user %d rejected: %s", userIDInt, violation.Code)
+			writePolicyViolation(w, violation)
+			return
+		}
 		if err != nil {
 			log.Printf("Insert booking error: %v", err)
 			http.Error(w, "Error while booking", http.StatusInternalServerError)
 			return
 		}
+		booking.ID = bookingID
+
+		// Платная бронь оплачивается с баланса или становится активной только после оплаты у провайдера
+		var payment *models.Payment
+		if wallet {
+			err = settleFromWallet(tx, []*models.Booking{&booking})
+		} else {
+			payment, err = startPayment(tx, userIDInt, []models.Booking{booking})
+		}
+		if errors.As(err, &violation) {
+			log.Printf("Booking of user %d rejected: %s", userIDInt, violation.Code)
+			writePolicyViolation(w, violation)
+			return
+		}
+		if err != nil {
+			log.Printf("Create payment error: %v", err)
+			http.Error(w, "Error while creating payment", http.StatusInternalServerError)
+			return
+		}
 
 		// Подтверждаем транзакцию
 		if err := tx.Commit(); err != nil {
@@ -145,12 +408,23 @@ func BookParkingSpot(db *sql.DB) http.HandlerFunc {
 		}
 
 		// Формируем ответ
-		endTime := reservedAt.Add(time.Duration(bookingData.Hours) * time.Hour)
+		price, currency := bookingPrice(&booking)
 		response := BookingResponse{
-			ID:         bookingID,
-			ReservedAt: reservedAt,
-			EndTime:    endTime,
-			Message:    "Booking successful!",
+			ID:            bookingID,
+			ParkingSpot:   booking.ParkingSpot,
+			ReservedAt:    reservedAt,
+			StartsAt:      reservedAt,
+			EndTime:       endTime,
+			Price:         price,
+			Currency:      currency,
+			PromoCode:     quote.PromoCode,
+			PromoDiscount: quote.PromoDiscount,
+			Status:        booking.Status,
+			Payment:       payment,
+			Message:       "Booking successful!",
+		}
+		if payment != nil {
+			response.Message = "Booking is awaiting payment"
 		}
 
 		log.Printf("Booking successful: %+v", response)
@@ -163,37 +437,14 @@ func BookParkingSpot(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func IsParkingSpotAvailable(db *sql.DB, spotNumber int) (bool, error) {
-	// Проверяем, не заблокировано ли место
-	var isBlocked bool
-	err := db.QueryRow(`
-        SELECT is_blocked
-        FROM blocked_spots
-        WHERE spot_number = $1 AND is_blocked = true
-    `, spotNumber).Scan(&isBlocked)
-
-	if err != nil && err != sql.ErrNoRows {
-		return false, err
-	}
-
-	if isBlocked {
-		return false, nil
-	}
-
-	// Проверяем, не занято ли место
-	var count int
-	err = db.QueryRow(`
-        SELECT COUNT(*)
-        FROM bookings
-        WHERE parking_spot = $1
-        AND reserved_at + (hours * interval '1 hour') > NOW()
-    `, spotNumber).Scan(&count)
-
-	if err != nil {
-		return false, err
-	}
+// isValidParkingSpot проверяет, что место существует на парковке и не выведено из эксплуатации
+func isValidParkingSpot(db models.Queryer, spotNumber int) (bool, error) {
+	return models.IsValidSpot(db, spotNumber)
+}
 
-	return count == 0, nil
+// IsParkingSpotAvailable проверяет, что место не заблокировано и свободно на всем интервале [start, end)
+func IsParkingSpotAvailable(db models.Queryer, spotNumber int, start, end time.Time) (bool, error) {
+	return models.IsSpotAvailable(db, spotNumber, start, end)
 }
 
 func GetAllBookings(db *sql.DB) http.HandlerFunc {
@@ -212,11 +463,12 @@ func GetAllBookings(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Получаем все активные бронирования
+		// Получаем все бронирования, которые занимают место
 		rows, err := db.Query(`
-            SELECT id, parking_spot, car_number, reserved_at, hours
+            SELECT id, parking_spot, car_number, reserved_at, ends_at,
+                EXTRACT(EPOCH FROM ends_at - reserved_at) / 3600, status
             FROM bookings
-            WHERE status = 'active'
+            WHERE ` + models.OccupyingStatusCondition + `
             ORDER BY reserved_at DESC
         `)
 		if err != nil {
@@ -228,11 +480,12 @@ func GetAllBookings(db *sql.DB) http.HandlerFunc {
 
 		var bookings []map[string]interface{}
 		for rows.Next() {
-			var id, parkingSpot, hours int
-			var carNumber string
-			var reservedAt time.Time
+			var id, parkingSpot int
+			var hours float64
+			var carNumber, status string
+			var reservedAt, endsAt time.Time
 
-			if err := rows.Scan(&id, &parkingSpot, &carNumber, &reservedAt, &hours); err != nil {
+			if err := rows.Scan(&id, &parkingSpot, &carNumber, &reservedAt, &endsAt, &hours, &status); err != nil {
 				log.Printf("Row scan error: %v", err)
 				continue
 			}
@@ -242,7 +495,9 @@ func GetAllBookings(db *sql.DB) http.HandlerFunc {
 				"parking_spot": parkingSpot,
 				"car_number":   carNumber,
 				"reserved_at":  reservedAt,
+				"ends_at":      endsAt,
 				"hours":        hours,
+				"status":       status,
 			})
 		}
 
@@ -276,26 +531,37 @@ func CancelBooking(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Отменяем бронирование
-		result, err := db.Exec(`
-            UPDATE bookings
-            SET status = 'cancelled'
-            WHERE id = $1 AND status = 'active'
-        `, bookingID)
+		// Причина отмены необязательна, тело запроса может отсутствовать
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		adminID, _ := userIDFromClaims(claims)
 
+		// Отменяем бронирование с полным возвратом (переход проверяется моделью статусов)
+		booking, refund, err := adminCancelBooking(db, bookingID, adminID, strings.TrimSpace(req.Reason))
+		if errors.Is(err, models.ErrBookingNotFound) {
+			http.Error(w, "Booking not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrInvalidTransition) {
+			http.Error(w, "Booking cannot be cancelled in its current status", http.StatusConflict)
+			return
+		}
 		if err != nil {
-			log.Printf("Database update error: %v", err)
+			log.Printf("Cancel booking error: %v", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		rowsAffected, _ := result.RowsAffected()
-		if rowsAffected == 0 {
-			http.Error(w, "Booking not found or already cancelled", http.StatusNotFound)
-			return
-		}
+		// Освободившееся место предлагаем листу ожидания
+		offerReleasedSpots(db, booking.ParkingSpot)
 
-		json.NewEncoder(w).Encode(map[string]string{
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"refund":  refund,
 			"message": "Booking cancelled successfully",
 		})
 	}
@@ -325,6 +591,16 @@ func ToggleSpotBlock(db *sql.DB) http.HandlerFunc {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
+		_, err = models.GetSpot(db, req.SpotNumber)
+		if errors.Is(err, models.ErrSpotNotFound) {
+			http.Error(w, "Parking spot not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 
 		// Проверяем существование записи для данного места
 		var exists bool
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"time"
+)
+
+// Максимальный размер загружаемой схемы парковки
+const maxLayoutBytes = 2 << 20
+
+// LotMapResponse — схема парковки с текущим состоянием мест
+type LotMapResponse struct {
+	Lot       *models.Lot    `json:"lot"`
+	Layout    *models.Layout `json:"layout"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// lotSpotNumbers возвращает номера всех мест парковки, включая выведенные из эксплуатации
+func lotSpotNumbers(db models.Queryer, lotID int) (map[int]bool, error) {
+	spots, err := models.ListSpots(db, lotID, true)
+	if err != nil {
+		return nil, err
+	}
+	numbers := map[int]bool{}
+	for _, spot := range spots {
+		numbers[spot.Number] = true
+	}
+	return numbers, nil
+}
+
+// adminLotFromRequest проверяет права администратора и существование парковки из URL;
+// при ошибке уже отвечает клиенту и возвращает false
+func adminLotFromRequest(db *sql.DB, w http.ResponseWriter, r *http.Request) (int, bool) {
+	claims, err := utils.GetAndValidateTokenClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+	if !isAdminFromClaims(claims) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return 0, false
+	}
+
+	lotID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid lot ID", http.StatusBadRequest)
+		return 0, false
+	}
+	_, err = models.GetLot(db, lotID)
+	if errors.Is(err, models.ErrLotNotFound) {
+		http.Error(w, "Lot not found", http.StatusNotFound)
+		return 0, false
+	}
+	if err != nil {
+		log.Printf("Database query error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return 0, false
+	}
+	return lotID, true
+}
+
+// writeLayoutError отвечает клиенту ошибкой проверки схемы или внутренней ошибкой
+func writeLayoutError(w http.ResponseWriter, err error) {
+	var layoutErr *models.LayoutError
+	if errors.As(err, &layoutErr) {
+		http.Error(w, layoutErr.Message, http.StatusBadRequest)
+		return
+	}
+	log.Printf("Save layout error: %v", err)
+	http.Error(w, "Error while saving layout", http.StatusInternalServerError)
+}
+
+// GetLotMap возвращает схему парковки, где у каждого места есть подпись, характеристики
+// и текущее состояние: free, occupied, blocked или retired
+func GetLotMap(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if _, err := utils.GetAndValidateTokenClaims(r); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		lotID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid lot ID", http.StatusBadRequest)
+			return
+		}
+
+		lot, err := models.GetLot(db, lotID)
+		if errors.Is(err, models.ErrLotNotFound) {
+			http.Error(w, "Lot not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		layout, updatedAt, err := models.GetLotLayout(db, lotID)
+		if errors.Is(err, models.ErrLayoutNotFound) {
+			http.Error(w, "Lot has no layout", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		spots, err := models.ListSpots(db, lotID, true)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		statuses, err := models.GetSpotStatuses(db, lotID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		byNumber := map[int]models.Spot{}
+		for _, spot := range spots {
+			byNumber[spot.Number] = spot
+		}
+		for i, feature := range layout.Features {
+			spot, ok := byNumber[feature.SpotNumber()]
+			if feature.Kind() != models.LayoutSpot || !ok {
+				continue
+			}
+			properties := map[string]interface{}{}
+			for key, value := range feature.Properties {
+				properties[key] = value
+			}
+			properties["label"] = spot.Label
+			properties["attributes"] = spot.Features
+			properties["status"] = statuses[spot.Number]
+			layout.Features[i].Properties = properties
+		}
+
+		json.NewEncoder(w).Encode(LotMapResponse{
+			Lot:       lot,
+			Layout:    layout,
+			UpdatedAt: updatedAt,
+		})
+	}
+}
+
+// AdminGetLotLayout возвращает сохраненную схему парковки без текущего состояния мест
+func AdminGetLotLayout(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		lotID, ok := adminLotFromRequest(db, w, r)
+		if !ok {
+			return
+		}
+
+		layout, _, err := models.GetLotLayout(db, lotID)
+		if errors.Is(err, models.ErrLayoutNotFound) {
+			http.Error(w, "Lot has no layout", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(layout)
+	}
+}
+
+// AdminPutLotLayout загружает схему парковки целиком в формате GeoJSON FeatureCollection
+func AdminPutLotLayout(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		lotID, ok := adminLotFromRequest(db, w, r)
+		if !ok {
+			return
+		}
+
+		var layout models.Layout
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLayoutBytes)).Decode(&layout); err != nil {
+			http.Error(w, "Invalid GeoJSON", http.StatusBadRequest)
+			return
+		}
+
+		spots, err := lotSpotNumbers(db, lotID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := layout.Validate(spots); err != nil {
+			writeLayoutError(w, err)
+			return
+		}
+		if err := models.SaveLotLayout(db, lotID, &layout); err != nil {
+			writeLayoutError(w, err)
+			return
+		}
+
+		log.Printf("Layout of lot %d updated, %d features", lotID, len(layout.Features))
+
+		json.NewEncoder(w).Encode(layout)
+	}
+}
+
+// AdminPutSpotGeometry задает геометрию одного места на схеме, сохраняя остальные объекты
+func AdminPutSpotGeometry(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		lotID, ok := adminLotFromRequest(db, w, r)
+		if !ok {
+			return
+		}
+		spotNumber, err := strconv.Atoi(mux.Vars(r)["n"])
+		if err != nil {
+			http.Error(w, "Invalid parking spot number", http.StatusBadRequest)
+			return
+		}
+
+		var geometry models.Geometry
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLayoutBytes)).Decode(&geometry); err != nil {
+			http.Error(w, "Invalid GeoJSON geometry", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		layout, _, err := models.LockLotLayout(tx, lotID)
+		if errors.Is(err, models.ErrLayoutNotFound) {
+			layout, err = &models.Layout{Type: "FeatureCollection", Features: []models.LayoutFeature{}}, nil
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		updated := false
+		for i, feature := range layout.Features {
+			if feature.Kind() == models.LayoutSpot && feature.SpotNumber() == spotNumber {
+				layout.Features[i].Geometry = geometry
+				updated = true
+			}
+		}
+		if !updated {
+			layout.Features = append(layout.Features, models.LayoutFeature{
+				Type:       "Feature",
+				Geometry:   geometry,
+				Properties: map[string]interface{}{"kind": models.LayoutSpot, "spot": float64(spotNumber)},
+			})
+		}
+
+		spots, err := lotSpotNumbers(tx, lotID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := layout.Validate(spots); err != nil {
+			writeLayoutError(w, err)
+			return
+		}
+		if err := models.SaveLotLayout(tx, lotID, layout); err != nil {
+			writeLayoutError(w, err)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(layout)
+	}
+}
+
+// AdminDeleteLotLayout удаляет схему парковки
+func AdminDeleteLotLayout(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		lotID, ok := adminLotFromRequest(db, w, r)
+		if !ok {
+			return
+		}
+
+		err := models.DeleteLotLayout(db, lotID)
+		if errors.Is(err, models.ErrLayoutNotFound) {
+			http.Error(w, "Lot has no layout", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Delete layout error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Layout deleted successfully",
+		})
+	}
+}
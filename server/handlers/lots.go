@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LotRequest — данные парковки для создания или изменения
+type LotRequest struct {
+	Name     string  `json:"name"`
+	Address  string  `json:"address"`
+	Timezone string  `json:"timezone"`
+	OpensAt  *string `json:"opensAt"`
+	ClosesAt *string `json:"closesAt"`
+}
+
+// ZoneRequest — данные новой зоны парковки
+type ZoneRequest struct {
+	Name string `json:"name"`
+}
+
+// Ограничения длины полей парковки и зоны
+const (
+	maxLotNameLength    = 100
+	maxLotAddressLength = 255
+	maxZoneNameLength   = 50
+)
+
+// parseLotID читает необязательный фильтр ?lotId=; 0 означает все парковки
+func parseLotID(value string) (int, bool) {
+	if value == "" {
+		return 0, true
+	}
+	lotID, err := strconv.Atoi(value)
+	if err != nil || lotID < 1 {
+		return 0, false
+	}
+	return lotID, true
+}
+
+// checkSpotInLot проверяет, что место относится к выбранной клиентом парковке;
+// при нарушении возвращает текст для клиента
+func checkSpotInLot(db models.Queryer, spotNumber int, lotID *int) (string, error) {
+	if lotID == nil {
+		return "", nil
+	}
+	lot, err := models.GetSpotLot(db, spotNumber)
+	if err != nil {
+		return "", err
+	}
+	if lot.ID != *lotID {
+		return "Parking spot does not belong to the selected lot", nil
+	}
+	return "", nil
+}
+
+// checkSpotOpen проверяет, что интервал брони попадает в часы работы парковки места;
+// при нарушении возвращает текст для клиента
+func checkSpotOpen(db models.Queryer, spotNumber int, start, end time.Time) (string, error) {
+	lot, err := models.GetSpotLot(db, spotNumber)
+	if err != nil {
+		return "", err
+	}
+	err = lot.CheckOpen(start, end, config.Booking.Location)
+	if errors.Is(err, models.ErrLotClosed) {
+		return "Booking is outside the lot's opening hours", nil
+	}
+	return "", err
+}
+
+// spotLocation возвращает часовой пояс парковки, к которой относится место
+func spotLocation(db models.Queryer, spotNumber int) (*time.Location, error) {
+	lot, err := models.GetSpotLot(db, spotNumber)
+	if err != nil {
+		return nil, err
+	}
+	return lot.Location(config.Booking.Location)
+}
+
+// bookingLocation возвращает часовой пояс, по которому выравнивается бронь: парковки места,
+// а если место подбирает сервер — выбранной парковки или пояс по умолчанию
+func bookingLocation(db models.Queryer, spotNumber int, lotID *int) (*time.Location, error) {
+	if spotNumber != 0 {
+		return spotLocation(db, spotNumber)
+	}
+	if lotID == nil || *lotID == 0 {
+		return config.Booking.Location, nil
+	}
+	lot, err := models.GetLot(db, *lotID)
+	if err != nil {
+		return nil, err
+	}
+	return lot.Location(config.Booking.Location)
+}
+
+// newLot проверяет запрос и собирает парковку; при ошибке возвращает текст для клиента
+func newLot(req LotRequest) (*models.Lot, string) {
+	lot := &models.Lot{
+		Name:     strings.TrimSpace(req.Name),
+		Address:  strings.TrimSpace(req.Address),
+		Timezone: strings.TrimSpace(req.Timezone),
+		OpensAt:  req.OpensAt,
+		ClosesAt: req.ClosesAt,
+	}
+	if lot.Name == "" || len(lot.Name) > maxLotNameLength {
+		return nil, "Lot name must be between 1 and 100 characters"
+	}
+	if len(lot.Address) > maxLotAddressLength {
+		return nil, "Lot address is too long"
+	}
+	if lot.Timezone != "" {
+		if _, err := time.LoadLocation(lot.Timezone); err != nil {
+			return nil, "Unknown timezone"
+		}
+	}
+	if (lot.OpensAt == nil) != (lot.ClosesAt == nil) {
+		return nil, "Both opensAt and closesAt must be set, or neither"
+	}
+	if lot.OpensAt != nil {
+		opens, err := time.Parse(models.ClockLayout, *lot.OpensAt)
+		if err != nil {
+			return nil, "Invalid opensAt, expected HH:MM"
+		}
+		closes, err := time.Parse(models.ClockLayout, *lot.ClosesAt)
+		if err != nil {
+			return nil, "Invalid closesAt, expected HH:MM"
+		}
+		if !opens.Before(closes) {
+			return nil, "opensAt must be before closesAt"
+		}
+	}
+	return lot, ""
+}
+
+// GetLots возвращает парковки вместе с зонами
+func GetLots(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if _, err := utils.GetAndValidateTokenClaims(r); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		lots, err := models.ListLots(db)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lots": lots,
+		})
+	}
+}
+
+// AdminCreateLot добавляет парковку
+func AdminCreateLot(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		var req LotRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid lot data", http.StatusBadRequest)
+			return
+		}
+		lot, message := newLot(req)
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+
+		lotID, err := models.CreateLot(db, lot)
+		if errors.Is(err, models.ErrLotExists) {
+			http.Error(w, "Lot with this name already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Insert lot error: %v", err)
+			http.Error(w, "Error while saving lot", http.StatusInternalServerError)
+			return
+		}
+
+		created, err := models.GetLot(db, lotID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+// AdminUpdateLot заменяет данные парковки
+func AdminUpdateLot(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		lotID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid lot ID", http.StatusBadRequest)
+			return
+		}
+
+		var req LotRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid lot data", http.StatusBadRequest)
+			return
+		}
+		lot, message := newLot(req)
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		lot.ID = lotID
+
+		err = models.UpdateLot(db, lot)
+		if errors.Is(err, models.ErrLotNotFound) {
+			http.Error(w, "Lot not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrLotExists) {
+			http.Error(w, "Lot with this name already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Update lot error: %v", err)
+			http.Error(w, "Error while saving lot", http.StatusInternalServerError)
+			return
+		}
+
+		updated, err := models.GetLot(db, lotID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(updated)
+	}
+}
+
+// AdminCreateZone добавляет зону в парковку
+func AdminCreateZone(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		lotID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid lot ID", http.StatusBadRequest)
+			return
+		}
+
+		var req ZoneRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid zone data", http.StatusBadRequest)
+			return
+		}
+		zone := models.Zone{LotID: lotID, Name: strings.TrimSpace(req.Name)}
+		if zone.Name == "" || len(zone.Name) > maxZoneNameLength {
+			http.Error(w, "Zone name must be between 1 and 50 characters", http.StatusBadRequest)
+			return
+		}
+
+		_, err = models.GetLot(db, lotID)
+		if errors.Is(err, models.ErrLotNotFound) {
+			http.Error(w, "Lot not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		zone.ID, err = models.CreateZone(db, &zone)
+		if errors.Is(err, models.ErrZoneExists) {
+			http.Error(w, "Zone with this name already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Insert zone error: %v", err)
+			http.Error(w, "Error while saving zone", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(zone)
+	}
+}
+
+// AdminDeleteZone удаляет зону; ее места остаются в парковке без зоны
+func AdminDeleteZone(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		vars := mux.Vars(r)
+		lotID, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			http.Error(w, "Invalid lot ID", http.StatusBadRequest)
+			return
+		}
+		zoneID, err := strconv.Atoi(vars["zoneId"])
+		if err != nil {
+			http.Error(w, "Invalid zone ID", http.StatusBadRequest)
+			return
+		}
+
+		err = models.DeleteZone(db, lotID, zoneID)
+		if errors.Is(err, models.ErrZoneNotFound) {
+			http.Error(w, "Zone not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Delete zone error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Zone deleted successfully",
+		})
+	}
+}
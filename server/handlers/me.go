@@ -0,0 +1,653 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"io"
+	"log"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMyBookingsLimit = 20
+	maxMyBookingsLimit     = 100
+)
+
+// MyBooking — бронь в ответе для ее владельца
+type MyBooking struct {
+	ID          int       `json:"id"`
+	ParkingSpot int       `json:"parkingSpot"`
+	CarNumber   string    `json:"carNumber"`
+	StartsAt    time.Time `json:"startsAt"`
+	EndTime     time.Time `json:"endTime"`
+	// PlannedEndTime отличается от EndTime, если пользователь выехал досрочно
+	PlannedEndTime time.Time            `json:"plannedEndTime"`
+	CheckedInAt    *time.Time           `json:"checkedInAt,omitempty"`
+	CheckedOutAt   *time.Time           `json:"checkedOutAt,omitempty"`
+	Status         models.BookingStatus `json:"status"`
+	Phase          string               `json:"phase"`
+	Price          int64                `json:"price"`
+	Currency       string               `json:"currency"`
+}
+
+// GetMyBookings возвращает текущие, будущие и прошедшие брони вызывающего пользователя
+func GetMyBookings(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		filter := models.UserBookingFilter{
+			UserID: userID,
+			Limit:  defaultMyBookingsLimit,
+		}
+
+		query := r.URL.Query()
+
+		// Фильтр по статусам: ?status=active,checked_in
+		if value := query.Get("status"); value != "" {
+			for _, item := range strings.Split(value, ",") {
+				status := models.BookingStatus(strings.TrimSpace(item))
+				if !status.Valid() {
+					http.Error(w, "Invalid status filter", http.StatusBadRequest)
+					return
+				}
+				filter.Statuses = append(filter.Statuses, status)
+			}
+		}
+
+		// Фильтр по фазе: ?scope=current|upcoming|past
+		switch scope := query.Get("scope"); scope {
+		case "", models.PhaseCurrent, models.PhaseUpcoming, models.PhasePast:
+			filter.Phase = scope
+		default:
+			http.Error(w, "Invalid scope", http.StatusBadRequest)
+			return
+		}
+
+		// Фильтр по датам: ?from=...&to=... в формате RFC 3339
+		if value := query.Get("from"); value != "" {
+			from, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "Invalid from date", http.StatusBadRequest)
+				return
+			}
+			filter.From = &from
+		}
+		if value := query.Get("to"); value != "" {
+			to, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "Invalid to date", http.StatusBadRequest)
+				return
+			}
+			filter.To = &to
+		}
+		if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+			http.Error(w, "from must be before to", http.StatusBadRequest)
+			return
+		}
+
+		// Пагинация: ?limit=20&cursor=...
+		if value := query.Get("limit"); value != "" {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			if limit > maxMyBookingsLimit {
+				limit = maxMyBookingsLimit
+			}
+			filter.Limit = limit
+		}
+		if value := query.Get("cursor"); value != "" {
+			cursor, err := models.DecodeBookingCursor(value)
+			if err != nil {
+				http.Error(w, "Invalid cursor", http.StatusBadRequest)
+				return
+			}
+			filter.After = cursor
+		}
+
+		// Запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+		pageSize := filter.Limit
+		filter.Limit++
+		bookings, err := models.ListUserBookings(db, filter)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		var nextCursor string
+		if len(bookings) > pageSize {
+			bookings = bookings[:pageSize]
+			last := bookings[len(bookings)-1]
+			nextCursor = models.BookingCursor{ReservedAt: last.ReservedAt, ID: last.ID}.Encode()
+		}
+
+		now := time.Now()
+		result := make([]MyBooking, 0, len(bookings))
+		for _, booking := range bookings {
+			result = append(result, newMyBooking(booking, now))
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"bookings":   result,
+			"nextCursor": nextCursor,
+		})
+	}
+}
+
+func newMyBooking(booking models.Booking, now time.Time) MyBooking {
+	price, currency := bookingPrice(&booking)
+	return MyBooking{
+		ID:             booking.ID,
+		ParkingSpot:    booking.ParkingSpot,
+		CarNumber:      booking.CarNumber,
+		StartsAt:       booking.ReservedAt,
+		EndTime:        booking.EndsAt,
+		PlannedEndTime: booking.PlannedEndsAt,
+		CheckedInAt:    booking.CheckedInAt,
+		CheckedOutAt:   booking.CheckedOutAt,
+		Status:         booking.Status,
+		Phase:          booking.Phase(now),
+		Price:          price,
+		Currency:       currency,
+	}
+}
+
+// Максимальная длина причины отмены
+const maxCancellationReasonLength = 500
+
+// CancelMyBooking отменяет еще не начавшуюся бронь ее владельцем не позже CancelCutoff до начала;
+// начавшуюся бронь владелец завершает выездом
+func CancelMyBooking(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		bookingID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+			return
+		}
+
+		// Причина отмены необязательна, тело запроса может отсутствовать
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+		if len(req.Reason) > maxCancellationReasonLength {
+			http.Error(w, "Cancellation reason is too long", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		booking, err := models.LockUserBooking(tx, bookingID, userID)
+		if errors.Is(err, models.ErrBookingNotFound) {
+			http.Error(w, "Booking not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		if !booking.Status.Occupies() || !now.Before(booking.EndsAt) {
+			http.Error(w, "Only active or upcoming bookings can be cancelled", http.StatusConflict)
+			return
+		}
+		if !now.Before(booking.ReservedAt) {
+			http.Error(w, "Booking has already started, use POST /api/me/bookings/{id}/check-out to leave early", http.StatusConflict)
+			return
+		}
+		if booking.ReservedAt.Sub(now) < config.Booking.CancelCutoff {
+			http.Error(w, "Cancellation cut-off has passed for this booking", http.StatusConflict)
+			return
+		}
+
+		refund, err := cancelWithRefund(tx, booking, userID, req.Reason, false)
+		if errors.Is(err, models.ErrInvalidTransition) {
+			http.Error(w, "Booking cannot be cancelled in its current status", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Cancel booking error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Booking %d cancelled by its owner %d", bookingID, userID)
+		sendRefunds(db, *refund)
+		offerReleasedSpots(db, booking.ParkingSpot)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      bookingID,
+			"status":  models.StatusCancelled,
+			"refund":  refund,
+			"message": "Booking cancelled successfully",
+		})
+	}
+}
+
+// ExtendMyBooking продлевает текущую или будущую бронь владельца на том же месте
+func ExtendMyBooking(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		bookingID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+			return
+		}
+
+		// Продление задается в минутах; hours оставлен для старых клиентов
+		var req struct {
+			Minutes int `json:"minutes"`
+			Hours   int `json:"hours"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		extension := time.Duration(req.Minutes)*time.Minute + time.Duration(req.Hours)*time.Hour
+		if req.Minutes < 0 || req.Hours < 0 || extension <= 0 {
+			http.Error(w, "Extension must be greater than 0", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		booking, err := models.LockUserBooking(tx, bookingID, userID)
+		if errors.Is(err, models.ErrBookingNotFound) {
+			http.Error(w, "Booking not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if !booking.Status.Occupies() || !time.Now().Before(booking.EndsAt) {
+			http.Error(w, "Only active or upcoming bookings can be extended", http.StatusConflict)
+			return
+		}
+		if booking.Status == models.StatusPending {
+			http.Error(w, "Booking is awaiting payment", http.StatusConflict)
+			return
+		}
+		loc, err := spotLocation(tx, booking.ParkingSpot)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		newEndTime, err := models.AlignEnd(booking.EndsAt.Add(extension), config.Booking.SlotGranularity, config.Booking.SlotRounding, loc)
+		if err != nil {
+			http.Error(w, unalignedSlotMessage(), http.StatusBadRequest)
+			return
+		}
+		if !newEndTime.After(booking.EndsAt) {
+			http.Error(w, "Extension must be greater than 0", http.StatusBadRequest)
+			return
+		}
+
+		message, err := checkSpotOpen(tx, booking.ParkingSpot, booking.ReservedAt, newEndTime)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if message != "" {
+			http.Error(w, message, http.StatusUnprocessableEntity)
+			return
+		}
+
+		// Баланс блокируется до проверки правил, как при создании брони: иначе встречные
+		// запросы одного пользователя захватят блокировки в разном порядке
+		wallet, err := useWallet(tx, userID, "")
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Продленная бронь проверяется по тем же правилам, что и новая
+		extended := *booking
+		extended.PlannedEndsAt = newEndTime
+		var violation *models.PolicyViolation
+		err = models.CheckBookingPolicy(tx, bookingPolicy(), extended)
+		if errors.As(err, &violation) {
+			writePolicyViolation(w, violation)
+			return
+		}
+		if err != nil {
+			log.Printf("Booking policy check error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Следующий интервал на этом месте должен быть свободен
+		available, err := IsParkingSpotAvailable(tx, booking.ParkingSpot, booking.EndsAt, newEndTime)
+		if err != nil {
+			log.Printf("Error checking parking spot availability: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !available {
+			http.Error(w, "Parking spot is not available for the extension", http.StatusConflict)
+			return
+		}
+
+		endTime, err := models.ExtendBooking(tx, bookingID, newEndTime)
+		if errors.Is(err, models.ErrBookingConflict) {
+			http.Error(w, "Parking spot is not available for the extension", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Database update error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Стоимость пересчитывается по тарифу за весь продленный интервал; промокод брони
+		// применяется снова без проверки лимитов, ведь это то же применение
+		extended.PlannedEndsAt = endTime
+		promo, err := models.GetBookingPromoCode(tx, bookingID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		quote, err := priceBooking(tx, &extended, promo)
+		if err != nil {
+			log.Printf("Quote error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if promo != nil {
+			if err := models.SetRedemptionDiscount(tx, bookingID, quote.PromoDiscount); err != nil {
+				log.Printf("Database update error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := models.SetBookingPrice(tx, bookingID, *extended.Price, *extended.Currency); err != nil {
+			log.Printf("Database update error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		// Доплата за продление начисляется сразу: списывается с баланса, а если его нет,
+		// становится долгом, который гасится платежом через /api/me/payments
+		if booking.Price != nil && *extended.Price > *booking.Price {
+			surcharge := *extended.Price - *booking.Price
+			if wallet {
+				err = models.CheckBalance(tx, userID, surcharge, *extended.Currency)
+			}
+			if errors.As(err, &violation) {
+				writePolicyViolation(w, violation)
+				return
+			}
+			if err == nil {
+				err = models.PostCharge(tx, userID, bookingID, surcharge, *extended.Currency)
+			}
+			if err != nil {
+				log.Printf("Ledger charge error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Booking %d extended by %v until %v", bookingID, extension, endTime)
+
+		json.NewEncoder(w).Encode(BookingResponse{
+			ID:          bookingID,
+			ParkingSpot: booking.ParkingSpot,
+			ReservedAt:  booking.ReservedAt,
+			StartsAt:    booking.ReservedAt,
+			EndTime:     endTime,
+			Price:       *extended.Price,
+			Currency:    *extended.Currency,
+			Status:      booking.Status,
+			Message:     "Booking extended successfully",
+		})
+	}
+}
+
+// CheckOutMyBooking фиксирует досрочный выезд владельца и сразу освобождает место
+func CheckOutMyBooking(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		bookingID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		booking, err := models.LockUserBooking(tx, bookingID, userID)
+		if errors.Is(err, models.ErrBookingNotFound) {
+			http.Error(w, "Booking not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		if now.Before(booking.ReservedAt) {
+			http.Error(w, "Booking has not started yet, cancel it instead", http.StatusConflict)
+			return
+		}
+		if !now.Before(booking.EndsAt) {
+			http.Error(w, "Booking has already ended", http.StatusConflict)
+			return
+		}
+
+		err = models.CheckOutBooking(tx, bookingID)
+		if errors.Is(err, models.ErrInvalidTransition) {
+			http.Error(w, "Booking cannot be checked out in its current status", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Database update error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		booking, err = models.GetBooking(tx, bookingID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Booking %d checked out at %v", bookingID, booking.EndsAt)
+		offerReleasedSpots(db, booking.ParkingSpot)
+
+		json.NewEncoder(w).Encode(newMyBooking(*booking, now))
+	}
+}
+
+// CheckInMyBooking регистрирует прибытие владельца брони
+func CheckInMyBooking(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		bookingID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		booking, err := models.LockUserBooking(tx, bookingID, userID)
+		if errors.Is(err, models.ErrBookingNotFound) {
+			http.Error(w, "Booking not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		if now.Before(booking.ReservedAt.Add(-config.Booking.CheckInEarly)) {
+			http.Error(w, "Check-in is not open yet for this booking", http.StatusConflict)
+			return
+		}
+		if !now.Before(booking.EndsAt) {
+			http.Error(w, "Booking has already ended", http.StatusConflict)
+			return
+		}
+
+		err = models.CheckInBooking(tx, bookingID)
+		if errors.Is(err, models.ErrInvalidTransition) {
+			http.Error(w, "Booking cannot be checked in in its current status", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Database update error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		booking, err = models.GetBooking(tx, bookingID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Booking %d checked in", bookingID)
+
+		json.NewEncoder(w).Encode(newMyBooking(*booking, now))
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"server/models"
+	"server/testutil"
+)
+
+func TestCancelMyBooking(t *testing.T) {
+	tests := []struct {
+		name        string
+		startsIn    time.Duration
+		wantCode    int
+		wantMessage string
+		wantStatus  models.BookingStatus
+	}{
+		{
+			name: "started booking points to check-out", startsIn: -30 * time.Minute,
+			wantCode: http.StatusConflict, wantMessage: "/check-out", wantStatus: models.StatusActive,
+		},
+		{
+			name: "booking inside the cut-off is kept", startsIn: 5 * time.Minute,
+			wantCode: http.StatusConflict, wantMessage: "cut-off", wantStatus: models.StatusActive,
+		},
+		{
+			name: "booking before the cut-off is cancelled", startsIn: 2 * time.Hour,
+			wantCode: http.StatusOK, wantStatus: models.StatusCancelled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			userID := testutil.CreateUser(t, db, "driver@example.com")
+			start := time.Now().Add(tt.startsIn)
+			bookingID, err := models.CreateBooking(db, &models.Booking{
+				UserID: userID, ParkingSpot: 1, CarNumber: "AA123BB",
+				ReservedAt: start, PlannedEndsAt: start.Add(time.Hour),
+			})
+			if err != nil {
+				t.Fatalf("create booking: %v", err)
+			}
+
+			router := mux.NewRouter()
+			router.HandleFunc("/api/me/bookings/{id}", CancelMyBooking(db)).Methods("DELETE")
+			r := httptest.NewRequest("DELETE", fmt.Sprintf("/api/me/bookings/%d", bookingID), nil)
+			authorize(t, r, userID)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantMessage) {
+				t.Errorf("response %q does not mention %q", w.Body, tt.wantMessage)
+			}
+			booking, err := models.GetBooking(db, bookingID)
+			if err != nil {
+				t.Fatalf("get booking: %v", err)
+			}
+			if booking.Status != tt.wantStatus {
+				t.Errorf("booking is %s, want %s", booking.Status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,604 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"io"
+	"log"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/payments"
+	"server/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Сколько последних операций журнала возвращается в выписке
+const ledgerStatementLength = 100
+
+// Максимальная длина описания ручной корректировки
+const maxAdjustmentDescriptionLength = 200
+
+var (
+	// errPaymentMismatch — уведомление провайдера не совпадает с платежом по сумме или валюте
+	errPaymentMismatch = errors.New("payment callback does not match the payment")
+	// errPaymentsUnavailable — платежный провайдер не настроен
+	errPaymentsUnavailable = errors.New("payment provider is not configured")
+)
+
+// holdUntilPaid оставляет платную бронь в статусе pending до подтверждения оплаты, а бесплатную
+// делает активной. Статус задается заново при каждом вызове: при подборе места одна и та же бронь
+// пересчитывается для следующего места, и там она может оказаться бесплатной
+func holdUntilPaid(booking *models.Booking) {
+	if booking.Price != nil && *booking.Price > 0 {
+		booking.Status = models.StatusPending
+	} else {
+		booking.Status = models.StatusActive
+	}
+}
+
+// startPayment создает один платеж за брони, ожидающие оплаты. Если платить не за что, возвращает nil
+func startPayment(tx *sql.Tx, userID int, bookings []models.Booking) (*models.Payment, error) {
+	var amount int64
+	currency := config.Booking.Currency
+	bookingIDs := []int{}
+	for _, booking := range bookings {
+		if booking.Status != models.StatusPending || booking.Price == nil || *booking.Price == 0 {
+			continue
+		}
+		amount += *booking.Price
+		currency = *booking.Currency
+		bookingIDs = append(bookingIDs, booking.ID)
+	}
+	if amount == 0 {
+		return nil, nil
+	}
+	return createPayment(tx, userID, amount, currency, bookingIDs)
+}
+
+// createPayment сохраняет платеж и регистрирует его у провайдера
+func createPayment(tx *sql.Tx, userID int, amount int64, currency string, bookingIDs []int) (*models.Payment, error) {
+	provider := payments.Current()
+	if provider == nil {
+		return nil, errPaymentsUnavailable
+	}
+	payment := &models.Payment{
+		UserID:    userID,
+		Provider:  provider.Name(),
+		Amount:    amount,
+		Currency:  currency,
+		ExpiresAt: time.Now().Add(config.Payments.Timeout),
+	}
+	if _, err := models.CreatePayment(tx, payment, bookingIDs); err != nil {
+		return nil, err
+	}
+
+	checkout, err := provider.CreatePayment(payment.ID, amount, currency, fmt.Sprintf("Parking payment %d", payment.ID))
+	if err != nil {
+		return nil, err
+	}
+	if err := models.SetPaymentCheckout(tx, payment.ID, checkout.Reference, checkout.ConfirmationURL); err != nil {
+		return nil, err
+	}
+	payment.Reference = &checkout.Reference
+	if checkout.ConfirmationURL != "" {
+		payment.ConfirmationURL = &checkout.ConfirmationURL
+	}
+	return payment, nil
+}
+
+// applyPaymentResult применяет результат платежа. Успешный платеж зачисляется пользователю,
+// его ожидающие брони активируются и начисляются; деньги за брони, отмененные до оплаты,
+// сразу возвращаются. Неуспешный платеж отменяет брони. Повторное уведомление ничего не меняет
+func applyPaymentResult(db *sql.DB, provider payments.Provider, callback *payments.Callback) (*models.Payment, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	payment, err := models.LockPaymentByReference(tx, provider.Name(), callback.Reference)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status == models.PaymentSucceeded || payment.Status == models.PaymentFailed {
+		return payment, nil
+	}
+
+	if !callback.Succeeded {
+		if payment.Status == models.PaymentExpired {
+			return payment, nil
+		}
+		if err := models.SetPaymentStatus(tx, payment.ID, models.PaymentFailed); err != nil {
+			return nil, err
+		}
+		released, err := models.CancelUnpaidBookings(tx, payment.ID, "Payment failed")
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		payment.Status = models.PaymentFailed
+		offerReleasedSpots(db, released...)
+		return payment, nil
+	}
+
+	if callback.Amount != payment.Amount || callback.Currency != payment.Currency {
+		return nil, errPaymentMismatch
+	}
+	if err := models.SetPaymentStatus(tx, payment.ID, models.PaymentSucceeded); err != nil {
+		return nil, err
+	}
+	if err := models.PostPayment(tx, *payment); err != nil {
+		return nil, err
+	}
+
+	bookings, err := models.LockPaymentBookings(tx, payment.ID)
+	if err != nil {
+		return nil, err
+	}
+	var unused int64
+	for _, booking := range bookings {
+		price, currency := bookingPrice(&booking)
+		if booking.Status != models.StatusPending {
+			unused += price
+			continue
+		}
+		if err := models.TransitionBookingStatus(tx, booking.ID, models.StatusActive); err != nil {
+			return nil, err
+		}
+		if err := models.PostCharge(tx, booking.UserID, booking.ID, price, currency); err != nil {
+			return nil, err
+		}
+	}
+
+	// Место уже освобождено (например, платеж пришел после отмены брони) — деньги возвращаются
+	// через провайдера после фиксации транзакции
+	var refundIDs []int
+	if unused > 0 {
+		if err := models.AddPaymentRefund(tx, payment.ID, unused); err != nil {
+			return nil, err
+		}
+		if err := models.PostRefund(tx, *payment, unused, nil, nil); err != nil {
+			return nil, err
+		}
+		refundID, err := models.QueueProviderRefund(tx, *payment, nil, unused)
+		if err != nil {
+			return nil, err
+		}
+		refundIDs = append(refundIDs, refundID)
+		log.Printf("Payment %d: %d returned for bookings cancelled before payment", payment.ID, unused)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	payment.Status = models.PaymentSucceeded
+	payment.RefundedAmount += unused
+	sendProviderRefunds(db, refundIDs)
+	return payment, nil
+}
+
+// PaymentCallback принимает уведомление провайдера о результате платежа
+func PaymentCallback(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		provider := payments.Current()
+		if provider == nil || mux.Vars(r)["provider"] != provider.Name() {
+			http.Error(w, "Unknown payment provider", http.StatusNotFound)
+			return
+		}
+
+		callback, err := provider.ParseCallback(r)
+		if err != nil {
+			log.Printf("Payment callback rejected: %v", err)
+			http.Error(w, "Invalid payment callback", http.StatusBadRequest)
+			return
+		}
+
+		payment, err := applyPaymentResult(db, provider, callback)
+		if errors.Is(err, models.ErrPaymentNotFound) {
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, errPaymentMismatch) {
+			log.Printf("Payment callback for %s does not match the payment: %+v", callback.Reference, callback)
+			http.Error(w, "Payment amount does not match", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Payment callback error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Payment %d is %s", payment.ID, payment.Status)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":     payment.ID,
+			"status": payment.Status,
+		})
+	}
+}
+
+// FakeCheckout — подтверждение оплаты у локального провайдера для разработки и тестов.
+// GET возвращает платеж вызывающего пользователя, POST с {"status": "succeeded"} или "failed"
+// сообщает его результат, как это сделал бы провайдер. Маршрут подключается только
+// при PAYMENT_FAKE_CHECKOUT=true
+func FakeCheckout(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		provider := payments.Current()
+		if provider == nil || provider.Name() != payments.FakeProviderName {
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		}
+		payment, err := models.GetPaymentByReference(db, provider.Name(), mux.Vars(r)["reference"])
+		// Чужой платеж не отличается от несуществующего
+		if errors.Is(err, models.ErrPaymentNotFound) || (err == nil && payment.UserID != userID) {
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			var req struct {
+				Status string `json:"status"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if req.Status != "succeeded" && req.Status != "failed" {
+				http.Error(w, "Status must be succeeded or failed", http.StatusBadRequest)
+				return
+			}
+			payment, err = applyPaymentResult(db, provider, &payments.Callback{
+				Reference: *payment.Reference,
+				Succeeded: req.Status == "succeeded",
+				Amount:    payment.Amount,
+				Currency:  payment.Currency,
+			})
+			if err != nil {
+				log.Printf("Fake payment error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		json.NewEncoder(w).Encode(payment)
+	}
+}
+
+// GetMyPayments возвращает платежи вызывающего пользователя
+func GetMyPayments(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		list, err := models.ListUserPayments(db, userID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"payments": list,
+		})
+	}
+}
+
+// PayMyBalance создает платеж на сумму долга вызывающего пользователя
+// или, если указана amount, на пополнение его баланса
+func PayMyBalance(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		// Тело запроса необязательно: без него оплачивается долг
+		var req struct {
+			Amount *int64 `json:"amount"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Amount != nil && *req.Amount <= 0 {
+			http.Error(w, "Amount must be greater than 0", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		currency := config.Booking.Currency
+		amount := int64(0)
+		if req.Amount != nil {
+			amount = *req.Amount
+		} else {
+			balance, err := models.UserBalance(tx, userID, currency)
+			if err != nil {
+				log.Printf("Database query error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if balance >= 0 {
+				http.Error(w, "There is nothing to pay", http.StatusConflict)
+				return
+			}
+			amount = -balance
+		}
+
+		payment, err := createPayment(tx, userID, amount, currency, nil)
+		if errors.Is(err, errPaymentsUnavailable) {
+			http.Error(w, "Payments are not available", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			log.Printf("Create payment error: %v", err)
+			http.Error(w, "Error while creating payment", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(payment)
+	}
+}
+
+// AdminGetUserLedger возвращает баланс пользователя и выписку по нему
+func AdminGetUserLedger(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		userID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		writeWallet(w, db, userID)
+	}
+}
+
+// AdminCreateAdjustment вручную корректирует баланс пользователя:
+// положительная сумма зачисляется ему, отрицательная списывается
+func AdminCreateAdjustment(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		adminID, _ := userIDFromClaims(claims)
+
+		userID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Amount      int64  `json:"amount"`
+			Description string `json:"description"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid adjustment data", http.StatusBadRequest)
+			return
+		}
+		req.Description = strings.TrimSpace(req.Description)
+		if req.Amount == 0 {
+			http.Error(w, "Amount must not be zero", http.StatusBadRequest)
+			return
+		}
+		if req.Description == "" || len(req.Description) > maxAdjustmentDescriptionLength {
+			http.Error(w, "Description must be between 1 and 200 characters", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		transaction, err := models.PostAdjustment(tx, userID, req.Amount, config.Booking.Currency, req.Description, adminID)
+		if errors.Is(err, models.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Ledger adjustment error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Admin %d adjusted balance of user %d by %d", adminID, userID, req.Amount)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(transaction)
+	}
+}
+
+// AdminRefundPayment возвращает часть или всю сумму подтвержденного платежа.
+// Если указана бронь этого платежа, возврат сторнирует ее начисление. Провайдеру возврат
+// отправляется после фиксации; если он его не примет, попытку повторит фоновый процесс
+func AdminRefundPayment(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		adminID, _ := userIDFromClaims(claims)
+
+		paymentID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Amount    int64 `json:"amount"`
+			BookingID *int  `json:"bookingId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid refund data", http.StatusBadRequest)
+			return
+		}
+		if req.Amount <= 0 {
+			http.Error(w, "Amount must be greater than 0", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		payment, err := models.LockPayment(tx, paymentID)
+		if errors.Is(err, models.ErrPaymentNotFound) {
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if payment.Status != models.PaymentSucceeded {
+			http.Error(w, "Only succeeded payments can be refunded", http.StatusConflict)
+			return
+		}
+		if req.BookingID != nil {
+			booking, err := models.GetBooking(tx, *req.BookingID)
+			if errors.Is(err, models.ErrBookingNotFound) || (err == nil && (booking.PaymentID == nil || *booking.PaymentID != payment.ID)) {
+				http.Error(w, "Booking was not paid by this payment", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				log.Printf("Database query error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		err = models.AddPaymentRefund(tx, payment.ID, req.Amount)
+		if errors.Is(err, models.ErrRefundExceedsPayment) {
+			http.Error(w, "Refund exceeds the paid amount", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Database update error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := models.PostRefund(tx, *payment, req.Amount, req.BookingID, &adminID); err != nil {
+			log.Printf("Ledger refund error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		refundID, err := models.QueueProviderRefund(tx, *payment, req.BookingID, req.Amount)
+		if err != nil {
+			log.Printf("Database insert error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Admin %d refunded %d of payment %d", adminID, req.Amount, payment.ID)
+		sendProviderRefunds(db, []int{refundID})
+
+		payment.RefundedAmount += req.Amount
+		json.NewEncoder(w).Encode(payment)
+	}
+}
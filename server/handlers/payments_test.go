@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"server/models"
+	"server/payments"
+	"server/testutil"
+)
+
+// Секрет подписи уведомлений локального провайдера в тестах
+const testCallbackSecret = "test-secret"
+
+// initFakeProvider выбирает локального провайдера на время теста
+func initFakeProvider(t *testing.T) {
+	t.Helper()
+	if err := payments.Init(payments.FakeProviderName, testCallbackSecret); err != nil {
+		t.Fatalf("init payments: %v", err)
+	}
+	t.Cleanup(func() { payments.Init("", "") })
+}
+
+// createUnpaidBooking создает бронь места spot за 10000 копеек, ожидающую оплаты, и ее платеж
+func createUnpaidBooking(t *testing.T, db *sql.DB, userID, spot int) (int, *models.Payment) {
+	t.Helper()
+	price, currency := int64(10000), "RUB"
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	bookingID, err := models.CreateBooking(db, &models.Booking{
+		UserID: userID, ParkingSpot: spot, CarNumber: "AA123BB",
+		ReservedAt: start, PlannedEndsAt: start.Add(time.Hour),
+		Status: models.StatusPending, Price: &price, Currency: &currency,
+	})
+	if err != nil {
+		t.Fatalf("create booking: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer tx.Rollback()
+	payment, err := createPayment(tx, userID, price, currency, []int{bookingID})
+	if err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	return bookingID, payment
+}
+
+// sendCallback отправляет уведомление локального провайдера, подписанное секретом secret
+func sendCallback(db *sql.DB, secret, body string) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/payments/{provider}/callback", PaymentCallback(db)).Methods("POST")
+
+	r := httptest.NewRequest("POST", "/api/payments/fake/callback", strings.NewReader(body))
+	r.Header.Set(payments.FakeSignatureHeader, payments.NewFakeProvider(secret).Sign([]byte(body)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestPaymentCallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      string
+		amount      int64
+		secret      string
+		wantCode    int
+		wantBooking models.BookingStatus
+		wantPayment models.PaymentStatus
+		wantCharged int64
+	}{
+		{
+			name: "succeeded payment activates the booking", status: "succeeded", amount: 10000, secret: testCallbackSecret,
+			wantCode: http.StatusOK, wantBooking: models.StatusActive, wantPayment: models.PaymentSucceeded, wantCharged: 10000,
+		},
+		{
+			name: "failed payment cancels the booking", status: "failed", amount: 10000, secret: testCallbackSecret,
+			wantCode: http.StatusOK, wantBooking: models.StatusCancelled, wantPayment: models.PaymentFailed,
+		},
+		{
+			name: "forged signature is rejected", status: "succeeded", amount: 10000, secret: "forged-secret",
+			wantCode: http.StatusBadRequest, wantBooking: models.StatusPending, wantPayment: models.PaymentPending,
+		},
+		{
+			name: "amount mismatch is rejected", status: "succeeded", amount: 100, secret: testCallbackSecret,
+			wantCode: http.StatusBadRequest, wantBooking: models.StatusPending, wantPayment: models.PaymentPending,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			initFakeProvider(t)
+			userID := testutil.CreateUser(t, db, "payer@example.com")
+			bookingID, payment := createUnpaidBooking(t, db, userID, 1)
+
+			body := fmt.Sprintf(`{"reference":%q,"status":%q,"amount":%d,"currency":"RUB"}`, *payment.Reference, tt.status, tt.amount)
+			if w := sendCallback(db, tt.secret, body); w.Code != tt.wantCode {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+
+			booking, err := models.GetBooking(db, bookingID)
+			if err != nil {
+				t.Fatalf("get booking: %v", err)
+			}
+			if booking.Status != tt.wantBooking {
+				t.Errorf("booking is %s, want %s", booking.Status, tt.wantBooking)
+			}
+			stored, err := models.GetPaymentByReference(db, payments.FakeProviderName, *payment.Reference)
+			if err != nil {
+				t.Fatalf("get payment: %v", err)
+			}
+			if stored.Status != tt.wantPayment {
+				t.Errorf("payment is %s, want %s", stored.Status, tt.wantPayment)
+			}
+			charged, err := models.BookingCharged(db, bookingID)
+			if err != nil {
+				t.Fatalf("booking charged: %v", err)
+			}
+			if charged != tt.wantCharged {
+				t.Errorf("booking charged %d, want %d", charged, tt.wantCharged)
+			}
+		})
+	}
+}
+
+func TestPaymentCallbackIsIdempotent(t *testing.T) {
+	db := testutil.OpenDB(t)
+	initFakeProvider(t)
+	userID := testutil.CreateUser(t, db, "payer@example.com")
+	bookingID, payment := createUnpaidBooking(t, db, userID, 1)
+
+	body := fmt.Sprintf(`{"reference":%q,"status":"succeeded","amount":10000,"currency":"RUB"}`, *payment.Reference)
+	for i := 0; i < 2; i++ {
+		if w := sendCallback(db, testCallbackSecret, body); w.Code != http.StatusOK {
+			t.Fatalf("callback %d: got status %d: %s", i+1, w.Code, w.Body)
+		}
+	}
+
+	charged, err := models.BookingCharged(db, bookingID)
+	if err != nil {
+		t.Fatalf("booking charged: %v", err)
+	}
+	if charged != 10000 {
+		t.Fatalf("booking charged %d after a repeated callback, want 10000", charged)
+	}
+	balance, err := models.UserBalance(db, userID, "RUB")
+	if err != nil {
+		t.Fatalf("user balance: %v", err)
+	}
+	if balance != 0 {
+		t.Fatalf("balance is %d, want 0", balance)
+	}
+}
+
+func TestLatePaymentIsRefundedAfterCommit(t *testing.T) {
+	db := testutil.OpenDB(t)
+	initFakeProvider(t)
+	userID := testutil.CreateUser(t, db, "payer@example.com")
+	bookingID, payment := createUnpaidBooking(t, db, userID, 1)
+
+	// Платеж истек и бронь отменена, но провайдер все же подтвердил оплату
+	if _, err := db.Exec(`UPDATE payments SET expires_at = NOW() - INTERVAL '1 minute' WHERE id = $1`, payment.ID); err != nil {
+		t.Fatalf("expire payment: %v", err)
+	}
+	if _, err := models.ExpirePayments(db); err != nil {
+		t.Fatalf("expire payments: %v", err)
+	}
+
+	body := fmt.Sprintf(`{"reference":%q,"status":"succeeded","amount":10000,"currency":"RUB"}`, *payment.Reference)
+	if w := sendCallback(db, testCallbackSecret, body); w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+
+	booking, err := models.GetBooking(db, bookingID)
+	if err != nil {
+		t.Fatalf("get booking: %v", err)
+	}
+	if booking.Status != models.StatusCancelled {
+		t.Fatalf("booking is %s, want cancelled", booking.Status)
+	}
+
+	var status string
+	var amount int64
+	err = db.QueryRow(`SELECT status, amount FROM provider_refunds WHERE payment_id = $1`, payment.ID).Scan(&status, &amount)
+	if err != nil {
+		t.Fatalf("provider refund: %v", err)
+	}
+	if status != models.ProviderRefundSucceeded || amount != 10000 {
+		t.Fatalf("provider refund is %s for %d, want succeeded for 10000", status, amount)
+	}
+}
+
+func TestWithoutProviderBookingsArePaidFromWallet(t *testing.T) {
+	if err := payments.Init("", ""); err != nil {
+		t.Fatalf("init payments: %v", err)
+	}
+	if message := checkPaymentMethod(PaymentMethodProvider); message == "" {
+		t.Fatal("provider payment method accepted without a provider")
+	}
+	if message := checkPaymentMethod(PaymentMethodWallet); message != "" {
+		t.Fatalf("wallet payment method rejected: %s", message)
+	}
+
+	db := testutil.OpenDB(t)
+	userID := testutil.CreateUser(t, db, "payer@example.com")
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer tx.Rollback()
+	wallet, err := useWallet(tx, userID, "")
+	if err != nil {
+		t.Fatalf("useWallet: %v", err)
+	}
+	if !wallet {
+		t.Fatal("booking without a provider is not paid from the wallet")
+	}
+	if _, err := createPayment(tx, userID, 10000, "RUB", nil); err != errPaymentsUnavailable {
+		t.Fatalf("createPayment without a provider: %v, want errPaymentsUnavailable", err)
+	}
+}
@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TariffRequest — данные тарифа для создания или замены; суммы в копейках
+type TariffRequest struct {
+	Name        string              `json:"name"`
+	LotID       *int                `json:"lotId"`
+	SpotFeature *string             `json:"spotFeature"`
+	HourlyRate  int64               `json:"hourlyRate"`
+	DailyCap    *int64              `json:"dailyCap"`
+	FreeMinutes int                 `json:"freeMinutes"`
+	Active      *bool               `json:"active"`
+	Rates       []models.TariffRate `json:"rates"`
+}
+
+// Максимальная длина названия тарифа
+const maxTariffNameLength = 100
+
+// priceBooking считает стоимость брони по тарифу ее места и промокоду (nil — без промокода)
+// и записывает ее в бронь
+func priceBooking(db models.Queryer, booking *models.Booking, promo *models.PromoCode) (*models.Quote, error) {
+	quote, err := models.QuoteBooking(db, booking.UserID, booking.ParkingSpot, booking.ReservedAt,
+		booking.PlannedEndsAt, config.Booking.Location, config.Booking.Currency, promo)
+	if err != nil {
+		return nil, err
+	}
+	booking.Price = &quote.Amount
+	booking.Currency = &quote.Currency
+	return quote, nil
+}
+
+// bookingPrice возвращает стоимость брони для ответа клиенту; у старых броней ее нет
+func bookingPrice(booking *models.Booking) (int64, string) {
+	if booking.Price == nil || booking.Currency == nil {
+		return 0, config.Booking.Currency
+	}
+	return *booking.Price, *booking.Currency
+}
+
+// parseOptionalTime читает необязательное время в формате RFC 3339; пустое значение — nil
+func parseOptionalTime(value string) (*time.Time, bool) {
+	if value == "" {
+		return nil, true
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, false
+	}
+	return &parsed, true
+}
+
+// newTariff проверяет запрос и собирает тариф; при ошибке возвращает текст для клиента
+func newTariff(db models.Queryer, req TariffRequest) (*models.Tariff, string, error) {
+	tariff := &models.Tariff{
+		Name:        strings.TrimSpace(req.Name),
+		LotID:       req.LotID,
+		SpotFeature: req.SpotFeature,
+		HourlyRate:  req.HourlyRate,
+		DailyCap:    req.DailyCap,
+		FreeMinutes: req.FreeMinutes,
+		Active:      req.Active == nil || *req.Active,
+		Rates:       req.Rates,
+	}
+	if tariff.Rates == nil {
+		tariff.Rates = []models.TariffRate{}
+	}
+	if tariff.Name == "" || len(tariff.Name) > maxTariffNameLength {
+		return nil, "Tariff name must be between 1 and 100 characters", nil
+	}
+	if tariff.HourlyRate < 0 || tariff.FreeMinutes < 0 || (tariff.DailyCap != nil && *tariff.DailyCap < 0) {
+		return nil, "Rates, caps and free minutes must not be negative", nil
+	}
+	if tariff.SpotFeature != nil && !models.IsValidFeature(*tariff.SpotFeature) {
+		return nil, "Unknown spot feature: " + *tariff.SpotFeature, nil
+	}
+	if tariff.LotID != nil {
+		_, err := models.GetLot(db, *tariff.LotID)
+		if errors.Is(err, models.ErrLotNotFound) {
+			return nil, "Lot not found", nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	for _, rate := range tariff.Rates {
+		if len(rate.Weekdays) == 0 {
+			return nil, "Each rate needs at least one weekday", nil
+		}
+		for _, day := range rate.Weekdays {
+			if day < 1 || day > 7 {
+				return nil, "Weekdays must be between 1 (Monday) and 7 (Sunday)", nil
+			}
+		}
+		start, err := models.ClockMinutes(rate.StartsAt)
+		if err != nil {
+			return nil, "Invalid rate startsAt, expected HH:MM", nil
+		}
+		end, err := models.ClockMinutes(rate.EndsAt)
+		if err != nil {
+			return nil, "Invalid rate endsAt, expected HH:MM", nil
+		}
+		if start >= end {
+			return nil, "Rate startsAt must be before endsAt", nil
+		}
+		if rate.HourlyRate < 0 {
+			return nil, "Rates, caps and free minutes must not be negative", nil
+		}
+	}
+	return tariff, "", nil
+}
+
+// GetQuote считает стоимость брони до ее создания. Место задается ?parkingSpot=;
+// без него считается тариф парковки ?lotId= для места с характеристиками ?features=.
+// Окно задается так же, как при бронировании: ?startsAt=&endsAt= или ?hours=; ?promoCode= применяет промокод
+func GetQuote(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		startsAt, ok := parseOptionalTime(query.Get("startsAt"))
+		if !ok {
+			http.Error(w, "Invalid startsAt date", http.StatusBadRequest)
+			return
+		}
+		endsAt, ok := parseOptionalTime(query.Get("endsAt"))
+		if !ok {
+			http.Error(w, "Invalid endsAt date", http.StatusBadRequest)
+			return
+		}
+		hours := 0
+		if value := query.Get("hours"); value != "" {
+			if hours, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "Invalid hours", http.StatusBadRequest)
+				return
+			}
+		}
+		// Окно выравнивается по часовому поясу парковки; неверные номера отклоняются ниже
+		locSpot, _ := strconv.Atoi(query.Get("parkingSpot"))
+		locLot, _ := parseLotID(query.Get("lotId"))
+		loc, err := bookingLocation(db, locSpot, &locLot)
+		if errors.Is(err, models.ErrSpotNotFound) {
+			http.Error(w, "Parking spot not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrLotNotFound) {
+			http.Error(w, "Lot not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		start, end, message := resolveBookingWindow(startsAt, endsAt, hours, time.Now(), loc)
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+
+		var violation *models.PolicyViolation
+		promo, err := findPromoCode(db, query.Get("promoCode"), userID)
+		if errors.As(err, &violation) {
+			writePolicyViolation(w, violation)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		var quote *models.Quote
+		if value := query.Get("parkingSpot"); value != "" {
+			// err объявлен выше: ошибка расчета ниже должна дойти до общей обработки
+			var spotNumber int
+			spotNumber, err = strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "Invalid parking spot number", http.StatusBadRequest)
+				return
+			}
+			quote, err = models.QuoteBooking(db, userID, spotNumber, start, end, config.Booking.Location, config.Booking.Currency, promo)
+			if errors.Is(err, models.ErrSpotNotFound) {
+				http.Error(w, "Parking spot not found", http.StatusNotFound)
+				return
+			}
+		} else {
+			lotID, ok := parseLotID(query.Get("lotId"))
+			if !ok || lotID == 0 {
+				http.Error(w, "parkingSpot or lotId is required", http.StatusBadRequest)
+				return
+			}
+			features := parseFeatures(query.Get("features"))
+			if message := checkFeatures(features); message != "" {
+				http.Error(w, message, http.StatusBadRequest)
+				return
+			}
+			quote, err = models.QuoteLot(db, userID, lotID, features, start, end, config.Booking.Location, config.Booking.Currency, promo)
+			if errors.Is(err, models.ErrLotNotFound) {
+				http.Error(w, "Lot not found", http.StatusNotFound)
+				return
+			}
+		}
+		if errors.As(err, &violation) {
+			writePolicyViolation(w, violation)
+			return
+		}
+		if err != nil {
+			log.Printf("Quote error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"startsAt": start,
+			"endsAt":   end,
+			"quote":    quote,
+		})
+	}
+}
+
+// AdminGetTariffs возвращает все тарифы
+func AdminGetTariffs(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		tariffs, err := models.ListTariffs(db)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"tariffs": tariffs,
+		})
+	}
+}
+
+// AdminSaveTariff создает тариф (POST) или заменяет существующий (PUT /{id})
+func AdminSaveTariff(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		tariffID := 0
+		if value, ok := mux.Vars(r)["id"]; ok {
+			if tariffID, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "Invalid tariff ID", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var req TariffRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid tariff data", http.StatusBadRequest)
+			return
+		}
+		tariff, message, err := newTariff(db, req)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		status := http.StatusOK
+		if tariffID == 0 {
+			tariffID, err = models.CreateTariff(tx, tariff)
+			status = http.StatusCreated
+		} else {
+			tariff.ID = tariffID
+			err = models.UpdateTariff(tx, tariff)
+		}
+		if errors.Is(err, models.ErrTariffNotFound) {
+			http.Error(w, "Tariff not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrTariffExists) {
+			http.Error(w, "Tariff with this name already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Save tariff error: %v", err)
+			http.Error(w, "Error while saving tariff", http.StatusInternalServerError)
+			return
+		}
+
+		saved, err := models.GetTariff(tx, tariffID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(saved)
+	}
+}
+
+// AdminDeleteTariff удаляет тариф; уже созданные брони сохраняют свою стоимость
+func AdminDeleteTariff(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		tariffID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid tariff ID", http.StatusBadRequest)
+			return
+		}
+
+		err = models.DeleteTariff(db, tariffID)
+		if errors.Is(err, models.ErrTariffNotFound) {
+			http.Error(w, "Tariff not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Delete tariff error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Tariff deleted successfully",
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"regexp"
+	"server/config"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Код промокода: латинские буквы, цифры, дефис и подчеркивание
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,40}$`)
+
+// Максимальная длина описания промокода
+const maxPromoDescriptionLength = 200
+
+// PromoCodeRequest — данные промокода для создания или замены; суммы в копейках
+type PromoCodeRequest struct {
+	Code            string     `json:"code"`
+	Description     string     `json:"description"`
+	DiscountPercent *int       `json:"discountPercent"`
+	DiscountAmount  *int64     `json:"discountAmount"`
+	ValidFrom       *time.Time `json:"validFrom"`
+	ValidUntil      *time.Time `json:"validUntil"`
+	MaxUses         *int       `json:"maxUses"`
+	MaxUsesPerUser  *int       `json:"maxUsesPerUser"`
+	LotIDs          []int      `json:"lotIds"`
+	SpotFeatures    []string   `json:"spotFeatures"`
+	Active          *bool      `json:"active"`
+}
+
+// unknownPromoCode — нарушение для кода, которого нет
+func unknownPromoCode(code string) *models.PolicyViolation {
+	return &models.PolicyViolation{
+		Code:    models.PolicyPromoCodeInvalid,
+		Message: "Promo code " + code + " does not exist",
+	}
+}
+
+// lookupPromoCode находит промокод по коду клиента. Пустой код — nil;
+// неизвестный код возвращается как *models.PolicyViolation
+func lookupPromoCode(db models.Queryer, code string) (*models.PromoCode, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return nil, nil
+	}
+	promo, err := models.GetPromoCodeByCode(db, code)
+	if errors.Is(err, models.ErrPromoCodeNotFound) {
+		return nil, unknownPromoCode(code)
+	}
+	return promo, err
+}
+
+// findPromoCode находит промокод клиента и проверяет, что пользователь может его применить
+func findPromoCode(db models.Queryer, code string, userID int) (*models.PromoCode, error) {
+	promo, err := lookupPromoCode(db, code)
+	if promo == nil || err != nil {
+		return nil, err
+	}
+	if err := promo.Check(db, userID, time.Now()); err != nil {
+		return nil, err
+	}
+	return promo, nil
+}
+
+// usePromoCode делает то же, что findPromoCode, но в транзакции брони: применения промокода
+// блокируются до ее конца, чтобы параллельные брони не превысили лимиты
+func usePromoCode(tx *sql.Tx, code string, userID int) (*models.PromoCode, error) {
+	promo, err := lookupPromoCode(tx, code)
+	if promo == nil || err != nil {
+		return nil, err
+	}
+	if err := models.LockPromoCodeUses(tx, promo.ID); err != nil {
+		return nil, err
+	}
+	if err := promo.Check(tx, userID, time.Now()); err != nil {
+		return nil, err
+	}
+	return promo, nil
+}
+
+// newPromoCode проверяет запрос и собирает промокод; при ошибке возвращает текст для клиента
+func newPromoCode(db models.Queryer, req PromoCodeRequest) (*models.PromoCode, string, error) {
+	promo := &models.PromoCode{
+		Code:            strings.ToUpper(strings.TrimSpace(req.Code)),
+		Description:     strings.TrimSpace(req.Description),
+		DiscountPercent: req.DiscountPercent,
+		DiscountAmount:  req.DiscountAmount,
+		ValidFrom:       req.ValidFrom,
+		ValidUntil:      req.ValidUntil,
+		MaxUses:         req.MaxUses,
+		MaxUsesPerUser:  req.MaxUsesPerUser,
+		LotIDs:          req.LotIDs,
+		SpotFeatures:    req.SpotFeatures,
+		Active:          req.Active == nil || *req.Active,
+	}
+	if promo.LotIDs == nil {
+		promo.LotIDs = []int{}
+	}
+	if promo.SpotFeatures == nil {
+		promo.SpotFeatures = []string{}
+	}
+
+	if !promoCodePattern.MatchString(promo.Code) {
+		return nil, "Code must be 3 to 40 latin letters, digits, dashes or underscores", nil
+	}
+	if len(promo.Description) > maxPromoDescriptionLength {
+		return nil, "Description must be at most 200 characters", nil
+	}
+	if (promo.DiscountPercent == nil) == (promo.DiscountAmount == nil) {
+		return nil, "Specify either discountPercent or discountAmount", nil
+	}
+	if promo.DiscountPercent != nil && (*promo.DiscountPercent <= 0 || *promo.DiscountPercent > 100) {
+		return nil, "Discount percent must be between 1 and 100", nil
+	}
+	if promo.DiscountAmount != nil && *promo.DiscountAmount <= 0 {
+		return nil, "Discount amount must be greater than 0", nil
+	}
+	if promo.ValidFrom != nil && promo.ValidUntil != nil && !promo.ValidFrom.Before(*promo.ValidUntil) {
+		return nil, "validFrom must be before validUntil", nil
+	}
+	if (promo.MaxUses != nil && *promo.MaxUses <= 0) || (promo.MaxUsesPerUser != nil && *promo.MaxUsesPerUser <= 0) {
+		return nil, "Usage limits must be greater than 0", nil
+	}
+	if message := checkFeatures(promo.SpotFeatures); message != "" {
+		return nil, message, nil
+	}
+	for _, lotID := range promo.LotIDs {
+		_, err := models.GetLot(db, lotID)
+		if errors.Is(err, models.ErrLotNotFound) {
+			return nil, "Lot not found: " + strconv.Itoa(lotID), nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return promo, "", nil
+}
+
+// AdminGetPromoCodes возвращает все промокоды с числом применений
+func AdminGetPromoCodes(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		promos, err := models.ListPromoCodes(db)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"promoCodes": promos,
+		})
+	}
+}
+
+// AdminSavePromoCode создает промокод (POST) или заменяет существующий (PUT /{id})
+func AdminSavePromoCode(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		promoID := 0
+		if value, ok := mux.Vars(r)["id"]; ok {
+			if promoID, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "Invalid promo code ID", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var req PromoCodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid promo code data", http.StatusBadRequest)
+			return
+		}
+		promo, message, err := newPromoCode(db, req)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+
+		status := http.StatusOK
+		if promoID == 0 {
+			promoID, err = models.CreatePromoCode(db, promo)
+			status = http.StatusCreated
+		} else {
+			promo.ID = promoID
+			err = models.UpdatePromoCode(db, promo)
+		}
+		if errors.Is(err, models.ErrPromoCodeNotFound) {
+			http.Error(w, "Promo code not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrPromoCodeExists) {
+			http.Error(w, "Promo code already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Save promo code error: %v", err)
+			http.Error(w, "Error while saving promo code", http.StatusInternalServerError)
+			return
+		}
+
+		saved, err := models.GetPromoCode(db, promoID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(saved)
+	}
+}
+
+// AdminDeletePromoCode удаляет промокод, который еще ни разу не применяли;
+// примененный промокод вместо удаления деактивируется
+func AdminDeletePromoCode(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		promoID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid promo code ID", http.StatusBadRequest)
+			return
+		}
+
+		err = models.DeletePromoCode(db, promoID)
+		if errors.Is(err, models.ErrPromoCodeNotFound) {
+			http.Error(w, "Promo code not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrPromoCodeRedeemed) {
+			http.Error(w, "Promo code has been used; deactivate it instead", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Delete promo code error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Promo code deleted successfully",
+		})
+	}
+}
+
+// AdminGetPromoCodeStats возвращает статистику применений промокода по дням и последние применения
+func AdminGetPromoCodeStats(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		promoID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid promo code ID", http.StatusBadRequest)
+			return
+		}
+
+		promo, err := models.GetPromoCode(db, promoID)
+		if errors.Is(err, models.ErrPromoCodeNotFound) {
+			http.Error(w, "Promo code not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		stats, err := models.GetPromoCodeStats(db, promoID, config.Booking.Location)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"promoCode": promo,
+			"currency":  config.Booking.Currency,
+			"stats":     stats,
+		})
+	}
+}
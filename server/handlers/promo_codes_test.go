@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"server/models"
+	"server/testutil"
+	"server/utils"
+)
+
+// authorize добавляет к запросу токен пользователя
+func authorize(t *testing.T, r *http.Request, userID int) {
+	t.Helper()
+	token, err := utils.GenerateToken(userID, "user")
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+}
+
+// createPromoCode сохраняет промокод и возвращает его ID
+func createPromoCode(t *testing.T, db models.Queryer, promo models.PromoCode) int {
+	t.Helper()
+	promo.Active = true
+	id, err := models.CreatePromoCode(db, &promo)
+	if err != nil {
+		t.Fatalf("create promo code: %v", err)
+	}
+	return id
+}
+
+func TestGetQuoteWithPromoCode(t *testing.T) {
+	percent := 20
+	tests := []struct {
+		name      string
+		promo     models.PromoCode
+		query     string
+		wantCode  int
+		violation string
+	}{
+		{
+			name:     "applicable to the spot",
+			promo:    models.PromoCode{DiscountPercent: &percent},
+			query:    "parkingSpot=1&hours=1",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "spot lacks the required feature",
+			promo:     models.PromoCode{DiscountPercent: &percent, SpotFeatures: []string{"ev_charger"}},
+			query:     "parkingSpot=1&hours=1",
+			wantCode:  http.StatusUnprocessableEntity,
+			violation: models.PolicyPromoCodeNotApplicable,
+		},
+		{
+			name:      "lot is not listed",
+			promo:     models.PromoCode{DiscountPercent: &percent, LotIDs: []int{999}},
+			query:     "lotId=1&hours=1",
+			wantCode:  http.StatusUnprocessableEntity,
+			violation: models.PolicyPromoCodeNotApplicable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			userID := testutil.CreateUser(t, db, "quote@example.com")
+			promo := tt.promo
+			promo.Code = "SPRING"
+			createPromoCode(t, db, promo)
+
+			r := httptest.NewRequest("GET", "/api/quote?"+tt.query+"&promoCode=spring", nil)
+			authorize(t, r, userID)
+			w := httptest.NewRecorder()
+			GetQuote(db)(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.violation != "" {
+				var violation models.PolicyViolation
+				if err := json.NewDecoder(w.Body).Decode(&violation); err != nil {
+					t.Fatalf("decode violation: %v", err)
+				}
+				if violation.Code != tt.violation {
+					t.Fatalf("got violation %q, want %q", violation.Code, tt.violation)
+				}
+				return
+			}
+			var response struct {
+				Quote *models.Quote `json:"quote"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("decode quote: %v", err)
+			}
+			if response.Quote == nil || response.Quote.PromoCode != "SPRING" || response.Quote.PromoDiscount == 0 {
+				t.Fatalf("quote %+v does not apply the promo code", response.Quote)
+			}
+		})
+	}
+}
+
+func TestBookingPromoCodeMaxUsesUnderConcurrency(t *testing.T) {
+	db := testutil.OpenDB(t)
+	maxUses, percent := 2, 100
+	promoID := createPromoCode(t, db, models.PromoCode{Code: "FREE", DiscountPercent: &percent, MaxUses: &maxUses})
+
+	// Каждый пользователь бронирует свое место на свою машину, так что мешать друг другу
+	// могут только лимиты промокода
+	const attempts = 8
+	codes := make([]int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		userID := testutil.CreateUser(t, db, fmt.Sprintf("promo%d@example.com", i))
+		body := fmt.Sprintf(`{"parkingSpot":%d,"carNumber":"AA%03dBB","hours":1,"promoCode":"free"}`, i+1, 100+i)
+		r := httptest.NewRequest("POST", "/api/booking", strings.NewReader(body))
+		authorize(t, r, userID)
+
+		wg.Add(1)
+		go func(i int, r *http.Request) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			BookParkingSpot(db)(w, r)
+			codes[i] = w.Code
+		}(i, r)
+	}
+	wg.Wait()
+
+	booked := 0
+	for i, code := range codes {
+		switch code {
+		case http.StatusOK:
+			booked++
+		case http.StatusUnprocessableEntity:
+		default:
+			t.Errorf("booking %d: unexpected status %d", i, code)
+		}
+	}
+	if booked != maxUses {
+		t.Fatalf("%d bookings got the promo code, want %d", booked, maxUses)
+	}
+
+	var redemptions int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM promo_redemptions WHERE promo_code_id = $1`, promoID).Scan(&redemptions); err != nil {
+		t.Fatalf("count redemptions: %v", err)
+	}
+	if redemptions != maxUses {
+		t.Fatalf("%d redemptions recorded, want %d", redemptions, maxUses)
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"server/config"
+	"server/models"
+	"server/payments"
+	"time"
+)
+
+// refundPolicy возвращает правила возврата из текущих настроек
+func refundPolicy() models.RefundPolicy {
+	return models.RefundPolicy{
+		FullBefore:  config.Booking.RefundFullBefore,
+		LatePercent: config.Booking.RefundLatePercent,
+	}
+}
+
+// cancelWithRefund отменяет бронь и возвращает деньги по правилам отмены. Возврат сначала
+// идет через провайдера по платежу брони, а то, что провайдер вернуть не может, зачисляется
+// на баланс. Возврат через провайдера только ставится в очередь: после фиксации транзакции
+// его нужно отправить через sendRefunds. admin — отмена администратором: она всегда
+// возвращает всю стоимость
+func cancelWithRefund(tx *sql.Tx, booking *models.Booking, cancelledBy int, reason string, admin bool) (*models.Refund, error) {
+	if err := models.CancelBooking(tx, booking.ID, cancelledBy, reason); err != nil {
+		return nil, err
+	}
+
+	charged, err := models.BookingCharged(tx, booking.ID)
+	if err != nil {
+		return nil, err
+	}
+	refund := refundPolicy().Apply(charged, booking.ReservedAt, time.Now(), admin)
+	refund.BookingID = booking.ID
+	_, refund.Currency = bookingPrice(booking)
+	if err := models.SetBookingRefund(tx, booking.ID, refund); err != nil {
+		return nil, err
+	}
+	if refund.Amount == 0 {
+		return &refund, nil
+	}
+
+	var createdBy *int
+	if admin {
+		createdBy = &cancelledBy
+	}
+
+	var payment *models.Payment
+	if booking.PaymentID != nil {
+		payment, err = models.LockPayment(tx, *booking.PaymentID)
+		if err != nil {
+			return nil, err
+		}
+		if payment.Status == models.PaymentSucceeded && payment.Currency == refund.Currency {
+			refund.ProviderAmount = payment.Amount - payment.RefundedAmount
+			if refund.ProviderAmount > refund.Amount {
+				refund.ProviderAmount = refund.Amount
+			}
+		}
+	}
+	refund.WalletAmount = refund.Amount - refund.ProviderAmount
+
+	if refund.ProviderAmount > 0 {
+		refund.PaymentID = &payment.ID
+		if err := models.AddPaymentRefund(tx, payment.ID, refund.ProviderAmount); err != nil {
+			return nil, err
+		}
+		if err := models.PostRefund(tx, *payment, refund.ProviderAmount, &booking.ID, createdBy); err != nil {
+			return nil, err
+		}
+		// Провайдеру возврат уходит только после фиксации отмены, см. sendRefunds
+		refundID, err := models.QueueProviderRefund(tx, *payment, &booking.ID, refund.ProviderAmount)
+		if err != nil {
+			return nil, err
+		}
+		refund.ProviderRefundID = &refundID
+	}
+	if refund.WalletAmount > 0 {
+		err := models.PostBookingRefund(tx, booking.UserID, booking.ID, refund.WalletAmount, refund.Currency, createdBy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("Booking %d: refunded %d of %d by rule %s", booking.ID, refund.Amount, charged, refund.Rule)
+	return &refund, nil
+}
+
+// sendProviderRefunds отправляет провайдеру возвраты, записанные в уже зафиксированной транзакции.
+// Ошибки только логируются: неотправленные возвраты повторит фоновый процесс
+func sendProviderRefunds(db *sql.DB, refundIDs []int) {
+	_, failed, err := models.SendProviderRefunds(db, payments.SendRefund, refundIDs)
+	if err != nil {
+		log.Printf("Provider refund error: %v", err)
+		return
+	}
+	if failed > 0 {
+		log.Printf("Payment provider did not accept %d refunds, they will be retried", failed)
+	}
+}
+
+// sendRefunds отправляет провайдеру возвраты за брони, отмененные в уже зафиксированной транзакции
+func sendRefunds(db *sql.DB, refunds ...models.Refund) {
+	var refundIDs []int
+	for _, refund := range refunds {
+		if refund.ProviderRefundID != nil {
+			refundIDs = append(refundIDs, *refund.ProviderRefundID)
+		}
+	}
+	sendProviderRefunds(db, refundIDs)
+}
+
+// adminCancelBooking отменяет бронь от имени администратора с полным возвратом, после фиксации
+// отправляет возврат провайдеру и возвращает отмененную бронь вместе с возвратом
+func adminCancelBooking(db *sql.DB, bookingID, adminID int, reason string) (*models.Booking, *models.Refund, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	booking, err := models.LockBooking(tx, bookingID)
+	if err != nil {
+		return nil, nil, err
+	}
+	refund, err := cancelWithRefund(tx, booking, adminID, reason, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	sendRefunds(db, *refund)
+	return booking, refund, nil
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"server/models"
+	"server/testutil"
+)
+
+// cancelPaidBooking отменяет бронь администратором в транзакции и возвращает возврат и транзакцию
+func cancelPaidBooking(t *testing.T, db *sql.DB, bookingID, adminID int) (*models.Refund, *sql.Tx) {
+	t.Helper()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	booking, err := models.LockBooking(tx, bookingID)
+	if err != nil {
+		tx.Rollback()
+		t.Fatalf("lock booking: %v", err)
+	}
+	refund, err := cancelWithRefund(tx, booking, adminID, "Test", true)
+	if err != nil {
+		tx.Rollback()
+		t.Fatalf("cancelWithRefund: %v", err)
+	}
+	return refund, tx
+}
+
+func TestCancelWithRefundSendsProviderRefundAfterCommit(t *testing.T) {
+	db := testutil.OpenDB(t)
+	initFakeProvider(t)
+	userID := testutil.CreateUser(t, db, "payer@example.com")
+	bookingID, payment := createUnpaidBooking(t, db, userID, 1)
+	body := fmt.Sprintf(`{"reference":%q,"status":"succeeded","amount":10000,"currency":"RUB"}`, *payment.Reference)
+	if w := sendCallback(db, testCallbackSecret, body); w.Code != http.StatusOK {
+		t.Fatalf("pay booking: got status %d: %s", w.Code, w.Body)
+	}
+
+	queued := func() int {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM provider_refunds WHERE payment_id = $1`, payment.ID).Scan(&count); err != nil {
+			t.Fatalf("count provider refunds: %v", err)
+		}
+		return count
+	}
+
+	// Откат отмены не оставляет возврата: провайдер не вызывается внутри транзакции
+	refund, tx := cancelPaidBooking(t, db, bookingID, userID)
+	if refund.ProviderAmount != 10000 || refund.ProviderRefundID == nil {
+		t.Fatalf("refund %+v, want 10000 queued for the provider", refund)
+	}
+	if n := queued(); n != 0 {
+		t.Fatalf("%d provider refunds are visible before commit", n)
+	}
+	tx.Rollback()
+	if n := queued(); n != 0 {
+		t.Fatalf("%d provider refunds left after rollback", n)
+	}
+
+	refund, tx = cancelPaidBooking(t, db, bookingID, userID)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	sendRefunds(db, *refund)
+
+	sent, err := models.GetProviderRefund(db, *refund.ProviderRefundID)
+	if err != nil {
+		t.Fatalf("get provider refund: %v", err)
+	}
+	if sent.Status != models.ProviderRefundSucceeded || sent.Amount != 10000 || sent.BookingID == nil || *sent.BookingID != bookingID {
+		t.Fatalf("provider refund %+v, want succeeded for 10000 of booking %d", sent, bookingID)
+	}
+}
@@ -0,0 +1,637 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"time"
+)
+
+// SeriesRequest — запрос на создание повторяющейся брони
+type SeriesRequest struct {
+	ParkingSpot int    `json:"parkingSpot"`
+	CarNumber   string `json:"carNumber"`
+	// CarCountry — код страны регистрации CarNumber; по умолчанию Грузия
+	CarCountry string `json:"carCountry,omitempty"`
+	// Weekdays — дни недели по ISO 8601: 1 — понедельник, 7 — воскресенье
+	Weekdays   []int    `json:"weekdays"`
+	StartTime  string   `json:"startTime"`
+	EndTime    string   `json:"endTime"`
+	StartsOn   string   `json:"startsOn"`
+	EndsOn     string   `json:"endsOn"`
+	Exceptions []string `json:"exceptions"`
+	// PaymentMethod — wallet или provider; пустой способ выбирает баланс, если на нем есть средства
+	PaymentMethod string `json:"paymentMethod,omitempty"`
+}
+
+// SeriesConflict — вхождение серии, которое не удалось забронировать
+type SeriesConflict struct {
+	Date     string    `json:"date"`
+	StartsAt time.Time `json:"startsAt"`
+	EndTime  time.Time `json:"endTime"`
+	Reason   string    `json:"reason"`
+	// Code — код нарушенного правила бронирования, если вхождение отклонено политикой
+	Code string `json:"code,omitempty"`
+}
+
+// SeriesResponse — серия вместе с созданными из нее бронями
+type SeriesResponse struct {
+	Series    models.Series    `json:"series"`
+	Bookings  []MyBooking      `json:"bookings"`
+	Conflicts []SeriesConflict `json:"conflicts,omitempty"`
+	// Payment — платеж за платные вхождения; до его подтверждения они в статусе pending
+	Payment *models.Payment `json:"payment,omitempty"`
+}
+
+// validateSeriesRequest проверяет запрос и возвращает текст ошибки для клиента
+func validateSeriesRequest(req SeriesRequest, now time.Time) string {
+	if req.CarNumber == "" {
+		return "Car number is required"
+	}
+	if len(req.Weekdays) == 0 {
+		return "At least one weekday is required"
+	}
+	seen := map[int]bool{}
+	for _, day := range req.Weekdays {
+		if day < 1 || day > 7 || seen[day] {
+			return "Weekdays must be distinct numbers from 1 (Monday) to 7 (Sunday)"
+		}
+		seen[day] = true
+	}
+
+	startTime, err := time.Parse("15:04", req.StartTime)
+	if err != nil {
+		return "Invalid start time, expected HH:MM"
+	}
+	endTime, err := time.Parse("15:04", req.EndTime)
+	if err != nil {
+		return "Invalid end time, expected HH:MM"
+	}
+	duration := endTime.Sub(startTime)
+	if duration <= 0 {
+		return "End time must be after start time"
+	}
+	// Время серии задается по стенным часам, поэтому оно не округляется, а должно попадать на сетку слотов
+	if slot := config.Booking.SlotGranularity; slot > 0 && (sinceMidnight(startTime)%slot != 0 || sinceMidnight(endTime)%slot != 0) {
+		return unalignedSlotMessage()
+	}
+	if duration < config.Booking.MinDuration {
+		return "Booking is shorter than the minimum duration"
+	}
+	if duration > config.Booking.MaxDuration {
+		return "Booking exceeds the maximum duration"
+	}
+
+	loc := config.Booking.Location
+	startsOn, err := time.ParseInLocation(models.DateLayout, req.StartsOn, loc)
+	if err != nil {
+		return "Invalid startsOn date, expected YYYY-MM-DD"
+	}
+	endsOn, err := time.ParseInLocation(models.DateLayout, req.EndsOn, loc)
+	if err != nil {
+		return "Invalid endsOn date, expected YYYY-MM-DD"
+	}
+	if endsOn.Before(startsOn) {
+		return "endsOn must not be before startsOn"
+	}
+	if endsOn.AddDate(0, 0, 1).Before(now) {
+		return "Series has already ended"
+	}
+	if endsOn.Sub(now) > config.Booking.SeriesMaxLength {
+		return "Series exceeds the maximum length"
+	}
+	for _, date := range req.Exceptions {
+		if _, err := time.ParseInLocation(models.DateLayout, date, loc); err != nil {
+			return "Invalid exception date, expected YYYY-MM-DD"
+		}
+	}
+
+	return ""
+}
+
+// sinceMidnight возвращает время суток как смещение от полуночи
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// bookOccurrence бронирует одно вхождение серии внутри транзакции; с wallet вхождение сразу
+// оплачивается с баланса. Конфликт откатывается до точки сохранения, не прерывая остальную серию;
+// нарушение правил бронирования или нехватка баланса возвращается как *models.PolicyViolation
+func bookOccurrence(tx *sql.Tx, booking *models.Booking, wallet bool) (string, error) {
+	available, err := IsParkingSpotAvailable(tx, booking.ParkingSpot, booking.ReservedAt, booking.PlannedEndsAt)
+	if err != nil {
+		return "", err
+	}
+	if !available {
+		return "Parking spot is not available", nil
+	}
+	if message, err := checkSpotOpen(tx, booking.ParkingSpot, booking.ReservedAt, booking.PlannedEndsAt); err != nil || message != "" {
+		return message, err
+	}
+	if err := models.CheckBookingPolicy(tx, bookingPolicy(), *booking); err != nil {
+		return "", err
+	}
+
+	if _, err := priceBooking(tx, booking, nil); err != nil {
+		return "", err
+	}
+	holdUntilPaid(booking)
+
+	if _, err := tx.Exec("SAVEPOINT occurrence"); err != nil {
+		return "", err
+	}
+	booking.ID, err = models.CreateBooking(tx, booking)
+	if errors.Is(err, models.ErrBookingConflict) {
+		if _, err := tx.Exec("ROLLBACK TO SAVEPOINT occurrence"); err != nil {
+			return "", err
+		}
+		return "Parking spot is already booked", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if wallet {
+		var violation *models.PolicyViolation
+		err := settleFromWallet(tx, []*models.Booking{booking})
+		if errors.As(err, &violation) {
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT occurrence"); err != nil {
+				return "", err
+			}
+			return "", violation
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	_, err = tx.Exec("RELEASE SAVEPOINT occurrence")
+	return "", err
+}
+
+// CreateMySeries создает повторяющуюся бронь и сразу бронирует все ее вхождения.
+// Серия может выходить за горизонт обычного бронирования, но не дальше SeriesMaxLength
+func CreateMySeries(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		var req SeriesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid series data", http.StatusBadRequest)
+			return
+		}
+
+		carNumber, message := normalizeCarNumber(req.CarNumber, req.CarCountry)
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		req.CarNumber = carNumber
+
+		now := time.Now()
+		if message := validateSeriesRequest(req, now); message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		if message := checkPaymentMethod(req.PaymentMethod); message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		validSpot, err := isValidParkingSpot(db, req.ParkingSpot)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !validSpot {
+			http.Error(w, "Invalid parking spot number", http.StatusBadRequest)
+			return
+		}
+
+		series := models.Series{
+			UserID:      userID,
+			ParkingSpot: req.ParkingSpot,
+			CarNumber:   req.CarNumber,
+			Weekdays:    req.Weekdays,
+			StartTime:   req.StartTime,
+			EndTime:     req.EndTime,
+			StartsOn:    req.StartsOn,
+			EndsOn:      req.EndsOn,
+			Exceptions:  req.Exceptions,
+		}
+		// Вхождения считаются по стенным часам парковки, к которой относится место
+		loc, err := spotLocation(db, req.ParkingSpot)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		occurrences, err := series.Occurrences(loc, now)
+		if err != nil {
+			log.Printf("Series occurrences error: %v", err)
+			http.Error(w, "Invalid series data", http.StatusBadRequest)
+			return
+		}
+		if len(occurrences) == 0 {
+			http.Error(w, "Series has no upcoming occurrences", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		wallet, err := useWallet(tx, userID, req.PaymentMethod)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		seriesID, err := models.CreateSeries(tx, &series)
+		if err != nil {
+			log.Printf("Insert series error: %v", err)
+			http.Error(w, "Error while creating series", http.StatusInternalServerError)
+			return
+		}
+
+		response := SeriesResponse{Bookings: []MyBooking{}}
+		created := []models.Booking{}
+		for _, occurrence := range occurrences {
+			booking := models.Booking{
+				UserID:        userID,
+				ParkingSpot:   req.ParkingSpot,
+				CarNumber:     req.CarNumber,
+				ReservedAt:    occurrence.StartsAt,
+				PlannedEndsAt: occurrence.EndsAt,
+				SeriesID:      &seriesID,
+			}
+			reason, err := bookOccurrence(tx, &booking, wallet)
+			var violation *models.PolicyViolation
+			if errors.As(err, &violation) {
+				reason, err = violation.Message, nil
+			}
+			if err != nil {
+				log.Printf("Insert series booking error: %v", err)
+				http.Error(w, "Error while booking", http.StatusInternalServerError)
+				return
+			}
+			if reason != "" {
+				conflict := SeriesConflict{
+					Date:     occurrence.Date,
+					StartsAt: occurrence.StartsAt,
+					EndTime:  occurrence.EndsAt,
+					Reason:   reason,
+				}
+				if violation != nil {
+					conflict.Code = violation.Code
+				}
+				response.Conflicts = append(response.Conflicts, conflict)
+				continue
+			}
+			booking.EndsAt = occurrence.EndsAt
+			created = append(created, booking)
+		}
+
+		// Вхождения, не оплаченные с баланса, оплачиваются одним платежом
+		response.Payment, err = startPayment(tx, userID, created)
+		if err != nil {
+			log.Printf("Create payment error: %v", err)
+			http.Error(w, "Error while creating payment", http.StatusInternalServerError)
+			return
+		}
+		for _, booking := range created {
+			response.Bookings = append(response.Bookings, newMyBooking(booking, now))
+		}
+
+		saved, err := models.GetUserSeries(tx, seriesID, userID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		response.Series = *saved
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Series %d created: %d bookings, %d conflicts", seriesID, len(response.Bookings), len(response.Conflicts))
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// GetMySeries возвращает повторяющиеся брони пользователя
+func GetMySeries(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		seriesList, err := models.ListUserSeries(db, userID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"series": seriesList,
+		})
+	}
+}
+
+// GetMySeriesByID возвращает серию вместе с ее бронями
+func GetMySeriesByID(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		seriesID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid series ID", http.StatusBadRequest)
+			return
+		}
+
+		series, err := models.GetUserSeries(db, seriesID, userID)
+		if errors.Is(err, models.ErrSeriesNotFound) {
+			http.Error(w, "Series not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		bookings, err := models.ListSeriesBookings(db, seriesID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		response := SeriesResponse{Series: *series, Bookings: make([]MyBooking, 0, len(bookings))}
+		for _, booking := range bookings {
+			response.Bookings = append(response.Bookings, newMyBooking(booking, now))
+		}
+
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// SkipMySeriesOccurrence исключает одну дату из серии и отменяет бронь на эту дату, если до ее начала
+// больше config.Booking.CancelCutoff; иначе бронь остается и возвращается в keptBookings
+func SkipMySeriesOccurrence(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		vars := mux.Vars(r)
+		seriesID, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			http.Error(w, "Invalid series ID", http.StatusBadRequest)
+			return
+		}
+		date := vars["date"]
+		if _, err := time.Parse(models.DateLayout, date); err != nil {
+			http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		series, err := models.GetUserSeries(tx, seriesID, userID)
+		if errors.Is(err, models.ErrSeriesNotFound) {
+			http.Error(w, "Series not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := models.AddSeriesException(tx, seriesID, date); err != nil {
+			log.Printf("Insert series exception error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		loc, err := spotLocation(tx, series.ParkingSpot)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		cancelled, kept, refunds, err := cancelSeriesBookings(tx, seriesID, userID, "Occurrence skipped", func(booking models.Booking) bool {
+			return booking.ReservedAt.In(loc).Format(models.DateLayout) == date
+		})
+		if err != nil {
+			log.Printf("Cancel series booking error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		sendRefunds(db, refunds...)
+		if len(cancelled) > 0 {
+			offerReleasedSpots(db, series.ParkingSpot)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"date":              date,
+			"cancelledBookings": cancelled,
+			"keptBookings":      kept,
+			"refunds":           refunds,
+			"message":           "Occurrence skipped successfully",
+		})
+	}
+}
+
+// CancelMySeries отменяет серию и все ее брони, до начала которых больше config.Booking.CancelCutoff;
+// более близкие брони остаются и возвращаются в keptBookings
+func CancelMySeries(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		seriesID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid series ID", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		series, err := models.GetUserSeries(tx, seriesID, userID)
+		if errors.Is(err, models.ErrSeriesNotFound) {
+			http.Error(w, "Series not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if series.Status == models.SeriesCancelled {
+			http.Error(w, "Series is already cancelled", http.StatusConflict)
+			return
+		}
+
+		if err := models.SetSeriesStatus(tx, seriesID, models.SeriesCancelled); err != nil {
+			log.Printf("Database update error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		cancelled, kept, refunds, err := cancelSeriesBookings(tx, seriesID, userID, "Series cancelled", func(models.Booking) bool {
+			return true
+		})
+		if err != nil {
+			log.Printf("Cancel series booking error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Series %d cancelled, %d bookings released", seriesID, len(cancelled))
+		sendRefunds(db, refunds...)
+		if len(cancelled) > 0 {
+			offerReleasedSpots(db, series.ParkingSpot)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":                seriesID,
+			"cancelledBookings": cancelled,
+			"keptBookings":      kept,
+			"refunds":           refunds,
+			"message":           "Series cancelled successfully",
+		})
+	}
+}
+
+// cancelSeriesBookings отменяет еще не начавшиеся брони серии, подходящие под match, по правилам
+// возврата и возвращает их ID вместе с возвратами; возвраты через провайдера отправляются
+// после фиксации транзакции через sendRefunds. Брони, до начала которых осталось меньше
+// config.Booking.CancelCutoff, не отменяются, как и в DELETE /api/me/bookings/{id}: их ID возвращаются в kept
+func cancelSeriesBookings(tx *sql.Tx, seriesID, userID int, reason string, match func(models.Booking) bool) (cancelled, kept []int, refunds []models.Refund, err error) {
+	bookings, err := models.ListSeriesBookings(tx, seriesID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	now := time.Now()
+	cancelled = []int{}
+	kept = []int{}
+	refunds = []models.Refund{}
+	for i := range bookings {
+		booking := &bookings[i]
+		if !booking.Status.Occupies() || !now.Before(booking.ReservedAt) || !match(*booking) {
+			continue
+		}
+		if booking.ReservedAt.Sub(now) < config.Booking.CancelCutoff {
+			kept = append(kept, booking.ID)
+			continue
+		}
+		refund, err := cancelWithRefund(tx, booking, userID, reason, false)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		cancelled = append(cancelled, booking.ID)
+		refunds = append(refunds, *refund)
+	}
+
+	return cancelled, kept, refunds, nil
+}
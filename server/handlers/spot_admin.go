@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"strings"
+)
+
+// SpotRequest — данные места для добавления или изменения
+type SpotRequest struct {
+	// Number равен 0, если нужен следующий свободный номер
+	Number int    `json:"number"`
+	Label  string `json:"label"`
+	// LotID равен 0 для парковки по умолчанию; при изменении места не учитывается
+	LotID  int  `json:"lotId"`
+	ZoneID *int `json:"zoneId"`
+	// Features при изменении места равен nil, если характеристики не меняются
+	Features []string `json:"features"`
+}
+
+// Максимальная длина подписи места
+const maxSpotLabelLength = 20
+
+// checkSpotZone проверяет, что зона существует и относится к парковке места
+func checkSpotZone(db models.Queryer, zoneID *int, lotID int) (string, error) {
+	if zoneID == nil {
+		return "", nil
+	}
+	zone, err := models.GetZone(db, *zoneID)
+	if errors.Is(err, models.ErrZoneNotFound) {
+		return "Zone not found", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if zone.LotID != lotID {
+		return "Zone belongs to a different lot", nil
+	}
+	return "", nil
+}
+
+// GetSpots возвращает действующие места с подписями; ?lotId= ограничивает парковку
+func GetSpots(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if _, err := utils.GetAndValidateTokenClaims(r); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		lotID, ok := parseLotID(r.URL.Query().Get("lotId"))
+		if !ok {
+			http.Error(w, "Invalid lot ID", http.StatusBadRequest)
+			return
+		}
+
+		spots, err := models.ListSpots(db, lotID, false)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"spots": spots,
+		})
+	}
+}
+
+// AdminGetSpots возвращает все места, включая выведенные из эксплуатации
+func AdminGetSpots(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		lotID, ok := parseLotID(r.URL.Query().Get("lotId"))
+		if !ok {
+			http.Error(w, "Invalid lot ID", http.StatusBadRequest)
+			return
+		}
+
+		spots, err := models.ListSpots(db, lotID, true)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"spots": spots,
+		})
+	}
+}
+
+// AdminCreateSpot добавляет место на парковку
+func AdminCreateSpot(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		var req SpotRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid spot data", http.StatusBadRequest)
+			return
+		}
+		req.Label = strings.TrimSpace(req.Label)
+		if req.Number < 0 {
+			http.Error(w, "Spot number must be positive", http.StatusBadRequest)
+			return
+		}
+		if len(req.Label) > maxSpotLabelLength {
+			http.Error(w, "Spot label is too long", http.StatusBadRequest)
+			return
+		}
+		if message := checkFeatures(req.Features); message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		if req.LotID != 0 {
+			_, err := models.GetLot(db, req.LotID)
+			if errors.Is(err, models.ErrLotNotFound) {
+				http.Error(w, "Lot not found", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				log.Printf("Database query error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if req.ZoneID != nil {
+			if req.LotID == 0 {
+				http.Error(w, "lotId is required together with zoneId", http.StatusBadRequest)
+				return
+			}
+			message, err := checkSpotZone(db, req.ZoneID, req.LotID)
+			if err != nil {
+				log.Printf("Database query error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if message != "" {
+				http.Error(w, message, http.StatusBadRequest)
+				return
+			}
+		}
+
+		number, err := models.CreateSpot(db, &models.Spot{
+			Number:   req.Number,
+			Label:    req.Label,
+			LotID:    req.LotID,
+			ZoneID:   req.ZoneID,
+			Features: req.Features,
+		})
+		if errors.Is(err, models.ErrSpotExists) {
+			http.Error(w, "Spot with this number or label already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Insert spot error: %v", err)
+			http.Error(w, "Error while adding spot", http.StatusInternalServerError)
+			return
+		}
+
+		spot, err := models.GetSpot(db, number)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Spot %d (%s) added", spot.Number, spot.Label)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(spot)
+	}
+}
+
+// AdminUpdateSpot меняет подпись, зону и характеристики места
+func AdminUpdateSpot(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		spotNumber, err := strconv.Atoi(mux.Vars(r)["n"])
+		if err != nil {
+			http.Error(w, "Invalid parking spot number", http.StatusBadRequest)
+			return
+		}
+
+		var req SpotRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid spot data", http.StatusBadRequest)
+			return
+		}
+		req.Label = strings.TrimSpace(req.Label)
+		if req.Label == "" || len(req.Label) > maxSpotLabelLength {
+			http.Error(w, "Spot label must be between 1 and 20 characters", http.StatusBadRequest)
+			return
+		}
+		if message := checkFeatures(req.Features); message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+
+		current, err := models.GetSpot(db, spotNumber)
+		if errors.Is(err, models.ErrSpotNotFound) {
+			http.Error(w, "Parking spot not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		message, err := checkSpotZone(db, req.ZoneID, current.LotID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+
+		current.Label = req.Label
+		current.ZoneID = req.ZoneID
+		if req.Features != nil {
+			current.Features = req.Features
+		}
+		err = models.UpdateSpot(db, current)
+		if errors.Is(err, models.ErrSpotNotFound) {
+			http.Error(w, "Parking spot not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrSpotExists) {
+			http.Error(w, "Spot with this label already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Update spot error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		spot, err := models.GetSpot(db, spotNumber)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(spot)
+	}
+}
+
+// AdminRetireSpot выводит место из эксплуатации; брони и история места сохраняются
+func AdminRetireSpot(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		spotNumber, err := strconv.Atoi(mux.Vars(r)["n"])
+		if err != nil {
+			http.Error(w, "Invalid parking spot number", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		err = models.RetireSpot(tx, spotNumber)
+		if errors.Is(err, models.ErrSpotNotFound) {
+			http.Error(w, "Parking spot not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrSpotInUse) {
+			http.Error(w, "Spot has current or upcoming bookings; cancel them first", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Retire spot error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Spot %d retired", spotNumber)
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Spot retired successfully",
+		})
+	}
+}
+
+// AdminRestoreSpot возвращает выведенное место в эксплуатацию
+func AdminRestoreSpot(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		spotNumber, err := strconv.Atoi(mux.Vars(r)["n"])
+		if err != nil {
+			http.Error(w, "Invalid parking spot number", http.StatusBadRequest)
+			return
+		}
+
+		err = models.RestoreSpot(db, spotNumber)
+		if errors.Is(err, models.ErrSpotNotFound) {
+			http.Error(w, "Parking spot not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Restore spot error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Spot restored successfully",
+		})
+	}
+}
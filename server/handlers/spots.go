@@ -5,6 +5,7 @@ import (
     "encoding/json"
     "log"
     "net/http"
+    "server/models"
     "server/utils"
 
 )
@@ -25,33 +26,17 @@ func GetOccupiedSpots(db *sql.DB) http.HandlerFunc {
             return
         }
 
-        // Получаем занятые места
-        rows, err := db.Query(`
-            SELECT parking_spot
-            FROM bookings
-            WHERE reserved_at + (hours * interval '1 hour') > NOW()
-        `)
-        if err != nil {
-            log.Printf("Database query error: %v", err)
-            http.Error(w, "Database error", http.StatusInternalServerError)
+        lotID, ok := parseLotID(r.URL.Query().Get("lotId"))
+        if !ok {
+            http.Error(w, "Invalid lot ID", http.StatusBadRequest)
             return
         }
-        defer rows.Close()
-
-        occupiedSpots := []int{}
-        for rows.Next() {
-            var spot int
-            if err := rows.Scan(&spot); err != nil {
-                log.Printf("Error scanning row: %v", err)
-                http.Error(w, "Error reading data", http.StatusInternalServerError)
-                return
-            }
-            occupiedSpots = append(occupiedSpots, spot)
-        }
 
-        if err = rows.Err(); err != nil {
-            log.Printf("Error iterating rows: %v", err)
-            http.Error(w, "Error reading data", http.StatusInternalServerError)
+        // Получаем занятые места (с учетом статуса брони)
+        occupiedSpots, err := models.GetOccupiedParkingSpots(db, lotID)
+        if err != nil {
+            log.Printf("Database query error: %v", err)
+            http.Error(w, "Database error", http.StatusInternalServerError)
             return
         }
 
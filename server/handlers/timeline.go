@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"server/models"
+	"server/utils"
+	"strconv"
+)
+
+// GetSpotTimeline возвращает расписание места за окно ?from=&to=: брони, блокировки и свободные промежутки.
+// Не администратор видит владельца и номер машины только у своих броней
+func GetSpotTimeline(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+		isAdmin := isAdminFromClaims(claims)
+
+		spotNumber, err := strconv.Atoi(mux.Vars(r)["n"])
+		if err != nil {
+			http.Error(w, "Invalid parking spot number", http.StatusBadRequest)
+			return
+		}
+		// История выведенного из эксплуатации места тоже доступна
+		spot, err := models.GetSpot(db, spotNumber)
+		if errors.Is(err, models.ErrSpotNotFound) {
+			http.Error(w, "Parking spot not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		from, to, message := parseTimeWindow(r.URL.Query())
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+
+		timeline, err := models.GetSpotTimeline(db, spotNumber, from, to)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if !isAdmin {
+			for i, entry := range timeline {
+				if entry.UserID != nil && *entry.UserID != userID {
+					timeline[i].UserID = nil
+					timeline[i].CarNumber = ""
+				}
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"parkingSpot": spotNumber,
+			"label":       spot.Label,
+			"from":        from,
+			"to":          to,
+			"timeline":    timeline,
+		})
+	}
+}
@@ -29,6 +29,15 @@ func isAdminFromClaims(claims map[string]interface{}) bool {
     return true
 }
 
+// Функция для получения ID пользователя из токена
+func userIDFromClaims(claims map[string]interface{}) (int, bool) {
+    userID, ok := claims["sub"].(float64)
+    if !ok {
+        return 0, false
+    }
+    return int(userID), true
+}
+
 func GetAllUsers(db *sql.DB) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"strings"
+)
+
+// Максимальная длина названия группы пользователей
+const maxUserGroupNameLength = 30
+
+// AdminGetUserGroups возвращает группы пользователей
+func AdminGetUserGroups(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		groups, err := models.ListUserGroups(db)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"groups": groups,
+		})
+	}
+}
+
+// AdminPutUserGroup создает группу пользователей или меняет ее скидку и ежемесячную сумму на баланс
+func AdminPutUserGroup(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		group := models.UserGroup{Name: strings.TrimSpace(mux.Vars(r)["name"])}
+		if group.Name == "" || len(group.Name) > maxUserGroupNameLength {
+			http.Error(w, "Group name must be between 1 and 30 characters", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			DiscountPercent  int   `json:"discountPercent"`
+			MonthlyAllowance int64 `json:"monthlyAllowance"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid group data", http.StatusBadRequest)
+			return
+		}
+		if req.DiscountPercent < 0 || req.DiscountPercent > 100 {
+			http.Error(w, "Discount must be between 0 and 100 percent", http.StatusBadRequest)
+			return
+		}
+		if req.MonthlyAllowance < 0 {
+			http.Error(w, "Monthly allowance must not be negative", http.StatusBadRequest)
+			return
+		}
+		group.DiscountPercent = req.DiscountPercent
+		group.MonthlyAllowance = req.MonthlyAllowance
+
+		if err := models.SaveUserGroup(db, &group); err != nil {
+			log.Printf("Save user group error: %v", err)
+			http.Error(w, "Error while saving group", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(group)
+	}
+}
+
+// AdminDeleteUserGroup удаляет группу; ее пользователи остаются без группы
+func AdminDeleteUserGroup(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		err = models.DeleteUserGroup(db, mux.Vars(r)["name"])
+		if errors.Is(err, models.ErrUserGroupNotFound) {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Delete user group error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Group deleted successfully",
+		})
+	}
+}
+
+// AdminSetUserGroup включает пользователя в группу; null исключает его из группы
+func AdminSetUserGroup(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		userID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Group *string `json:"group"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		err = models.SetUserGroup(db, userID, req.Group)
+		if errors.Is(err, models.ErrUserGroupNotFound) {
+			http.Error(w, "Group not found", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, models.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Update user group error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(AdminResponse{
+			Success: true,
+			Message: "User group updated successfully",
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"strings"
+)
+
+// VehicleRequest — данные машины для регистрации или изменения
+type VehicleRequest struct {
+	Plate string `json:"plate"`
+	// Country — код страны регистрации; по умолчанию Грузия
+	Country   string `json:"country"`
+	Make      string `json:"make"`
+	Model     string `json:"model"`
+	Colour    string `json:"colour"`
+	SizeClass string `json:"sizeClass"`
+	IsEV      bool   `json:"isEv"`
+}
+
+// Ограничения длины описательных полей машины
+const (
+	maxVehicleMakeLength   = 50
+	maxVehicleColourLength = 30
+)
+
+// newVehicle проверяет запрос и собирает машину; при ошибке возвращает текст для клиента
+func newVehicle(req VehicleRequest, userID int) (*models.Vehicle, string) {
+	plate, country, err := utils.NormalizePlate(req.Plate, req.Country)
+	if err != nil {
+		return nil, "Invalid licence plate for the country of registration"
+	}
+
+	vehicle := &models.Vehicle{
+		UserID:    userID,
+		Plate:     plate,
+		Country:   country,
+		Make:      strings.TrimSpace(req.Make),
+		Model:     strings.TrimSpace(req.Model),
+		Colour:    strings.TrimSpace(req.Colour),
+		SizeClass: req.SizeClass,
+		IsEV:      req.IsEV,
+	}
+	if vehicle.SizeClass == "" {
+		vehicle.SizeClass = models.SizeStandard
+	}
+	if !models.IsValidSizeClass(vehicle.SizeClass) {
+		return nil, "Size class must be one of: small, standard, large"
+	}
+	if len(vehicle.Make) > maxVehicleMakeLength || len(vehicle.Model) > maxVehicleMakeLength ||
+		len(vehicle.Colour) > maxVehicleColourLength {
+		return nil, "Vehicle description is too long"
+	}
+	return vehicle, ""
+}
+
+// normalizeCarNumber нормализует введенный вручную номер и проверяет его формат так же,
+// как при регистрации машины; при ошибке возвращает текст для клиента
+func normalizeCarNumber(carNumber, country string) (string, string) {
+	if utils.CleanPlate(carNumber) == "" {
+		return "", "Car number is required"
+	}
+	plate, _, err := utils.NormalizePlate(carNumber, country)
+	if err != nil {
+		return "", "Invalid licence plate for the country of registration"
+	}
+	return plate, ""
+}
+
+// resolveCarNumber возвращает номер машины для брони: из реестра, если передан vehicleID,
+// иначе введенный вручную номер страны country в нормализованном виде
+func resolveCarNumber(db models.Queryer, userID int, vehicleID *int, carNumber, country string) (string, string, error) {
+	if vehicleID != nil {
+		vehicle, err := models.GetUserVehicle(db, *vehicleID, userID)
+		if errors.Is(err, models.ErrVehicleNotFound) {
+			return "", "Vehicle not found", nil
+		}
+		if err != nil {
+			return "", "", err
+		}
+		return vehicle.Plate, "", nil
+	}
+
+	carNumber, message := normalizeCarNumber(carNumber, country)
+	return carNumber, message, nil
+}
+
+// GetMyVehicles возвращает машины текущего пользователя
+func GetMyVehicles(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		vehicles, err := models.ListUserVehicles(db, userID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"vehicles": vehicles,
+		})
+	}
+}
+
+// CreateMyVehicle регистрирует машину текущего пользователя
+func CreateMyVehicle(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		var req VehicleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid vehicle data", http.StatusBadRequest)
+			return
+		}
+
+		vehicle, message := newVehicle(req, userID)
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+
+		vehicle.ID, err = models.CreateVehicle(db, vehicle)
+		if errors.Is(err, models.ErrVehicleExists) {
+			http.Error(w, "Vehicle with this plate is already registered", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Insert vehicle error: %v", err)
+			http.Error(w, "Error while saving vehicle", http.StatusInternalServerError)
+			return
+		}
+
+		created, err := models.GetUserVehicle(db, vehicle.ID, userID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+// GetMyVehicle возвращает одну машину текущего пользователя
+func GetMyVehicle(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		vehicleID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid vehicle ID", http.StatusBadRequest)
+			return
+		}
+
+		vehicle, err := models.GetUserVehicle(db, vehicleID, userID)
+		if errors.Is(err, models.ErrVehicleNotFound) {
+			http.Error(w, "Vehicle not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(vehicle)
+	}
+}
+
+// UpdateMyVehicle заменяет данные машины текущего пользователя
+func UpdateMyVehicle(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		vehicleID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid vehicle ID", http.StatusBadRequest)
+			return
+		}
+
+		var req VehicleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid vehicle data", http.StatusBadRequest)
+			return
+		}
+
+		vehicle, message := newVehicle(req, userID)
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		vehicle.ID = vehicleID
+
+		err = models.UpdateVehicle(db, vehicle)
+		if errors.Is(err, models.ErrVehicleNotFound) {
+			http.Error(w, "Vehicle not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrVehicleExists) {
+			http.Error(w, "Vehicle with this plate is already registered", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Update vehicle error: %v", err)
+			http.Error(w, "Error while saving vehicle", http.StatusInternalServerError)
+			return
+		}
+
+		updated, err := models.GetUserVehicle(db, vehicleID, userID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(updated)
+	}
+}
+
+// DeleteMyVehicle удаляет машину текущего пользователя
+func DeleteMyVehicle(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		vehicleID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid vehicle ID", http.StatusBadRequest)
+			return
+		}
+
+		err = models.DeleteVehicle(db, vehicleID, userID)
+		if errors.Is(err, models.ErrVehicleNotFound) {
+			http.Error(w, "Vehicle not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Delete vehicle error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Vehicle deleted successfully",
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import "testing"
+
+func TestNormalizeCarNumber(t *testing.T) {
+	tests := []struct {
+		name        string
+		carNumber   string
+		country     string
+		want        string
+		wantMessage string
+	}{
+		{name: "georgian plate with separators", carNumber: " aa-123-bb ", want: "AA123BB"},
+		{name: "old georgian format", carNumber: "abc 123", country: "ge", want: "ABC123"},
+		{name: "generic format of another country", carNumber: "B-MW 1234", country: "DE", want: "BMW1234"},
+		{name: "empty", carNumber: " - ", wantMessage: "Car number is required"},
+		{name: "not a georgian plate", carNumber: "A123BC77", wantMessage: "Invalid licence plate for the country of registration"},
+		{name: "no digits", carNumber: "ABCDEF", country: "DE", wantMessage: "Invalid licence plate for the country of registration"},
+		{name: "invalid country", carNumber: "AA123BB", country: "GEO", wantMessage: "Invalid licence plate for the country of registration"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, message := normalizeCarNumber(tt.carNumber, tt.country)
+			if got != tt.want || message != tt.wantMessage {
+				t.Fatalf("normalizeCarNumber = %q, %q; want %q, %q", got, message, tt.want, tt.wantMessage)
+			}
+		})
+	}
+}
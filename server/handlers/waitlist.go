@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"time"
+)
+
+// WaitlistRequest — запрос на постановку в лист ожидания
+type WaitlistRequest struct {
+	// ParkingSpot равен 0, если подходит любое место
+	ParkingSpot int        `json:"parkingSpot"`
+	CarNumber   string     `json:"carNumber"`
+	Hours       int        `json:"hours,omitempty"`
+	StartsAt    *time.Time `json:"startsAt,omitempty"`
+	EndsAt      *time.Time `json:"endsAt,omitempty"`
+	// LotID ограничивает ожидание одной парковкой; 0 — любая
+	LotID *int `json:"lotId,omitempty"`
+	// CarCountry — код страны регистрации CarNumber; по умолчанию Грузия
+	CarCountry string `json:"carCountry,omitempty"`
+}
+
+// offerReleasedSpots предлагает освободившиеся места листу ожидания.
+// Вызывается после фиксации транзакции, поэтому ошибки только логируются
+func offerReleasedSpots(db *sql.DB, spots ...int) {
+	if len(spots) == 0 {
+		return
+	}
+	offered, err := models.FillWaitlist(db, spots, config.Booking.WaitlistOfferTTL, config.Booking.Location)
+	if err != nil {
+		log.Printf("Waitlist offer error: %v", err)
+		return
+	}
+	for _, entry := range offered {
+		log.Printf("Waitlist entry %d offered booking %d", entry.ID, *entry.BookingID)
+	}
+}
+
+// JoinWaitlist ставит пользователя в очередь на место или на любое место в заданном интервале
+func JoinWaitlist(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		var req WaitlistRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid waitlist data", http.StatusBadRequest)
+			return
+		}
+
+		if req.ParkingSpot != 0 {
+			validSpot, err := isValidParkingSpot(db, req.ParkingSpot)
+			if err != nil {
+				log.Printf("Database query error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !validSpot {
+				http.Error(w, "Invalid parking spot number", http.StatusBadRequest)
+				return
+			}
+			message, err := checkSpotInLot(db, req.ParkingSpot, req.LotID)
+			if err != nil {
+				log.Printf("Database query error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if message != "" {
+				http.Error(w, message, http.StatusBadRequest)
+				return
+			}
+		}
+		if req.LotID != nil {
+			_, err := models.GetLot(db, *req.LotID)
+			if errors.Is(err, models.ErrLotNotFound) {
+				http.Error(w, "Lot not found", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				log.Printf("Database query error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		carNumber, message := normalizeCarNumber(req.CarNumber, req.CarCountry)
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		req.CarNumber = carNumber
+
+		loc, err := bookingLocation(db, req.ParkingSpot, req.LotID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		startsAt, endsAt, message := resolveBookingWindow(req.StartsAt, req.EndsAt, req.Hours, time.Now(), loc)
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		// Остальные лимиты проверяются при принятии предложения, когда известна итоговая бронь
+		var violation *models.PolicyViolation
+		if errors.As(bookingPolicy().CheckDuration(endsAt.Sub(startsAt)), &violation) {
+			writePolicyViolation(w, violation)
+			return
+		}
+		if req.ParkingSpot != 0 {
+			message, err := checkSpotOpen(db, req.ParkingSpot, startsAt, endsAt)
+			if err != nil {
+				log.Printf("Database query error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if message != "" {
+				http.Error(w, message, http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		entry := models.WaitlistEntry{
+			UserID:    userID,
+			CarNumber: req.CarNumber,
+			StartsAt:  startsAt,
+			EndsAt:    endsAt,
+			LotID:     req.LotID,
+		}
+		if req.ParkingSpot != 0 {
+			entry.ParkingSpot = &req.ParkingSpot
+		}
+
+		entryID, err := models.CreateWaitlistEntry(db, &entry)
+		if err != nil {
+			log.Printf("Insert waitlist entry error: %v", err)
+			http.Error(w, "Error while joining the waitlist", http.StatusInternalServerError)
+			return
+		}
+
+		// Если место уже свободно, предложение появится сразу
+		var spots []int
+		if entry.ParkingSpot != nil {
+			spots = []int{*entry.ParkingSpot}
+		}
+		if _, err := models.FillWaitlist(db, spots, config.Booking.WaitlistOfferTTL, config.Booking.Location); err != nil {
+			log.Printf("Waitlist offer error: %v", err)
+		}
+
+		created, err := models.GetUserWaitlistEntry(db, entryID, userID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("User %d joined the waitlist, entry %d", userID, entryID)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+// GetMyWaitlist возвращает записи пользователя в листе ожидания вместе с текущими предложениями
+func GetMyWaitlist(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		entries, err := models.ListUserWaitlist(db, userID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"waitlist": entries,
+		})
+	}
+}
+
+// AcceptWaitlistOffer подтверждает предложенное место до истечения срока предложения
+func AcceptWaitlistOffer(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		entryID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid waitlist entry ID", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		entry, err := models.LockUserWaitlistEntry(tx, entryID, userID)
+		if errors.Is(err, models.ErrWaitlistEntryNotFound) {
+			http.Error(w, "Waitlist entry not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		if entry.Status != models.WaitlistOffered || entry.BookingID == nil {
+			http.Error(w, "There is no open offer for this waitlist entry", http.StatusConflict)
+			return
+		}
+		if entry.OfferExpiresAt != nil && !now.Before(*entry.OfferExpiresAt) {
+			http.Error(w, "Waitlist offer has expired", http.StatusConflict)
+			return
+		}
+
+		hold, err := models.GetBooking(tx, *entry.BookingID)
+		if errors.Is(err, models.ErrBookingNotFound) {
+			http.Error(w, "Offered booking is no longer available", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		// Баланс блокируется до проверки правил, как при создании брони: иначе встречные
+		// запросы одного пользователя захватят блокировки в разном порядке
+		wallet, err := useWallet(tx, userID, "")
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		var violation *models.PolicyViolation
+		err = models.CheckBookingPolicy(tx, bookingPolicy(), *hold)
+		if errors.As(err, &violation) {
+			writePolicyViolation(w, violation)
+			return
+		}
+		if err != nil {
+			log.Printf("Booking policy check error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Цена фиксируется в момент принятия предложения
+		if _, err := priceBooking(tx, hold, nil); err != nil {
+			log.Printf("Quote error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := models.SetBookingPrice(tx, hold.ID, *hold.Price, *hold.Currency); err != nil {
+			log.Printf("Database update error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Платная бронь оплачивается с баланса или остается удержанной до подтверждения оплаты
+		var payment *models.Payment
+		if wallet {
+			err = settleFromWallet(tx, []*models.Booking{hold})
+		} else {
+			payment, err = startPayment(tx, userID, []models.Booking{*hold})
+		}
+		if errors.As(err, &violation) {
+			writePolicyViolation(w, violation)
+			return
+		}
+		if err != nil {
+			log.Printf("Booking payment error: %v", err)
+			http.Error(w, "Error while creating payment", http.StatusInternalServerError)
+			return
+		}
+		if payment == nil && hold.Status == models.StatusPending {
+			err = models.TransitionBookingStatus(tx, *entry.BookingID, models.StatusActive)
+		}
+		if errors.Is(err, models.ErrBookingNotFound) || errors.Is(err, models.ErrInvalidTransition) {
+			http.Error(w, "Offered booking is no longer available", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Database update error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := models.SetWaitlistStatus(tx, entryID, models.WaitlistAccepted); err != nil {
+			log.Printf("Database update error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		booking, err := models.GetBooking(tx, *entry.BookingID)
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Waitlist entry %d accepted, booking %d is %s", entryID, booking.ID, booking.Status)
+
+		price, currency := bookingPrice(booking)
+		message := "Booking successful!"
+		if payment != nil {
+			message = "Booking is awaiting payment"
+		}
+		json.NewEncoder(w).Encode(BookingResponse{
+			ID:          booking.ID,
+			ParkingSpot: booking.ParkingSpot,
+			ReservedAt:  booking.ReservedAt,
+			StartsAt:    booking.ReservedAt,
+			EndTime:     booking.EndsAt,
+			Price:       price,
+			Currency:    currency,
+			Status:      booking.Status,
+			Payment:     payment,
+			Message:     message,
+		})
+	}
+}
+
+// LeaveWaitlist убирает запись из очереди; открытое предложение при этом отклоняется
+// и место передается следующему в очереди
+func LeaveWaitlist(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		entryID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid waitlist entry ID", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		entry, err := models.LockUserWaitlistEntry(tx, entryID, userID)
+		if errors.Is(err, models.ErrWaitlistEntryNotFound) {
+			http.Error(w, "Waitlist entry not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database query error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if entry.Status != models.WaitlistWaiting && entry.Status != models.WaitlistOffered {
+			http.Error(w, "Waitlist entry is already closed", http.StatusConflict)
+			return
+		}
+
+		var releasedSpot int
+		if entry.Status == models.WaitlistOffered {
+			releasedSpot, err = models.ReleaseWaitlistHold(tx, *entry, "Waitlist offer declined")
+			if err != nil {
+				log.Printf("Release waitlist hold error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := models.SetWaitlistStatus(tx, entryID, models.WaitlistCancelled); err != nil {
+			log.Printf("Database update error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		if releasedSpot != 0 {
+			offerReleasedSpots(db, releasedSpot)
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Left the waitlist successfully",
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/payments"
+	"server/utils"
+	"strconv"
+	"strings"
+)
+
+// Способы оплаты брони; пустой способ выбирает баланс, если на нем есть средства
+const (
+	PaymentMethodWallet   = "wallet"
+	PaymentMethodProvider = "provider"
+)
+
+// Максимальная длина описания зачисления на баланс
+const maxGrantDescriptionLength = 200
+
+// checkPaymentMethod проверяет способ оплаты и возвращает текст ошибки для клиента
+func checkPaymentMethod(method string) string {
+	switch method {
+	case "", PaymentMethodWallet:
+		return ""
+	case PaymentMethodProvider:
+		if payments.Current() == nil {
+			return "Payments through a provider are not available"
+		}
+		return ""
+	}
+	return "Payment method must be wallet or provider"
+}
+
+// useWallet блокирует баланс пользователя и решает, оплачивать ли брони с него.
+// Без платежного провайдера брони всегда оплачиваются с баланса
+func useWallet(tx *sql.Tx, userID int, method string) (bool, error) {
+	if err := models.LockWallet(tx, userID); err != nil {
+		return false, err
+	}
+	switch {
+	case method == PaymentMethodWallet || payments.Current() == nil:
+		return true, nil
+	case method == PaymentMethodProvider:
+		return false, nil
+	}
+	balance, err := models.UserBalance(tx, userID, config.Booking.Currency)
+	return balance > 0, err
+}
+
+// settleFromWallet оплачивает с баланса пользователя брони, ожидающие оплаты: они сразу
+// становятся активными. Если баланса не хватает на все брони, возвращает *models.PolicyViolation
+func settleFromWallet(tx *sql.Tx, bookings []*models.Booking) error {
+	var amount int64
+	unpaid := []*models.Booking{}
+	for _, booking := range bookings {
+		if booking.Status != models.StatusPending || booking.Price == nil || *booking.Price == 0 {
+			continue
+		}
+		amount += *booking.Price
+		unpaid = append(unpaid, booking)
+	}
+	if len(unpaid) == 0 {
+		return nil
+	}
+	if err := models.CheckBalance(tx, unpaid[0].UserID, amount, config.Booking.Currency); err != nil {
+		return err
+	}
+
+	for _, booking := range unpaid {
+		if err := models.TransitionBookingStatus(tx, booking.ID, models.StatusActive); err != nil {
+			return err
+		}
+		if err := models.PostCharge(tx, booking.UserID, booking.ID, *booking.Price, *booking.Currency); err != nil {
+			return err
+		}
+		booking.Status = models.StatusActive
+	}
+	return nil
+}
+
+// writeWallet отвечает балансом пользователя, ежемесячной суммой его группы и последними операциями
+func writeWallet(w http.ResponseWriter, db *sql.DB, userID int) {
+	currency := config.Booking.Currency
+	balance, err := models.UserBalance(db, userID, currency)
+	if err != nil {
+		log.Printf("Database query error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	allowance, err := models.GetUserAllowance(db, userID)
+	if err != nil {
+		log.Printf("Database query error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	transactions, err := models.ListUserTransactions(db, userID, ledgerStatementLength)
+	if err != nil {
+		log.Printf("Database query error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"balance":          balance,
+		"currency":         currency,
+		"monthlyAllowance": allowance,
+		"transactions":     transactions,
+	})
+}
+
+// GetMyWallet возвращает баланс вызывающего пользователя и историю операций по нему;
+// отрицательный баланс — долг, например за продление оплаченной брони
+func GetMyWallet(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userID, ok := userIDFromClaims(claims)
+		if !ok {
+			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		writeWallet(w, db, userID)
+	}
+}
+
+// GrantRequest — зачисление на баланс; сумма в копейках
+type GrantRequest struct {
+	Amount      int64  `json:"amount"`
+	Description string `json:"description"`
+	// UserIDs и Group задают получателей массового начисления
+	UserIDs []int  `json:"userIds"`
+	Group   string `json:"group"`
+}
+
+// checkGrant проверяет сумму и описание зачисления и возвращает текст ошибки для клиента
+func checkGrant(req *GrantRequest) string {
+	req.Description = strings.TrimSpace(req.Description)
+	if req.Amount <= 0 {
+		return "Amount must be greater than 0"
+	}
+	if req.Description == "" || len(req.Description) > maxGrantDescriptionLength {
+		return "Description must be between 1 and 200 characters"
+	}
+	return ""
+}
+
+// AdminTopUpWallet зачисляет средства на баланс пользователя
+func AdminTopUpWallet(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		adminID, _ := userIDFromClaims(claims)
+
+		userID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		var req GrantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid top-up data", http.StatusBadRequest)
+			return
+		}
+		if message := checkGrant(&req); message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		transaction, err := models.PostGrant(tx, userID, req.Amount, config.Booking.Currency, req.Description, &adminID)
+		if errors.Is(err, models.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Wallet top-up error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Admin %d topped up wallet of user %d by %d", adminID, userID, req.Amount)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(transaction)
+	}
+}
+
+// AdminGrantCredits зачисляет одну и ту же сумму на балансы перечисленных пользователей
+// или всех участников группы; зачисление проходит целиком или не проходит вовсе
+func AdminGrantCredits(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		claims, err := utils.GetAndValidateTokenClaims(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdminFromClaims(claims) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		adminID, _ := userIDFromClaims(claims)
+
+		var req GrantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid grant data", http.StatusBadRequest)
+			return
+		}
+		if message := checkGrant(&req); message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		if (len(req.UserIDs) == 0) == (req.Group == "") {
+			http.Error(w, "Specify either userIds or group", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Transaction begin error: %v", err)
+			http.Error(w, "Database transaction error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		userIDs := req.UserIDs
+		if req.Group != "" {
+			userIDs, err = models.GroupMemberIDs(tx, req.Group)
+			if errors.Is(err, models.ErrUserGroupNotFound) {
+				http.Error(w, "Group not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("Database query error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		for _, userID := range userIDs {
+			_, err := models.PostGrant(tx, userID, req.Amount, config.Booking.Currency, req.Description, &adminID)
+			if errors.Is(err, models.ErrUserNotFound) {
+				http.Error(w, "User not found: "+strconv.Itoa(userID), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				log.Printf("Wallet grant error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Transaction commit error: %v", err)
+			http.Error(w, "Error while committing transaction", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Admin %d granted %d to %d users", adminID, req.Amount, len(userIDs))
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"granted": len(userIDs),
+			"amount":  req.Amount,
+		})
+	}
+}
@@ -5,9 +5,13 @@ import (
 	"log"
 	"net/http"
 	"time"
+	_ "time/tzdata"
 
+	"server/config"
 	"server/handlers"
 	"server/middlewares"
+	"server/payments"
+	"server/workers"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
@@ -20,6 +24,8 @@ import (
 var db *sql.DB
 
 func main() {
+	config.Load()
+
 	connStr := "postgres://user:password@db:5432/booking?sslmode=disable"
 	var err error
 
@@ -61,6 +67,17 @@ func main() {
 		log.Println("Миграции успешно применены!")
 	}
 
+	if err := payments.Init(config.Payments.Provider, config.Payments.CallbackSecret); err != nil {
+		log.Fatalf("Ошибка настройки платежного провайдера: %v\n", err)
+	}
+
+	// Фоновое освобождение мест при неявке, отмена неоплаченных броней, возвраты через провайдера и обработка листа ожидания
+	workers.StartNoShowWorker(db, config.Booking.NoShowInterval, config.Booking.NoShowGrace, config.Booking.WaitlistOfferTTL)
+	workers.StartPaymentWorker(db, config.Payments.CheckInterval, config.Booking.WaitlistOfferTTL)
+	workers.StartAllowanceWorker(db, config.Payments.AllowanceInterval)
+	workers.StartRefundWorker(db, config.Payments.RefundInterval)
+	workers.StartWaitlistWorker(db, config.Booking.WaitlistInterval, config.Booking.WaitlistOfferTTL)
+
 	// Создаем новый роутер
 	router := mux.NewRouter()
 
@@ -68,15 +85,83 @@ func main() {
 	router.HandleFunc("/api/login", handlers.LoginHandler(db)).Methods("POST")
 	router.HandleFunc("/api/register", handlers.RegisterHandler(db)).Methods("POST")
 	router.Handle("/api/booking", middlewares.CheckAuth(handlers.BookParkingSpot(db))).Methods("POST")
+	router.Handle("/api/quote", middlewares.CheckAuth(handlers.GetQuote(db))).Methods("GET")
+	router.HandleFunc("/api/payments/{provider}/callback", handlers.PaymentCallback(db)).Methods("POST")
+	if config.Payments.Provider == payments.FakeProviderName && config.Payments.FakeCheckout {
+		log.Println("Подтверждение оплаты локальным провайдером включено: только для разработки и тестов")
+		router.Handle("/api/payments/fake/{reference}", middlewares.CheckAuth(handlers.FakeCheckout(db))).Methods("GET", "POST")
+	}
 	router.Handle("/api/bookings", middlewares.CheckAuth(handlers.GetOccupiedSpots(db))).Methods("GET")
+	router.Handle("/api/lots", middlewares.CheckAuth(handlers.GetLots(db))).Methods("GET")
+	router.Handle("/api/lots/{id}/map", middlewares.CheckAuth(handlers.GetLotMap(db))).Methods("GET")
+	router.Handle("/api/spots", middlewares.CheckAuth(handlers.GetSpots(db))).Methods("GET")
+	router.Handle("/api/availability", middlewares.CheckAuth(handlers.GetAvailability(db))).Methods("GET")
+	router.Handle("/api/spots/{n}/timeline", middlewares.CheckAuth(handlers.GetSpotTimeline(db))).Methods("GET")
+
+	// Маршруты текущего пользователя
+	router.Handle("/api/me/bookings", middlewares.CheckAuth(handlers.GetMyBookings(db))).Methods("GET")
+	router.Handle("/api/me/bookings/{id}", middlewares.CheckAuth(handlers.CancelMyBooking(db))).Methods("DELETE")
+	router.Handle("/api/me/bookings/{id}/extend", middlewares.CheckAuth(handlers.ExtendMyBooking(db))).Methods("POST")
+	router.Handle("/api/me/bookings/{id}/check-in", middlewares.CheckAuth(handlers.CheckInMyBooking(db))).Methods("POST")
+	router.Handle("/api/me/bookings/{id}/check-out", middlewares.CheckAuth(handlers.CheckOutMyBooking(db))).Methods("POST")
+	router.Handle("/api/me/series", middlewares.CheckAuth(handlers.GetMySeries(db))).Methods("GET")
+	router.Handle("/api/me/series", middlewares.CheckAuth(handlers.CreateMySeries(db))).Methods("POST")
+	router.Handle("/api/me/series/{id}", middlewares.CheckAuth(handlers.GetMySeriesByID(db))).Methods("GET")
+	router.Handle("/api/me/series/{id}", middlewares.CheckAuth(handlers.CancelMySeries(db))).Methods("DELETE")
+	router.Handle("/api/me/series/{id}/occurrences/{date}", middlewares.CheckAuth(handlers.SkipMySeriesOccurrence(db))).Methods("DELETE")
+	router.Handle("/api/me/waitlist", middlewares.CheckAuth(handlers.GetMyWaitlist(db))).Methods("GET")
+	router.Handle("/api/me/waitlist", middlewares.CheckAuth(handlers.JoinWaitlist(db))).Methods("POST")
+	router.Handle("/api/me/waitlist/{id}", middlewares.CheckAuth(handlers.LeaveWaitlist(db))).Methods("DELETE")
+	router.Handle("/api/me/waitlist/{id}/accept", middlewares.CheckAuth(handlers.AcceptWaitlistOffer(db))).Methods("POST")
+	router.Handle("/api/me/vehicles", middlewares.CheckAuth(handlers.GetMyVehicles(db))).Methods("GET")
+	router.Handle("/api/me/vehicles", middlewares.CheckAuth(handlers.CreateMyVehicle(db))).Methods("POST")
+	router.Handle("/api/me/vehicles/{id}", middlewares.CheckAuth(handlers.GetMyVehicle(db))).Methods("GET")
+	router.Handle("/api/me/vehicles/{id}", middlewares.CheckAuth(handlers.UpdateMyVehicle(db))).Methods("PUT")
+	router.Handle("/api/me/vehicles/{id}", middlewares.CheckAuth(handlers.DeleteMyVehicle(db))).Methods("DELETE")
+	router.Handle("/api/me/wallet", middlewares.CheckAuth(handlers.GetMyWallet(db))).Methods("GET")
+	router.Handle("/api/me/ledger", middlewares.CheckAuth(handlers.GetMyWallet(db))).Methods("GET")
+	router.Handle("/api/me/payments", middlewares.CheckAuth(handlers.GetMyPayments(db))).Methods("GET")
+	router.Handle("/api/me/payments", middlewares.CheckAuth(handlers.PayMyBalance(db))).Methods("POST")
 
 	// Административные маршруты
 	router.HandleFunc("/api/admin/bookings", handlers.GetAllBookings(db)).Methods("GET")
 	router.HandleFunc("/api/admin/bookings/{id}", handlers.CancelBooking(db)).Methods("DELETE")
 	router.HandleFunc("/api/admin/blocked-spots", handlers.GetBlockedSpots(db)).Methods("GET")
 	router.HandleFunc("/api/admin/spots/toggle-block", handlers.ToggleSpotBlock(db)).Methods("POST")
+	router.HandleFunc("/api/admin/spots", handlers.AdminGetSpots(db)).Methods("GET")
+	router.HandleFunc("/api/admin/spots", handlers.AdminCreateSpot(db)).Methods("POST")
+	router.HandleFunc("/api/admin/spots/{n:[0-9]+}", handlers.AdminUpdateSpot(db)).Methods("PUT")
+	router.HandleFunc("/api/admin/spots/{n:[0-9]+}", handlers.AdminRetireSpot(db)).Methods("DELETE")
+	router.HandleFunc("/api/admin/spots/{n:[0-9]+}/restore", handlers.AdminRestoreSpot(db)).Methods("POST")
+	router.HandleFunc("/api/admin/lots", handlers.AdminCreateLot(db)).Methods("POST")
+	router.HandleFunc("/api/admin/lots/{id}", handlers.AdminUpdateLot(db)).Methods("PUT")
+	router.HandleFunc("/api/admin/lots/{id}/zones", handlers.AdminCreateZone(db)).Methods("POST")
+	router.HandleFunc("/api/admin/lots/{id}/zones/{zoneId}", handlers.AdminDeleteZone(db)).Methods("DELETE")
+	router.HandleFunc("/api/admin/lots/{id}/layout", handlers.AdminGetLotLayout(db)).Methods("GET")
+	router.HandleFunc("/api/admin/lots/{id}/layout", handlers.AdminPutLotLayout(db)).Methods("PUT")
+	router.HandleFunc("/api/admin/lots/{id}/layout", handlers.AdminDeleteLotLayout(db)).Methods("DELETE")
+	router.HandleFunc("/api/admin/lots/{id}/layout/spots/{n:[0-9]+}", handlers.AdminPutSpotGeometry(db)).Methods("PUT")
 	router.HandleFunc("/api/admin/users", handlers.GetUsersHandler(db)).Methods("GET")
 	router.HandleFunc("/api/admin/users/{id}/role", handlers.UpdateUserRoleHandler(db)).Methods("PUT")
+	router.HandleFunc("/api/admin/users/{id}/accessibility-permit", handlers.UpdateAccessibilityPermitHandler(db)).Methods("PUT")
+	router.HandleFunc("/api/admin/users/{id}/group", handlers.AdminSetUserGroup(db)).Methods("PUT")
+	router.HandleFunc("/api/admin/users/{id}/ledger", handlers.AdminGetUserLedger(db)).Methods("GET")
+	router.HandleFunc("/api/admin/users/{id}/ledger", handlers.AdminCreateAdjustment(db)).Methods("POST")
+	router.HandleFunc("/api/admin/users/{id}/wallet", handlers.AdminTopUpWallet(db)).Methods("POST")
+	router.HandleFunc("/api/admin/wallet/grants", handlers.AdminGrantCredits(db)).Methods("POST")
+	router.HandleFunc("/api/admin/payments/{id}/refunds", handlers.AdminRefundPayment(db)).Methods("POST")
+	router.HandleFunc("/api/admin/user-groups", handlers.AdminGetUserGroups(db)).Methods("GET")
+	router.HandleFunc("/api/admin/user-groups/{name}", handlers.AdminPutUserGroup(db)).Methods("PUT")
+	router.HandleFunc("/api/admin/user-groups/{name}", handlers.AdminDeleteUserGroup(db)).Methods("DELETE")
+	router.HandleFunc("/api/admin/tariffs", handlers.AdminGetTariffs(db)).Methods("GET")
+	router.HandleFunc("/api/admin/tariffs", handlers.AdminSaveTariff(db)).Methods("POST")
+	router.HandleFunc("/api/admin/tariffs/{id}", handlers.AdminSaveTariff(db)).Methods("PUT")
+	router.HandleFunc("/api/admin/tariffs/{id}", handlers.AdminDeleteTariff(db)).Methods("DELETE")
+	router.HandleFunc("/api/admin/promo-codes", handlers.AdminGetPromoCodes(db)).Methods("GET")
+	router.HandleFunc("/api/admin/promo-codes", handlers.AdminSavePromoCode(db)).Methods("POST")
+	router.HandleFunc("/api/admin/promo-codes/{id}", handlers.AdminSavePromoCode(db)).Methods("PUT")
+	router.HandleFunc("/api/admin/promo-codes/{id}", handlers.AdminDeletePromoCode(db)).Methods("DELETE")
+	router.HandleFunc("/api/admin/promo-codes/{id}/stats", handlers.AdminGetPromoCodeStats(db)).Methods("GET")
 
 	// Создаем и настраиваем CORS middleware
 	corsMiddleware := cors.New(cors.Options{
@@ -0,0 +1,4 @@
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+DROP TRIGGER IF EXISTS trg_bookings_set_period ON bookings;
+DROP FUNCTION IF EXISTS bookings_set_period();
+ALTER TABLE bookings DROP COLUMN IF EXISTS period;
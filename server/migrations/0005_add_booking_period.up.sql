@@ -0,0 +1,48 @@
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+-- Интервал бронирования [reserved_at, reserved_at + hours)
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS period TSTZRANGE;
+
+-- Интервал всегда вычисляется из колонок брони, чтобы не расходиться с ними
+CREATE OR REPLACE FUNCTION bookings_set_period() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.period := tstzrange(NEW.reserved_at, NEW.reserved_at + NEW.hours * interval '1 hour', '[)');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_bookings_set_period ON bookings;
+CREATE TRIGGER trg_bookings_set_period
+    BEFORE INSERT OR UPDATE ON bookings
+    FOR EACH ROW EXECUTE FUNCTION bookings_set_period();
+
+UPDATE bookings SET period = tstzrange(reserved_at, reserved_at + hours * interval '1 hour', '[)');
+
+-- Уже существующие двойные брони не отменяются молча: миграция останавливается
+-- и перечисляет их, чтобы администратор сам решил, какие из них отменить
+DO $$
+DECLARE
+    conflicts TEXT;
+BEGIN
+    SELECT string_agg(format('booking %s overlaps booking %s on spot %s', b.id, o.id, b.parking_spot), '; ')
+    INTO conflicts
+    FROM bookings b
+    JOIN bookings o ON o.parking_spot = b.parking_spot
+        AND o.status = 'active'
+        AND o.id < b.id
+        AND o.period && b.period
+    WHERE b.status = 'active';
+
+    IF conflicts IS NOT NULL THEN
+        RAISE EXCEPTION 'Overlapping active bookings must be resolved before this migration: %', conflicts;
+    END IF;
+END
+$$;
+
+ALTER TABLE bookings ALTER COLUMN period SET NOT NULL;
+
+-- Одно место не может быть занято двумя активными бронями одновременно
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_no_overlap
+    EXCLUDE USING gist (parking_spot WITH =, period WITH &&)
+    WHERE (status = 'active');
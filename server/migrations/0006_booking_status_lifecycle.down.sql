@@ -0,0 +1,8 @@
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_no_overlap
+    EXCLUDE USING gist (parking_spot WITH =, period WITH &&)
+    WHERE (status = 'active');
+
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_status_check;
+ALTER TABLE bookings ALTER COLUMN status DROP NOT NULL;
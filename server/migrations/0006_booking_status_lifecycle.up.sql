@@ -0,0 +1,14 @@
+UPDATE bookings SET status = 'active' WHERE status IS NULL;
+
+ALTER TABLE bookings ALTER COLUMN status SET NOT NULL;
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_status_check
+    CHECK (status IN ('pending', 'active', 'checked_in', 'completed', 'cancelled', 'no_show'));
+
+-- Место занимают брони в статусах pending, active и checked_in
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_no_overlap
+    EXCLUDE USING gist (parking_spot WITH =, period WITH &&)
+    WHERE (status IN ('pending', 'active', 'checked_in'));
@@ -0,0 +1,4 @@
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS cancellation_reason,
+    DROP COLUMN IF EXISTS cancelled_by,
+    DROP COLUMN IF EXISTS cancelled_at;
@@ -0,0 +1,10 @@
+CREATE OR REPLACE FUNCTION bookings_set_period() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.period := tstzrange(NEW.reserved_at, NEW.reserved_at + NEW.hours * interval '1 hour', '[)');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE bookings DROP COLUMN IF EXISTS checked_out_at;
+
+UPDATE bookings SET period = tstzrange(reserved_at, reserved_at + hours * interval '1 hour', '[)');
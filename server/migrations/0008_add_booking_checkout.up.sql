@@ -0,0 +1,15 @@
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS checked_out_at TIMESTAMP WITH TIME ZONE;
+
+-- После выезда фактический интервал заканчивается временем выезда,
+-- плановое окончание по-прежнему вычисляется как reserved_at + hours
+CREATE OR REPLACE FUNCTION bookings_set_period() RETURNS TRIGGER AS $$
+DECLARE
+    ends_at TIMESTAMP WITH TIME ZONE := NEW.reserved_at + NEW.hours * interval '1 hour';
+BEGIN
+    IF NEW.checked_out_at IS NOT NULL THEN
+        ends_at := GREATEST(NEW.reserved_at, LEAST(ends_at, NEW.checked_out_at));
+    END IF;
+    NEW.period := tstzrange(NEW.reserved_at, ends_at, '[)');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_bookings_active_reserved_at;
+ALTER TABLE bookings DROP COLUMN IF EXISTS checked_in_at;
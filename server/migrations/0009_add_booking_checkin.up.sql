@@ -0,0 +1,4 @@
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS checked_in_at TIMESTAMP WITH TIME ZONE;
+
+-- Фоновая проверка неявок ищет активные брони по времени начала
+CREATE INDEX IF NOT EXISTS idx_bookings_active_reserved_at ON bookings(reserved_at) WHERE status = 'active';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_bookings_series_id;
+ALTER TABLE bookings DROP COLUMN IF EXISTS series_id;
+DROP TABLE IF EXISTS booking_series_exceptions;
+DROP TABLE IF EXISTS booking_series;
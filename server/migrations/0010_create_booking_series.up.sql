@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS booking_series (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parking_spot INTEGER NOT NULL CHECK (parking_spot > 0 AND parking_spot <= 16),
+    car_number VARCHAR(20) NOT NULL,
+    -- Дни недели по ISO 8601: 1 — понедельник, 7 — воскресенье
+    weekdays SMALLINT[] NOT NULL,
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    starts_on DATE NOT NULL,
+    ends_on DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'cancelled')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (end_time > start_time),
+    CHECK (ends_on >= starts_on)
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_series_user_id ON booking_series(user_id);
+
+-- Даты, в которые повторяющаяся бронь не действует
+CREATE TABLE IF NOT EXISTS booking_series_exceptions (
+    series_id INTEGER NOT NULL REFERENCES booking_series(id) ON DELETE CASCADE,
+    occurs_on DATE NOT NULL,
+    PRIMARY KEY (series_id, occurs_on)
+);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS series_id INTEGER REFERENCES booking_series(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_bookings_series_id ON bookings(series_id);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS waitlist_entries (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- NULL означает, что подходит любое место
+    parking_spot INTEGER CHECK (parking_spot > 0 AND parking_spot <= 16),
+    car_number VARCHAR(20) NOT NULL,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    hours INTEGER NOT NULL CHECK (hours > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'waiting'
+        CHECK (status IN ('waiting', 'offered', 'accepted', 'expired', 'cancelled')),
+    -- Бронь в статусе pending, которая удерживает место на время предложения
+    booking_id INTEGER REFERENCES bookings(id) ON DELETE SET NULL,
+    offered_at TIMESTAMP WITH TIME ZONE,
+    offer_expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_waitlist_user_id ON waitlist_entries(user_id);
+CREATE INDEX IF NOT EXISTS idx_waitlist_waiting ON waitlist_entries(created_at) WHERE status = 'waiting';
+CREATE INDEX IF NOT EXISTS idx_waitlist_offered ON waitlist_entries(offer_expires_at) WHERE status = 'offered';
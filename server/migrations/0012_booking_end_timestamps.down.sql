@@ -0,0 +1,26 @@
+ALTER TABLE waitlist_entries ADD COLUMN IF NOT EXISTS hours INTEGER;
+UPDATE waitlist_entries SET hours = GREATEST(1, CEIL(EXTRACT(EPOCH FROM ends_at - starts_at) / 3600));
+ALTER TABLE waitlist_entries ALTER COLUMN hours SET NOT NULL;
+ALTER TABLE waitlist_entries ADD CONSTRAINT waitlist_entries_hours_check CHECK (hours > 0);
+ALTER TABLE waitlist_entries DROP CONSTRAINT IF EXISTS waitlist_entries_ends_after_start;
+ALTER TABLE waitlist_entries DROP COLUMN IF EXISTS ends_at;
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS hours INTEGER;
+UPDATE bookings SET hours = GREATEST(1, CEIL(EXTRACT(EPOCH FROM ends_at - reserved_at) / 3600));
+ALTER TABLE bookings ALTER COLUMN hours SET NOT NULL;
+ALTER TABLE bookings ADD CONSTRAINT bookings_hours_check CHECK (hours > 0);
+
+CREATE OR REPLACE FUNCTION bookings_set_period() RETURNS TRIGGER AS $$
+DECLARE
+    ends_at TIMESTAMP WITH TIME ZONE := NEW.reserved_at + NEW.hours * interval '1 hour';
+BEGIN
+    IF NEW.checked_out_at IS NOT NULL THEN
+        ends_at := GREATEST(NEW.reserved_at, LEAST(ends_at, NEW.checked_out_at));
+    END IF;
+    NEW.period := tstzrange(NEW.reserved_at, ends_at, '[)');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_ends_after_start;
+ALTER TABLE bookings DROP COLUMN IF EXISTS ends_at;
@@ -0,0 +1,25 @@
+-- Брони хранят явное время окончания вместо целого числа часов
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS ends_at TIMESTAMP WITH TIME ZONE;
+UPDATE bookings SET ends_at = reserved_at + hours * interval '1 hour' WHERE ends_at IS NULL;
+ALTER TABLE bookings ALTER COLUMN ends_at SET NOT NULL;
+ALTER TABLE bookings ADD CONSTRAINT bookings_ends_after_start CHECK (ends_at > reserved_at);
+
+CREATE OR REPLACE FUNCTION bookings_set_period() RETURNS TRIGGER AS $$
+DECLARE
+    effective_end TIMESTAMP WITH TIME ZONE := NEW.ends_at;
+BEGIN
+    IF NEW.checked_out_at IS NOT NULL THEN
+        effective_end := GREATEST(NEW.reserved_at, LEAST(effective_end, NEW.checked_out_at));
+    END IF;
+    NEW.period := tstzrange(NEW.reserved_at, effective_end, '[)');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE bookings DROP COLUMN IF EXISTS hours;
+
+ALTER TABLE waitlist_entries ADD COLUMN IF NOT EXISTS ends_at TIMESTAMP WITH TIME ZONE;
+UPDATE waitlist_entries SET ends_at = starts_at + hours * interval '1 hour' WHERE ends_at IS NULL;
+ALTER TABLE waitlist_entries ALTER COLUMN ends_at SET NOT NULL;
+ALTER TABLE waitlist_entries ADD CONSTRAINT waitlist_entries_ends_after_start CHECK (ends_at > starts_at);
+ALTER TABLE waitlist_entries DROP COLUMN IF EXISTS hours;
@@ -0,0 +1,2 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS vehicle_id;
+DROP TABLE IF EXISTS vehicles;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS vehicles (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- Номер хранится нормализованным: заглавные латинские буквы и цифры без разделителей
+    plate VARCHAR(20) NOT NULL,
+    -- Код страны регистрации по ISO 3166-1 alpha-2
+    country CHAR(2) NOT NULL,
+    make VARCHAR(50) NOT NULL DEFAULT '',
+    model VARCHAR(50) NOT NULL DEFAULT '',
+    colour VARCHAR(30) NOT NULL DEFAULT '',
+    size_class VARCHAR(20) NOT NULL DEFAULT 'standard'
+        CHECK (size_class IN ('small', 'standard', 'large')),
+    is_ev BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, country, plate)
+);
+
+CREATE INDEX IF NOT EXISTS idx_vehicles_plate ON vehicles(plate);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS vehicle_id INTEGER REFERENCES vehicles(id) ON DELETE SET NULL;
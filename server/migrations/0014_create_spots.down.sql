@@ -0,0 +1,15 @@
+ALTER TABLE blocked_spots DROP CONSTRAINT IF EXISTS blocked_spots_spot_number_fkey;
+
+ALTER TABLE waitlist_entries DROP CONSTRAINT IF EXISTS waitlist_entries_parking_spot_fkey;
+ALTER TABLE waitlist_entries ADD CONSTRAINT waitlist_entries_parking_spot_check
+    CHECK (parking_spot > 0 AND parking_spot <= 16) NOT VALID;
+
+ALTER TABLE booking_series DROP CONSTRAINT IF EXISTS booking_series_parking_spot_fkey;
+ALTER TABLE booking_series ADD CONSTRAINT booking_series_parking_spot_check
+    CHECK (parking_spot > 0 AND parking_spot <= 16) NOT VALID;
+
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_parking_spot_fkey;
+ALTER TABLE bookings ADD CONSTRAINT bookings_parking_spot_check
+    CHECK (parking_spot > 0 AND parking_spot <= 16) NOT VALID;
+
+DROP TABLE IF EXISTS spots;
@@ -0,0 +1,40 @@
+-- Таблица мест — единственный источник данных о составе парковки
+CREATE TABLE IF NOT EXISTS spots (
+    number INTEGER PRIMARY KEY CHECK (number > 0),
+    label VARCHAR(20) NOT NULL UNIQUE,
+    -- Выведенное из эксплуатации место нельзя бронировать, но его история сохраняется
+    retired_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO spots (number, label)
+SELECT n, n::text FROM generate_series(1, 16) AS n
+ON CONFLICT (number) DO NOTHING;
+
+-- Места, на которые уже ссылаются данные, должны существовать в таблице
+INSERT INTO spots (number, label)
+SELECT DISTINCT spot, spot::text
+FROM (
+    SELECT parking_spot AS spot FROM bookings
+    UNION SELECT parking_spot FROM booking_series
+    UNION SELECT parking_spot FROM waitlist_entries WHERE parking_spot IS NOT NULL
+    UNION SELECT spot_number FROM blocked_spots
+) AS referenced
+WHERE spot > 0
+ON CONFLICT (number) DO NOTHING;
+
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_parking_spot_check;
+ALTER TABLE bookings ADD CONSTRAINT bookings_parking_spot_fkey
+    FOREIGN KEY (parking_spot) REFERENCES spots(number);
+
+ALTER TABLE booking_series DROP CONSTRAINT IF EXISTS booking_series_parking_spot_check;
+ALTER TABLE booking_series ADD CONSTRAINT booking_series_parking_spot_fkey
+    FOREIGN KEY (parking_spot) REFERENCES spots(number);
+
+ALTER TABLE waitlist_entries DROP CONSTRAINT IF EXISTS waitlist_entries_parking_spot_check;
+ALTER TABLE waitlist_entries ADD CONSTRAINT waitlist_entries_parking_spot_fkey
+    FOREIGN KEY (parking_spot) REFERENCES spots(number);
+
+DELETE FROM blocked_spots WHERE spot_number NOT IN (SELECT number FROM spots);
+ALTER TABLE blocked_spots ADD CONSTRAINT blocked_spots_spot_number_fkey
+    FOREIGN KEY (spot_number) REFERENCES spots(number);
@@ -0,0 +1,8 @@
+ALTER TABLE waitlist_entries DROP COLUMN IF EXISTS lot_id;
+DROP INDEX IF EXISTS idx_spots_lot_id;
+ALTER TABLE spots DROP CONSTRAINT IF EXISTS spots_lot_label_key;
+ALTER TABLE spots ADD CONSTRAINT spots_label_key UNIQUE (label);
+ALTER TABLE spots DROP COLUMN IF EXISTS zone_id;
+ALTER TABLE spots DROP COLUMN IF EXISTS lot_id;
+DROP TABLE IF EXISTS zones;
+DROP TABLE IF EXISTS lots;
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS lots (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    address VARCHAR(255) NOT NULL DEFAULT '',
+    -- Часовой пояс IANA; NULL — часовой пояс сервиса по умолчанию (TIMEZONE)
+    timezone VARCHAR(64),
+    -- Часы работы в часовом поясе парковки; NULL — круглосуточно
+    opens_at TIME,
+    closes_at TIME,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT lots_opening_hours_check CHECK (
+        (opens_at IS NULL AND closes_at IS NULL) OR opens_at < closes_at
+    )
+);
+
+CREATE TABLE IF NOT EXISTS zones (
+    id SERIAL PRIMARY KEY,
+    lot_id INTEGER NOT NULL REFERENCES lots(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    UNIQUE (lot_id, name)
+);
+
+-- Существующие места относятся к парковке по умолчанию
+INSERT INTO lots (name) VALUES ('Main car park') ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE spots ADD COLUMN IF NOT EXISTS lot_id INTEGER REFERENCES lots(id);
+UPDATE spots SET lot_id = (SELECT MIN(id) FROM lots) WHERE lot_id IS NULL;
+ALTER TABLE spots ALTER COLUMN lot_id SET NOT NULL;
+ALTER TABLE spots ADD COLUMN IF NOT EXISTS zone_id INTEGER REFERENCES zones(id) ON DELETE SET NULL;
+
+-- Подпись места уникальна в пределах парковки
+ALTER TABLE spots DROP CONSTRAINT IF EXISTS spots_label_key;
+ALTER TABLE spots ADD CONSTRAINT spots_lot_label_key UNIQUE (lot_id, label);
+
+CREATE INDEX IF NOT EXISTS idx_spots_lot_id ON spots(lot_id);
+
+-- Запись листа ожидания на любое место ограничивается парковкой
+ALTER TABLE waitlist_entries ADD COLUMN IF NOT EXISTS lot_id INTEGER REFERENCES lots(id) ON DELETE CASCADE;
@@ -0,0 +1,10 @@
+ALTER TABLE users DROP COLUMN IF EXISTS accessibility_permit;
+
+UPDATE vehicles SET size_class = 'small' WHERE size_class = 'motorcycle';
+ALTER TABLE vehicles DROP CONSTRAINT IF EXISTS vehicles_size_class_check;
+ALTER TABLE vehicles ADD CONSTRAINT vehicles_size_class_check
+    CHECK (size_class IN ('small', 'standard', 'large'));
+
+DROP INDEX IF EXISTS idx_spots_features;
+ALTER TABLE spots DROP CONSTRAINT IF EXISTS spots_features_check;
+ALTER TABLE spots DROP COLUMN IF EXISTS features;
@@ -0,0 +1,14 @@
+-- Характеристики места: зарядка для электромобиля, место для людей с инвалидностью,
+-- навес, место только для мотоциклов
+ALTER TABLE spots ADD COLUMN IF NOT EXISTS features TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE spots ADD CONSTRAINT spots_features_check
+    CHECK (features <@ ARRAY['ev_charger', 'accessible', 'covered', 'motorcycle']::TEXT[]);
+
+CREATE INDEX IF NOT EXISTS idx_spots_features ON spots USING GIN (features);
+
+ALTER TABLE vehicles DROP CONSTRAINT IF EXISTS vehicles_size_class_check;
+ALTER TABLE vehicles ADD CONSTRAINT vehicles_size_class_check
+    CHECK (size_class IN ('motorcycle', 'small', 'standard', 'large'));
+
+-- Разрешение на парковку на местах для людей с инвалидностью выдает администратор
+ALTER TABLE users ADD COLUMN IF NOT EXISTS accessibility_permit BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,7 @@
+-- Схема парковки в формате GeoJSON FeatureCollection: места, въезды и проезды.
+-- Места ссылаются на spots.number через properties.spot
+CREATE TABLE IF NOT EXISTS lot_layouts (
+    lot_id INTEGER PRIMARY KEY REFERENCES lots(id) ON DELETE CASCADE,
+    geojson JSONB NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,8 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS currency;
+ALTER TABLE bookings DROP COLUMN IF EXISTS price;
+
+DROP TABLE IF EXISTS tariff_rates;
+DROP TABLE IF EXISTS tariffs;
+
+ALTER TABLE users DROP COLUMN IF EXISTS user_group;
+DROP TABLE IF EXISTS user_groups;
@@ -0,0 +1,44 @@
+-- Группы пользователей (студенты, сотрудники, гости) со скидкой на стоимость брони
+CREATE TABLE IF NOT EXISTS user_groups (
+    name VARCHAR(30) PRIMARY KEY,
+    discount_percent INTEGER NOT NULL DEFAULT 0
+        CHECK (discount_percent >= 0 AND discount_percent <= 100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS user_group VARCHAR(30)
+    REFERENCES user_groups(name) ON UPDATE CASCADE ON DELETE SET NULL;
+
+-- Тариф применяется к местам парковки lot_id с характеристикой spot_feature;
+-- NULL означает любую парковку или любое место. Суммы хранятся в копейках
+CREATE TABLE IF NOT EXISTS tariffs (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    lot_id INTEGER REFERENCES lots(id) ON DELETE CASCADE,
+    spot_feature TEXT CHECK (spot_feature IN ('ev_charger', 'accessible', 'covered', 'motorcycle')),
+    hourly_rate INTEGER NOT NULL CHECK (hourly_rate >= 0),
+    daily_cap INTEGER CHECK (daily_cap >= 0),
+    free_minutes INTEGER NOT NULL DEFAULT 0 CHECK (free_minutes >= 0),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Особая почасовая ставка по дням недели (ISO 8601: 1 — понедельник) и времени суток
+CREATE TABLE IF NOT EXISTS tariff_rates (
+    id SERIAL PRIMARY KEY,
+    tariff_id INTEGER NOT NULL REFERENCES tariffs(id) ON DELETE CASCADE,
+    weekdays INTEGER[] NOT NULL CHECK (weekdays <@ ARRAY[1, 2, 3, 4, 5, 6, 7] AND cardinality(weekdays) > 0),
+    starts_at TIME NOT NULL,
+    ends_at TIME NOT NULL,
+    hourly_rate INTEGER NOT NULL CHECK (hourly_rate >= 0),
+    CONSTRAINT tariff_rates_window_check CHECK (starts_at < ends_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tariff_rates_tariff_id ON tariff_rates(tariff_id);
+
+-- Базовый тариф повторяет цену, которую раньше показывал клиент: 100 рублей в час
+INSERT INTO tariffs (name, hourly_rate) VALUES ('Standard', 10000) ON CONFLICT (name) DO NOTHING;
+
+-- Стоимость фиксируется при бронировании; у старых броней ее нет
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS price INTEGER CHECK (price >= 0);
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS currency CHAR(3);
@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS trg_ledger_entries_balanced ON ledger_entries;
+DROP FUNCTION IF EXISTS ledger_check_balance();
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS ledger_transactions;
+
+DROP INDEX IF EXISTS idx_bookings_payment_id;
+ALTER TABLE bookings DROP COLUMN IF EXISTS payment_id;
+
+DROP TABLE IF EXISTS payments;
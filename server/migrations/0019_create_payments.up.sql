@@ -0,0 +1,70 @@
+-- Платежи через внешнего провайдера; reference — идентификатор платежа у провайдера
+CREATE TABLE IF NOT EXISTS payments (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(30) NOT NULL,
+    reference VARCHAR(100),
+    confirmation_url TEXT,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    refunded_amount INTEGER NOT NULL DEFAULT 0
+        CHECK (refunded_amount >= 0 AND refunded_amount <= amount),
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'succeeded', 'failed', 'expired')),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT payments_reference_key UNIQUE (provider, reference)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_user_id ON payments(user_id);
+CREATE INDEX IF NOT EXISTS idx_payments_pending ON payments(expires_at) WHERE status = 'pending';
+
+-- Платная бронь остается в статусе pending, пока не подтвержден ее платеж
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS payment_id INTEGER REFERENCES payments(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_bookings_payment_id ON bookings(payment_id) WHERE payment_id IS NOT NULL;
+
+-- Журнал двойной записи: каждая операция состоит из проводок с нулевой суммой.
+-- Счет user — расчеты с пользователем (плюс — долг пользователя, минус — его предоплата),
+-- revenue — выручка парковки, provider — деньги у платежного провайдера
+CREATE TABLE IF NOT EXISTS ledger_transactions (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('charge', 'payment', 'refund', 'adjustment')),
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    booking_id INTEGER REFERENCES bookings(id) ON DELETE SET NULL,
+    payment_id INTEGER REFERENCES payments(id) ON DELETE SET NULL,
+    currency CHAR(3) NOT NULL,
+    description VARCHAR(200) NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_transactions_user_id ON ledger_transactions(user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL REFERENCES ledger_transactions(id) ON DELETE CASCADE,
+    account VARCHAR(20) NOT NULL CHECK (account IN ('user', 'revenue', 'provider')),
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    amount INTEGER NOT NULL CHECK (amount <> 0),
+    CONSTRAINT ledger_entries_user_check CHECK ((account = 'user') = (user_id IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_transaction_id ON ledger_entries(transaction_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_user_id ON ledger_entries(user_id) WHERE account = 'user';
+
+-- Сумма проводок операции проверяется в конце транзакции, когда записаны все ее проводки
+CREATE OR REPLACE FUNCTION ledger_check_balance() RETURNS TRIGGER AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM ledger_entries WHERE transaction_id = NEW.transaction_id) <> 0 THEN
+        RAISE EXCEPTION 'ledger transaction % is not balanced', NEW.transaction_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_ledger_entries_balanced ON ledger_entries;
+CREATE CONSTRAINT TRIGGER trg_ledger_entries_balanced
+    AFTER INSERT ON ledger_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION ledger_check_balance();
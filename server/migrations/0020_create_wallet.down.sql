@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS wallet_allowances;
+
+DELETE FROM ledger_transactions WHERE kind = 'grant';
+
+ALTER TABLE ledger_entries DROP CONSTRAINT IF EXISTS ledger_entries_account_check;
+ALTER TABLE ledger_entries ADD CONSTRAINT ledger_entries_account_check
+    CHECK (account IN ('user', 'revenue', 'provider'));
+
+ALTER TABLE ledger_transactions DROP CONSTRAINT IF EXISTS ledger_transactions_kind_check;
+ALTER TABLE ledger_transactions ADD CONSTRAINT ledger_transactions_kind_check
+    CHECK (kind IN ('charge', 'payment', 'refund', 'adjustment'));
+
+ALTER TABLE user_groups DROP COLUMN IF EXISTS monthly_allowance;
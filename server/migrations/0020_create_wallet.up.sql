@@ -0,0 +1,23 @@
+-- Сумма, которая каждый месяц зачисляется на баланс участникам группы (в копейках)
+ALTER TABLE user_groups ADD COLUMN IF NOT EXISTS monthly_allowance INTEGER NOT NULL DEFAULT 0
+    CHECK (monthly_allowance >= 0);
+
+-- Операция grant зачисляет на баланс средства организации со счета grants:
+-- пополнение администратором, массовое начисление и ежемесячная сумма группы
+ALTER TABLE ledger_transactions DROP CONSTRAINT IF EXISTS ledger_transactions_kind_check;
+ALTER TABLE ledger_transactions ADD CONSTRAINT ledger_transactions_kind_check
+    CHECK (kind IN ('charge', 'payment', 'refund', 'adjustment', 'grant'));
+
+ALTER TABLE ledger_entries DROP CONSTRAINT IF EXISTS ledger_entries_account_check;
+ALTER TABLE ledger_entries ADD CONSTRAINT ledger_entries_account_check
+    CHECK (account IN ('user', 'revenue', 'provider', 'grants'));
+
+-- Ежемесячные зачисления; первичный ключ не дает зачислить сумму дважды за месяц
+CREATE TABLE IF NOT EXISTS wallet_allowances (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    month DATE NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    transaction_id INTEGER REFERENCES ledger_transactions(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, month)
+);
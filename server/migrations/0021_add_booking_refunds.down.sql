@@ -0,0 +1,3 @@
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS refund_rule,
+    DROP COLUMN IF EXISTS refund_amount;
@@ -0,0 +1,5 @@
+-- Возврат, рассчитанный по правилам отмены: сумма в копейках и примененное правило
+ALTER TABLE bookings
+    ADD COLUMN IF NOT EXISTS refund_amount INTEGER CHECK (refund_amount >= 0),
+    ADD COLUMN IF NOT EXISTS refund_rule VARCHAR(20)
+        CHECK (refund_rule IN ('full', 'partial', 'none', 'admin_override'));
@@ -0,0 +1,33 @@
+-- Промокоды на скидку: процент или фиксированная сумма в копейках.
+-- Пустые lot_ids и spot_features означают любую парковку и любое место
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(40) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    discount_percent INTEGER CHECK (discount_percent > 0 AND discount_percent <= 100),
+    discount_amount INTEGER CHECK (discount_amount > 0),
+    valid_from TIMESTAMP WITH TIME ZONE,
+    valid_until TIMESTAMP WITH TIME ZONE,
+    max_uses INTEGER CHECK (max_uses > 0),
+    max_uses_per_user INTEGER CHECK (max_uses_per_user > 0),
+    lot_ids INTEGER[] NOT NULL DEFAULT '{}',
+    spot_features TEXT[] NOT NULL DEFAULT '{}'
+        CHECK (spot_features <@ ARRAY['ev_charger', 'accessible', 'covered', 'motorcycle']::TEXT[]),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT promo_codes_discount_check CHECK ((discount_percent IS NULL) <> (discount_amount IS NULL)),
+    CONSTRAINT promo_codes_window_check CHECK (valid_from IS NULL OR valid_until IS NULL OR valid_from < valid_until)
+);
+
+-- Применение промокода к брони; discount — скидка в копейках.
+-- Промокод с применениями удалить нельзя, чтобы не потерять статистику
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+    id SERIAL PRIMARY KEY,
+    promo_code_id INTEGER NOT NULL REFERENCES promo_codes(id) ON DELETE RESTRICT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    booking_id INTEGER NOT NULL UNIQUE REFERENCES bookings(id) ON DELETE CASCADE,
+    discount INTEGER NOT NULL CHECK (discount >= 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_code_user ON promo_redemptions(promo_code_id, user_id);
@@ -0,0 +1,214 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Стратегии автоматического выбора места
+const (
+	StrategyEntrance       = "fill_from_entrance"
+	StrategySpreadOut      = "spread_out"
+	StrategyPreferred      = "preferred"
+	StrategyAttributeMatch = "attribute_match"
+)
+
+// AssignmentRequest — параметры брони, для которой подбирается место
+type AssignmentRequest struct {
+	UserID int
+	Start  time.Time
+	End    time.Time
+	// Features — характеристики, которые нужны пользователю
+	Features []string
+	// Preferred — места, которые пользователь просит в первую очередь
+	Preferred []int
+}
+
+// AssignmentStrategy упорядочивает свободные места от лучшего к худшему
+type AssignmentStrategy interface {
+	Rank(db Queryer, candidates []Spot, req AssignmentRequest) ([]Spot, error)
+}
+
+var assignmentStrategies = map[string]AssignmentStrategy{
+	StrategyEntrance:       entranceStrategy{},
+	StrategySpreadOut:      spreadOutStrategy{},
+	StrategyPreferred:      preferredStrategy{},
+	StrategyAttributeMatch: attributeMatchStrategy{},
+}
+
+// GetAssignmentStrategy возвращает стратегию по имени
+func GetAssignmentStrategy(name string) (AssignmentStrategy, bool) {
+	strategy, ok := assignmentStrategies[name]
+	return strategy, ok
+}
+
+// FreeSpots возвращает действующие незаблокированные места парковки lotID (0 — всех парковок)
+// со всеми характеристиками features, свободные на интервале [start, end), по номеру
+func FreeSpots(db Queryer, lotID int, features []string, start, end time.Time) ([]Spot, error) {
+	rows, err := db.Query(`
+		SELECT `+spotColumns+`
+		FROM spots s
+		WHERE retired_at IS NULL
+		AND ($1 = 0 OR lot_id = $1)
+		AND features @> COALESCE($2::TEXT[], '{}')
+		AND NOT EXISTS (
+			SELECT 1 FROM blocked_spots b
+			WHERE b.spot_number = s.number AND b.is_blocked = true
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM bookings
+			WHERE parking_spot = s.number
+			AND period && tstzrange($3, $4, '[)')
+			AND `+OccupyingStatusCondition+`
+		)
+		ORDER BY number
+	`, lotID, pq.Array(features), start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spots := []Spot{}
+	for rows.Next() {
+		var spot Spot
+		if err := scanSpotRow(rows, &spot); err != nil {
+			return nil, err
+		}
+		spots = append(spots, spot)
+	}
+
+	return spots, rows.Err()
+}
+
+// rankBy упорядочивает места по возрастанию score, при равенстве — по номеру
+func rankBy(candidates []Spot, score func(Spot) int) []Spot {
+	ranked := append([]Spot(nil), candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		si, sj := score(ranked[i]), score(ranked[j])
+		if si != sj {
+			return si < sj
+		}
+		return ranked[i].Number < ranked[j].Number
+	})
+	return ranked
+}
+
+// entranceStrategy заполняет парковку от въезда: номера мест идут от въезда вглубь
+type entranceStrategy struct{}
+
+func (entranceStrategy) Rank(db Queryer, candidates []Spot, req AssignmentRequest) ([]Spot, error) {
+	return rankBy(candidates, func(spot Spot) int { return spot.Number }), nil
+}
+
+// spreadOutStrategy выбирает место, наиболее удаленное от занятых на том же интервале
+// мест той же парковки, чтобы машинам было проще парковаться
+type spreadOutStrategy struct{}
+
+func (spreadOutStrategy) Rank(db Queryer, candidates []Spot, req AssignmentRequest) ([]Spot, error) {
+	busy, err := GetBusyIntervals(db, req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+	lots, err := activeSpotLots(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return rankBy(candidates, func(spot Spot) int {
+		nearest := -1
+		for number := range busy {
+			if lots[number] != spot.LotID {
+				continue
+			}
+			distance := number - spot.Number
+			if distance < 0 {
+				distance = -distance
+			}
+			if nearest < 0 || distance < nearest {
+				nearest = distance
+			}
+		}
+		if nearest < 0 {
+			// На парковке никого нет: такие места лучше всех, между собой — от въезда
+			return math.MinInt32
+		}
+		return -nearest
+	}), nil
+}
+
+// preferredStrategy сначала предлагает места из запроса, затем места, которые пользователь
+// бронировал чаще всего, затем остальные от въезда
+type preferredStrategy struct{}
+
+func (preferredStrategy) Rank(db Queryer, candidates []Spot, req AssignmentRequest) ([]Spot, error) {
+	rows, err := db.Query(`
+		SELECT parking_spot, COUNT(*)
+		FROM bookings
+		WHERE user_id = $1
+		GROUP BY parking_spot
+	`, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := map[int]int{}
+	for rows.Next() {
+		var spot, count int
+		if err := rows.Scan(&spot, &count); err != nil {
+			return nil, err
+		}
+		history[spot] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	preferred := map[int]int{}
+	for i, spot := range req.Preferred {
+		if _, ok := preferred[spot]; !ok {
+			preferred[spot] = i
+		}
+	}
+
+	ranked := append([]Spot(nil), candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		pi, iok := preferred[ranked[i].Number]
+		pj, jok := preferred[ranked[j].Number]
+		if iok != jok {
+			return iok
+		}
+		if iok && pi != pj {
+			return pi < pj
+		}
+		if hi, hj := history[ranked[i].Number], history[ranked[j].Number]; hi != hj {
+			return hi > hj
+		}
+		return ranked[i].Number < ranked[j].Number
+	})
+	return ranked, nil
+}
+
+// attributeMatchStrategy выбирает место с наименьшим числом лишних характеристик,
+// чтобы места с зарядкой или для людей с инвалидностью оставались тем, кому они нужны
+type attributeMatchStrategy struct{}
+
+func (attributeMatchStrategy) Rank(db Queryer, candidates []Spot, req AssignmentRequest) ([]Spot, error) {
+	wanted := map[string]bool{}
+	for _, feature := range req.Features {
+		wanted[feature] = true
+	}
+
+	return rankBy(candidates, func(spot Spot) int {
+		extra := 0
+		for _, feature := range spot.Features {
+			if !wanted[feature] {
+				extra++
+			}
+		}
+		return extra
+	}), nil
+}
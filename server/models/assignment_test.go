@@ -0,0 +1,134 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"server/testutil"
+)
+
+func spotNumbers(spots []Spot) []int {
+	numbers := make([]int, 0, len(spots))
+	for _, spot := range spots {
+		numbers = append(numbers, spot.Number)
+	}
+	return numbers
+}
+
+func TestRankWithoutHistory(t *testing.T) {
+	candidates := []Spot{
+		{Number: 7, Features: []string{FeatureEVCharger, FeatureCovered}},
+		{Number: 3, Features: []string{FeatureCovered}},
+		{Number: 5, Features: []string{}},
+		{Number: 1, Features: []string{FeatureAccessible}},
+		{Number: 2, Features: []string{FeatureEVCharger}},
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		features []string
+		want     []int
+	}{
+		{name: "from the entrance", strategy: StrategyEntrance, want: []int{1, 2, 3, 5, 7}},
+		{name: "fewest extra features without a request", strategy: StrategyAttributeMatch, want: []int{5, 1, 2, 3, 7}},
+		{name: "requested features are not extra", strategy: StrategyAttributeMatch, features: []string{FeatureEVCharger}, want: []int{2, 5, 1, 3, 7}},
+		{name: "ties are broken by number", strategy: StrategyAttributeMatch, features: []string{FeatureCovered, FeatureEVCharger}, want: []int{2, 3, 5, 7, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, ok := GetAssignmentStrategy(tt.strategy)
+			if !ok {
+				t.Fatalf("unknown strategy %s", tt.strategy)
+			}
+			ranked, err := strategy.Rank(nil, candidates, AssignmentRequest{Features: tt.features})
+			if err != nil {
+				t.Fatalf("Rank: %v", err)
+			}
+			if got := spotNumbers(ranked); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ranked %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := GetAssignmentStrategy("random"); ok {
+		t.Fatal("unknown strategy was found")
+	}
+}
+
+func TestRankSpreadOut(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userID := testutil.CreateUser(t, db, "spread@example.com")
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	end := start.Add(2 * time.Hour)
+	strategy, _ := GetAssignmentStrategy(StrategySpreadOut)
+
+	rank := func() []int {
+		t.Helper()
+		free, err := FreeSpots(db, 0, nil, start, end)
+		if err != nil {
+			t.Fatalf("FreeSpots: %v", err)
+		}
+		ranked, err := strategy.Rank(db, free, AssignmentRequest{UserID: userID, Start: start, End: end})
+		if err != nil {
+			t.Fatalf("Rank: %v", err)
+		}
+		return spotNumbers(ranked)
+	}
+
+	// На пустой парковке места идут от въезда
+	if got := rank(); got[0] != 1 || got[1] != 2 {
+		t.Fatalf("empty lot ranked %v, want to start with 1, 2", got)
+	}
+
+	_, err := CreateBooking(db, &Booking{
+		UserID: userID, ParkingSpot: 5, CarNumber: "AA123BB",
+		ReservedAt: start.Add(time.Hour), PlannedEndsAt: end.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("create booking: %v", err)
+	}
+	got := rank()
+	if got[0] != 16 || got[1] != 15 {
+		t.Fatalf("ranked %v, want the farthest spots 16, 15 first", got)
+	}
+	if last := got[len(got)-2:]; !reflect.DeepEqual(last, []int{4, 6}) {
+		t.Fatalf("ranked %v, want the neighbours 4, 6 last", got)
+	}
+}
+
+func TestRankPreferred(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userID := testutil.CreateUser(t, db, "preferred@example.com")
+	start := time.Now().Add(72 * time.Hour).Truncate(time.Hour)
+
+	// История: место 7 трижды, место 2 один раз, в другие дни
+	for i, spot := range []int{7, 7, 7, 2} {
+		day := start.AddDate(0, 0, i+1)
+		_, err := CreateBooking(db, &Booking{
+			UserID: userID, ParkingSpot: spot, CarNumber: "AA123BB",
+			ReservedAt: day, PlannedEndsAt: day.Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("create booking: %v", err)
+		}
+	}
+
+	candidates := []Spot{}
+	for number := 1; number <= 10; number++ {
+		candidates = append(candidates, Spot{Number: number})
+	}
+	strategy, _ := GetAssignmentStrategy(StrategyPreferred)
+	ranked, err := strategy.Rank(db, candidates, AssignmentRequest{
+		UserID: userID, Start: start, End: start.Add(time.Hour), Preferred: []int{9, 3, 9, 42},
+	})
+	if err != nil {
+		t.Fatalf("Rank: %v", err)
+	}
+	want := []int{9, 3, 7, 2, 1, 4, 5, 6, 8, 10}
+	if got := spotNumbers(ranked); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranked %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"time"
+)
+
+// Interval — полуоткрытый интервал времени [Start, End)
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// SpotAvailability — доступность места в запрошенном окне
+type SpotAvailability struct {
+	ParkingSpot int `json:"parkingSpot"`
+	// Blocked — место заблокировано администратором и недоступно целиком
+	Blocked bool `json:"blocked"`
+	// Free — место свободно на всем окне
+	Free bool `json:"free"`
+	// FreeIntervals — свободные промежутки внутри окна
+	FreeIntervals []Interval `json:"freeIntervals"`
+}
+
+// FreeIntervals возвращает промежутки окна [from, to), не покрытые занятыми интервалами.
+// busy должны быть отсортированы по началу; пересекающиеся интервалы допускаются
+func FreeIntervals(busy []Interval, from, to time.Time) []Interval {
+	free := []Interval{}
+	cursor := from
+	for _, interval := range busy {
+		if interval.Start.After(cursor) {
+			end := interval.Start
+			if end.After(to) {
+				end = to
+			}
+			if end.After(cursor) {
+				free = append(free, Interval{Start: cursor, End: end})
+			}
+		}
+		if interval.End.After(cursor) {
+			cursor = interval.End
+		}
+		if !cursor.Before(to) {
+			return free
+		}
+	}
+	if cursor.Before(to) {
+		free = append(free, Interval{Start: cursor, End: to})
+	}
+	return free
+}
+
+// GetBlockedSpots возвращает множество заблокированных мест
+func GetBlockedSpots(db Queryer) (map[int]bool, error) {
+	rows, err := db.Query(`SELECT spot_number FROM blocked_spots WHERE is_blocked = true`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocked := map[int]bool{}
+	for rows.Next() {
+		var spot int
+		if err := rows.Scan(&spot); err != nil {
+			return nil, err
+		}
+		blocked[spot] = true
+	}
+
+	return blocked, rows.Err()
+}
+
+// GetBusyIntervals возвращает занятые бронями интервалы мест внутри окна [from, to),
+// обрезанные по границам окна и отсортированные по началу
+func GetBusyIntervals(db Queryer, from, to time.Time) (map[int][]Interval, error) {
+	rows, err := db.Query(`
+		SELECT parking_spot, GREATEST(lower(period), $1), LEAST(upper(period), $2)
+		FROM bookings
+		WHERE period && tstzrange($1, $2, '[)')
+		AND `+OccupyingStatusCondition+`
+		ORDER BY parking_spot, lower(period)
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	busy := map[int][]Interval{}
+	for rows.Next() {
+		var spot int
+		var interval Interval
+		if err := rows.Scan(&spot, &interval.Start, &interval.End); err != nil {
+			return nil, err
+		}
+		busy[spot] = append(busy[spot], interval)
+	}
+
+	return busy, rows.Err()
+}
+
+// GetAvailability возвращает доступность перечисленных мест в окне [from, to)
+func GetAvailability(db Queryer, spots []int, from, to time.Time) ([]SpotAvailability, error) {
+	blocked, err := GetBlockedSpots(db)
+	if err != nil {
+		return nil, err
+	}
+	busy, err := GetBusyIntervals(db, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]SpotAvailability, 0, len(spots))
+	for _, spot := range spots {
+		availability := SpotAvailability{
+			ParkingSpot:   spot,
+			Blocked:       blocked[spot],
+			FreeIntervals: []Interval{},
+		}
+		if !availability.Blocked {
+			availability.FreeIntervals = FreeIntervals(busy[spot], from, to)
+			availability.Free = len(busy[spot]) == 0
+		}
+		result = append(result, availability)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFreeIntervals(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2026, 3, 2, hour, 0, 0, 0, time.UTC)
+	}
+	span := func(from, to int) Interval {
+		return Interval{Start: at(from), End: at(to)}
+	}
+
+	tests := []struct {
+		name string
+		busy []Interval
+		want []Interval
+	}{
+		{name: "nothing busy", busy: nil, want: []Interval{span(8, 20)}},
+		{name: "busy in the middle", busy: []Interval{span(10, 12)}, want: []Interval{span(8, 10), span(12, 20)}},
+		{name: "busy at the window start", busy: []Interval{span(8, 9)}, want: []Interval{span(9, 20)}},
+		{name: "busy at the window end", busy: []Interval{span(18, 20)}, want: []Interval{span(8, 18)}},
+		{name: "busy beyond the window", busy: []Interval{span(6, 9), span(19, 22)}, want: []Interval{span(9, 19)}},
+		{name: "whole window busy", busy: []Interval{span(6, 22)}, want: []Interval{}},
+		{name: "adjacent busy intervals", busy: []Interval{span(10, 12), span(12, 14)}, want: []Interval{span(8, 10), span(14, 20)}},
+		{name: "overlapping busy intervals", busy: []Interval{span(10, 14), span(11, 13), span(13, 15)}, want: []Interval{span(8, 10), span(15, 20)}},
+		{name: "nested interval does not move the cursor back", busy: []Interval{span(9, 16), span(10, 11)}, want: []Interval{span(8, 9), span(16, 20)}},
+		{name: "several gaps", busy: []Interval{span(9, 10), span(12, 13), span(15, 16)}, want: []Interval{span(8, 9), span(10, 12), span(13, 15), span(16, 20)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FreeIntervals(tt.busy, at(8), at(20))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("FreeIntervals = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,36 +2,103 @@ package models
 
 import (
     "database/sql"
+    "errors"
     "time"
+
+    "github.com/lib/pq"
 )
 
+// ErrBookingConflict возвращается, когда бронь пересекается с другой бронью того же места
+var ErrBookingConflict = errors.New("booking overlaps an existing booking for this spot")
+
+// Код ошибки PostgreSQL exclusion_violation (ограничение bookings_no_overlap)
+const exclusionViolation = "23P01"
+
+// Queryer — общий интерфейс *sql.DB и *sql.Tx
+type Queryer interface {
+    Exec(query string, args ...interface{}) (sql.Result, error)
+    Query(query string, args ...interface{}) (*sql.Rows, error)
+    QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 type Booking struct {
-    ID          int       `json:"id"`
-    UserID      int       `json:"user_id"`
-    ParkingSpot int       `json:"parking_spot"`
-    CarNumber   string    `json:"car_number"`
-    ReservedAt  time.Time `json:"reserved_at"`
-    Hours       int       `json:"hours"`
+    ID            int           `json:"id"`
+    UserID        int           `json:"user_id"`
+    ParkingSpot   int           `json:"parking_spot"`
+    CarNumber     string        `json:"car_number"`
+    ReservedAt    time.Time     `json:"reserved_at"`
+    // PlannedEndsAt — плановое окончание брони без учета досрочного выезда
+    PlannedEndsAt time.Time     `json:"planned_ends_at"`
+    Status        BookingStatus `json:"status"`
+    // EndsAt — фактическое окончание: плановое или время досрочного выезда
+    EndsAt        time.Time     `json:"ends_at"`
+    CheckedInAt   *time.Time    `json:"checked_in_at,omitempty"`
+    CheckedOutAt  *time.Time    `json:"checked_out_at,omitempty"`
+    SeriesID      *int          `json:"series_id,omitempty"`
+    VehicleID     *int          `json:"vehicle_id,omitempty"`
+    // Price — стоимость в копейках, зафиксированная при бронировании; nil у старых броней
+    Price         *int64        `json:"price,omitempty"`
+    Currency      *string       `json:"currency,omitempty"`
+    // PaymentID — платеж, после подтверждения которого бронь становится активной
+    PaymentID     *int          `json:"payment_id,omitempty"`
 }
 
-func CreateBooking(db *sql.DB, booking *Booking) (int, error) {
+// CreateBooking сохраняет бронь; пересечение с другой бронью возвращается как ErrBookingConflict
+func CreateBooking(db Queryer, booking *Booking) (int, error) {
+    if booking.Status == "" {
+        booking.Status = StatusActive
+    }
+    if !booking.Status.Valid() {
+        return 0, ErrInvalidStatus
+    }
+
     var id int
     err := db.QueryRow(`
-        INSERT INTO bookings (user_id, parking_spot, car_number, reserved_at, hours)
-        VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO bookings (user_id, parking_spot, car_number, reserved_at, ends_at, status, series_id, vehicle_id, price, currency)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
         RETURNING id
-    `, booking.UserID, booking.ParkingSpot, booking.CarNumber, booking.ReservedAt, booking.Hours).Scan(&id)
+    `, booking.UserID, booking.ParkingSpot, booking.CarNumber, booking.ReservedAt, booking.PlannedEndsAt,
+        string(booking.Status), booking.SeriesID, booking.VehicleID, booking.Price, booking.Currency).Scan(&id)
+
+    return id, TranslateBookingError(err)
+}
+
+// TranslateBookingError превращает нарушение ограничения bookings_no_overlap в ErrBookingConflict
+func TranslateBookingError(err error) error {
+    var pqErr *pq.Error
+    if errors.As(err, &pqErr) && pqErr.Code == exclusionViolation {
+        return ErrBookingConflict
+    }
+    return err
+}
+
+// HasOverlappingBooking проверяет, есть ли у места занимающая его бронь, пересекающая интервал [start, end)
+func HasOverlappingBooking(db Queryer, spotNumber int, start, end time.Time) (bool, error) {
+    var exists bool
+    err := db.QueryRow(`
+        SELECT EXISTS(
+            SELECT 1
+            FROM bookings
+            WHERE parking_spot = $1
+            AND period && tstzrange($2, $3, '[)')
+            AND ` + OccupyingStatusCondition + `
+        )
+    `, spotNumber, start, end).Scan(&exists)
 
-    return id, err
+    return exists, err
 }
 
-func GetOccupiedParkingSpots(db *sql.DB) ([]int, error) {
+// GetOccupiedParkingSpots возвращает места, занятые в данный момент; lotID = 0 — на всех парковках
+func GetOccupiedParkingSpots(db *sql.DB, lotID int) ([]int, error) {
     spots := []int{}
     rows, err := db.Query(`
-        SELECT DISTINCT parking_spot
-        FROM bookings
-        WHERE reserved_at + (hours * interval '1 hour') > NOW()
-    `)
+        SELECT DISTINCT b.parking_spot
+        FROM bookings b
+        JOIN spots s ON s.number = b.parking_spot
+        WHERE b.period @> NOW()
+        AND b.` + OccupyingStatusCondition + `
+        AND ($1 = 0 OR s.lot_id = $1)
+    `, lotID)
     if err != nil {
         return nil, err
     }
@@ -45,7 +112,7 @@ func GetOccupiedParkingSpots(db *sql.DB) ([]int, error) {
         spots = append(spots, spot)
     }
 
-    return spots, nil
+    return spots, rows.Err()
 }
 
 func IsParkingSpotOccupied(db *sql.DB, spotNumber int) (bool, error) {
@@ -54,8 +121,89 @@ func IsParkingSpotOccupied(db *sql.DB, spotNumber int) (bool, error) {
         SELECT COUNT(*)
         FROM bookings
         WHERE parking_spot = $1
-        AND reserved_at + (hours * interval '1 hour') > NOW()
+        AND period @> NOW()
+        AND ` + OccupyingStatusCondition + `
     `, spotNumber).Scan(&count)
 
     return count > 0, err
-}
\ No newline at end of file
+}
+
+// ExtendBooking переносит плановое окончание брони на newEnd и возвращает новое время окончания.
+// Пересечение продленного интервала с чужой бронью возвращается как ErrBookingConflict
+func ExtendBooking(db Queryer, bookingID int, newEnd time.Time) (time.Time, error) {
+    var endsAt time.Time
+    err := db.QueryRow(`
+        UPDATE bookings
+        SET ends_at = $2
+        WHERE id = $1
+        RETURNING upper(period)
+    `, bookingID, newEnd).Scan(&endsAt)
+    if err == sql.ErrNoRows {
+        return endsAt, ErrBookingNotFound
+    }
+
+    return endsAt, TranslateBookingError(err)
+}
+
+// SetBookingPrice сохраняет пересчитанную стоимость брони
+func SetBookingPrice(db Queryer, bookingID int, price int64, currency string) error {
+    _, err := db.Exec(`UPDATE bookings SET price = $2, currency = $3 WHERE id = $1`, bookingID, price, currency)
+    return err
+}
+
+// GetBooking возвращает бронь по ID или ErrBookingNotFound
+func GetBooking(db Queryer, bookingID int) (*Booking, error) {
+    return scanBooking(db.QueryRow(`
+        SELECT ` + bookingColumns + `
+        FROM bookings
+        WHERE id = $1
+    `, bookingID))
+}
+
+// LockBooking возвращает бронь по ID и блокирует ее строку до конца транзакции
+func LockBooking(tx *sql.Tx, bookingID int) (*Booking, error) {
+    return scanBooking(tx.QueryRow(`
+        SELECT ` + bookingColumns + `
+        FROM bookings
+        WHERE id = $1
+        FOR UPDATE
+    `, bookingID))
+}
+
+// Колонки брони в порядке, который ожидает scanBookingRow
+const bookingColumns = "id, user_id, parking_spot, car_number, reserved_at, ends_at, status, upper(period), checked_in_at, checked_out_at, series_id, vehicle_id, price, currency, payment_id"
+
+// rowScanner — общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
+
+func scanBookingRow(row rowScanner, booking *Booking) error {
+    return row.Scan(&booking.ID, &booking.UserID, &booking.ParkingSpot, &booking.CarNumber,
+        &booking.ReservedAt, &booking.PlannedEndsAt, &booking.Status, &booking.EndsAt, &booking.CheckedInAt, &booking.CheckedOutAt, &booking.SeriesID, &booking.VehicleID,
+        &booking.Price, &booking.Currency, &booking.PaymentID)
+}
+
+// LockUserBooking блокирует бронь, только если она принадлежит пользователю; иначе ErrBookingNotFound
+func LockUserBooking(tx *sql.Tx, bookingID, userID int) (*Booking, error) {
+    booking, err := LockBooking(tx, bookingID)
+    if err != nil {
+        return nil, err
+    }
+    if booking.UserID != userID {
+        return nil, ErrBookingNotFound
+    }
+    return booking, nil
+}
+
+func scanBooking(row *sql.Row) (*Booking, error) {
+    var booking Booking
+    err := scanBookingRow(row, &booking)
+    if err == sql.ErrNoRows {
+        return nil, ErrBookingNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &booking, nil
+}
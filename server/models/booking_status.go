@@ -0,0 +1,177 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// BookingStatus — состояние брони в жизненном цикле
+type BookingStatus string
+
+const (
+	StatusPending   BookingStatus = "pending"
+	StatusActive    BookingStatus = "active"
+	StatusCheckedIn BookingStatus = "checked_in"
+	StatusCompleted BookingStatus = "completed"
+	StatusCancelled BookingStatus = "cancelled"
+	StatusNoShow    BookingStatus = "no_show"
+)
+
+var (
+	ErrBookingNotFound   = errors.New("booking not found")
+	ErrInvalidStatus     = errors.New("unknown booking status")
+	ErrInvalidTransition = errors.New("booking status transition is not allowed")
+)
+
+// Разрешенные переходы; completed, cancelled и no_show — конечные состояния
+var bookingTransitions = map[BookingStatus][]BookingStatus{
+	StatusPending:   {StatusActive, StatusCancelled},
+	StatusActive:    {StatusCheckedIn, StatusCompleted, StatusCancelled, StatusNoShow},
+	StatusCheckedIn: {StatusCompleted, StatusCancelled},
+}
+
+// OccupyingStatusCondition — SQL-условие для броней, которые занимают место.
+// Должно совпадать с Occupies и с условием ограничения bookings_no_overlap.
+const OccupyingStatusCondition = "status IN ('pending', 'active', 'checked_in')"
+
+// Valid сообщает, является ли строка известным статусом
+func (s BookingStatus) Valid() bool {
+	switch s {
+	case StatusPending, StatusActive, StatusCheckedIn, StatusCompleted, StatusCancelled, StatusNoShow:
+		return true
+	}
+	return false
+}
+
+// Occupies сообщает, занимает ли бронь в этом статусе парковочное место
+func (s BookingStatus) Occupies() bool {
+	return s == StatusPending || s == StatusActive || s == StatusCheckedIn
+}
+
+// CanTransitionTo проверяет, разрешен ли переход в статус next
+func (s BookingStatus) CanTransitionTo(next BookingStatus) bool {
+	for _, allowed := range bookingTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// statusesLeadingTo возвращает все статусы, из которых разрешен переход в next
+func statusesLeadingTo(next BookingStatus) []string {
+	var from []string
+	for status, targets := range bookingTransitions {
+		for _, target := range targets {
+			if target == next {
+				from = append(from, string(status))
+			}
+		}
+	}
+	return from
+}
+
+// TransitionBookingStatus атомарно переводит бронь в статус next, если это разрешено из текущего статуса
+func TransitionBookingStatus(db Queryer, bookingID int, next BookingStatus) error {
+	return transitionBooking(db, bookingID, next, "")
+}
+
+// CancelBooking отменяет бронь, запоминая, кто и почему ее отменил
+func CancelBooking(db Queryer, bookingID, cancelledBy int, reason string) error {
+	return transitionBooking(db, bookingID, StatusCancelled,
+		"cancelled_at = NOW(), cancelled_by = NULLIF($4, 0), cancellation_reason = NULLIF($5, '')",
+		cancelledBy, reason)
+}
+
+// CheckInBooking отмечает прибытие владельца брони
+func CheckInBooking(db Queryer, bookingID int) error {
+	return transitionBooking(db, bookingID, StatusCheckedIn, "checked_in_at = NOW()")
+}
+
+// MarkNoShows переводит в no_show активные брони без регистрации, у которых истек срок ожидания,
+// и возвращает освобожденные брони. Каждая бронь проходит через таблицу переходов: если ее статус
+// успели изменить (например, владелец зарегистрировался), она пропускается
+func MarkNoShows(db Queryer, grace time.Duration) ([]Booking, error) {
+	rows, err := db.Query(`
+		SELECT `+bookingColumns+`
+		FROM bookings
+		WHERE status = 'active'
+		AND reserved_at + $1 * interval '1 second' <= NOW()
+		AND upper(period) > NOW()
+		ORDER BY id
+	`, grace.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	var candidates []Booking
+	for rows.Next() {
+		var booking Booking
+		if err := scanBookingRow(rows, &booking); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, booking)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	released := []Booking{}
+	for _, booking := range candidates {
+		err := transitionBooking(db, booking.ID, StatusNoShow, "")
+		if errors.Is(err, ErrInvalidTransition) || errors.Is(err, ErrBookingNotFound) {
+			continue
+		}
+		if err != nil {
+			return released, err
+		}
+		booking.Status = StatusNoShow
+		released = append(released, booking)
+	}
+
+	return released, nil
+}
+
+// CheckOutBooking завершает бронь досрочным выездом; интервал брони сокращается до текущего момента
+func CheckOutBooking(db Queryer, bookingID int) error {
+	return transitionBooking(db, bookingID, StatusCompleted, "checked_out_at = NOW()")
+}
+
+// transitionBooking меняет статус и, при необходимости, дополнительные колонки (параметры с $4)
+func transitionBooking(db Queryer, bookingID int, next BookingStatus, extraSet string, extraArgs ...interface{}) error {
+	set := "status = $2"
+	if extraSet != "" {
+		set += ", " + extraSet
+	}
+	args := append([]interface{}{bookingID, string(next), pq.Array(statusesLeadingTo(next))}, extraArgs...)
+
+	result, err := db.Exec(`
+		UPDATE bookings
+		SET `+set+`
+		WHERE id = $1 AND status = ANY($3)
+	`, args...)
+	if err != nil {
+		return TranslateBookingError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	// Ничего не обновили: либо брони нет, либо переход из текущего статуса запрещен
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM bookings WHERE id = $1)`, bookingID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBookingNotFound
+	}
+	return ErrInvalidTransition
+}
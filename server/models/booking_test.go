@@ -0,0 +1,52 @@
+package models
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"server/testutil"
+)
+
+func TestCreateBookingConcurrentSameSpot(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userID := testutil.CreateUser(t, db, "race@example.com")
+
+	const attempts = 10
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := CreateBooking(db, &Booking{
+				UserID:        userID,
+				ParkingSpot:   1,
+				CarNumber:     "A123BC77",
+				ReservedAt:    start,
+				PlannedEndsAt: start.Add(time.Hour),
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created, conflicts := 0, 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, ErrBookingConflict):
+			conflicts++
+		default:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if created != 1 || conflicts != attempts-1 {
+		t.Fatalf("created %d bookings and got %d conflicts, want 1 and %d", created, conflicts, attempts-1)
+	}
+}
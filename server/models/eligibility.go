@@ -0,0 +1,87 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Коды отказа, когда место не подходит машине или пользователю
+const (
+	PolicyEVOnly         = "ev_vehicle_required"
+	PolicyAccessibleOnly = "accessibility_permit_required"
+	PolicyMotorcycleOnly = "motorcycle_only"
+)
+
+// bookingVehicle возвращает машину брони: по VehicleID, а для брони по номеру — машину
+// из реестра пользователя с тем же номером; nil, если машина не зарегистрирована
+func bookingVehicle(db Queryer, booking Booking) (*Vehicle, error) {
+	var vehicle Vehicle
+	var err error
+	if booking.VehicleID != nil {
+		err = scanVehicleRow(db.QueryRow(`
+			SELECT `+vehicleColumns+`
+			FROM vehicles
+			WHERE id = $1
+		`, *booking.VehicleID), &vehicle)
+	} else {
+		err = scanVehicleRow(db.QueryRow(`
+			SELECT `+vehicleColumns+`
+			FROM vehicles
+			WHERE user_id = $1 AND plate = $2
+			ORDER BY id
+			LIMIT 1
+		`, booking.UserID, strings.ToUpper(booking.CarNumber)), &vehicle)
+	}
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &vehicle, nil
+}
+
+// CheckSpotEligibility проверяет, что пользователь и машина брони могут занимать место:
+// на место с зарядкой — только электромобиль, на место для людей с инвалидностью —
+// только пользователь с разрешением, на место для мотоциклов — только мотоцикл.
+// Отказ возвращается как *PolicyViolation
+func CheckSpotEligibility(db Queryer, booking Booking) error {
+	spot, err := GetSpot(db, booking.ParkingSpot)
+	if err != nil {
+		return err
+	}
+
+	if spot.HasFeature(FeatureAccessible) {
+		permit, err := HasAccessibilityPermit(db, booking.UserID)
+		if err != nil {
+			return err
+		}
+		if !permit {
+			return &PolicyViolation{
+				Code:    PolicyAccessibleOnly,
+				Message: "This spot is reserved for holders of an accessibility permit",
+			}
+		}
+	}
+
+	if !spot.HasFeature(FeatureEVCharger) && !spot.HasFeature(FeatureMotorcycle) {
+		return nil
+	}
+	vehicle, err := bookingVehicle(db, booking)
+	if err != nil {
+		return err
+	}
+	if spot.HasFeature(FeatureEVCharger) && (vehicle == nil || !vehicle.IsEV) {
+		return &PolicyViolation{
+			Code:    PolicyEVOnly,
+			Message: "This spot is reserved for registered electric vehicles",
+		}
+	}
+	if spot.HasFeature(FeatureMotorcycle) && (vehicle == nil || vehicle.SizeClass != SizeMotorcycle) {
+		return &PolicyViolation{
+			Code:    PolicyMotorcycleOnly,
+			Message: "This spot fits only registered motorcycles",
+		}
+	}
+	return nil
+}
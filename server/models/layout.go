@@ -0,0 +1,276 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Виды объектов на схеме парковки
+const (
+	LayoutSpot     = "spot"
+	LayoutEntrance = "entrance"
+	LayoutLane     = "lane"
+)
+
+// Текущее состояние места на карте
+const (
+	SpotStatusFree     = "free"
+	SpotStatusOccupied = "occupied"
+	SpotStatusBlocked  = "blocked"
+	SpotStatusRetired  = "retired"
+)
+
+// Максимальное число объектов на схеме одной парковки
+const maxLayoutFeatures = 5000
+
+var ErrLayoutNotFound = errors.New("lot layout not found")
+
+// LayoutError описывает ошибку в загруженной схеме
+type LayoutError struct {
+	Message string
+}
+
+func (e *LayoutError) Error() string {
+	return e.Message
+}
+
+func layoutErrorf(format string, args ...interface{}) error {
+	return &LayoutError{Message: fmt.Sprintf(format, args...)}
+}
+
+// Geometry — геометрия GeoJSON; координаты проверяются по типу при валидации схемы
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// LayoutFeature — объект схемы. properties.kind задает вид объекта,
+// properties.spot — номер места для объектов вида spot
+type LayoutFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Layout — схема парковки в формате GeoJSON FeatureCollection
+type Layout struct {
+	Type     string          `json:"type"`
+	Features []LayoutFeature `json:"features"`
+}
+
+// Kind возвращает вид объекта схемы
+func (f LayoutFeature) Kind() string {
+	kind, _ := f.Properties["kind"].(string)
+	return kind
+}
+
+// SpotNumber возвращает номер места объекта вида spot или 0
+func (f LayoutFeature) SpotNumber() int {
+	number, ok := f.Properties["spot"].(float64)
+	if !ok || number != float64(int(number)) {
+		return 0
+	}
+	return int(number)
+}
+
+// Допустимые типы геометрии для каждого вида объекта
+var layoutGeometries = map[string][]string{
+	LayoutSpot:     {"Polygon", "Point"},
+	LayoutEntrance: {"Point"},
+	LayoutLane:     {"LineString", "MultiLineString"},
+}
+
+// Validate проверяет схему: структуру GeoJSON, виды объектов, геометрию и то,
+// что каждое место из spots встречается на схеме не более одного раза
+func (l Layout) Validate(spots map[int]bool) error {
+	if l.Type != "FeatureCollection" {
+		return layoutErrorf("Layout must be a GeoJSON FeatureCollection")
+	}
+	if len(l.Features) > maxLayoutFeatures {
+		return layoutErrorf("Layout has more than %d features", maxLayoutFeatures)
+	}
+
+	seen := map[int]bool{}
+	for i, feature := range l.Features {
+		if err := feature.validate(); err != nil {
+			return layoutErrorf("Feature %d: %v", i, err)
+		}
+		if feature.Kind() != LayoutSpot {
+			continue
+		}
+		number := feature.SpotNumber()
+		if !spots[number] {
+			return layoutErrorf("Feature %d: spot %v does not belong to this lot", i, feature.Properties["spot"])
+		}
+		if seen[number] {
+			return layoutErrorf("Feature %d: spot %d appears more than once", i, number)
+		}
+		seen[number] = true
+	}
+	return nil
+}
+
+func (f LayoutFeature) validate() error {
+	if f.Type != "Feature" {
+		return errors.New("type must be Feature")
+	}
+	allowed, ok := layoutGeometries[f.Kind()]
+	if !ok {
+		return errors.New("properties.kind must be spot, entrance or lane")
+	}
+	for _, geometry := range allowed {
+		if f.Geometry.Type == geometry {
+			return validateCoordinates(f.Geometry)
+		}
+	}
+	return fmt.Errorf("geometry of a %s must be one of %v", f.Kind(), allowed)
+}
+
+// validateCoordinates проверяет вложенность и количество координат для типа геометрии
+func validateCoordinates(geometry Geometry) error {
+	switch geometry.Type {
+	case "Point":
+		var point []float64
+		if json.Unmarshal(geometry.Coordinates, &point) != nil || len(point) < 2 {
+			return errors.New("a Point needs a [x, y] position")
+		}
+	case "LineString":
+		var line [][]float64
+		if json.Unmarshal(geometry.Coordinates, &line) != nil || !validLine(line, 2) {
+			return errors.New("a LineString needs at least two positions")
+		}
+	case "MultiLineString":
+		var lines [][][]float64
+		if json.Unmarshal(geometry.Coordinates, &lines) != nil || len(lines) == 0 {
+			return errors.New("a MultiLineString needs at least one line")
+		}
+		for _, line := range lines {
+			if !validLine(line, 2) {
+				return errors.New("each line needs at least two positions")
+			}
+		}
+	case "Polygon":
+		var rings [][][]float64
+		if json.Unmarshal(geometry.Coordinates, &rings) != nil || len(rings) == 0 {
+			return errors.New("a Polygon needs at least one ring")
+		}
+		for _, ring := range rings {
+			if !validLine(ring, 4) || !samePosition(ring[0], ring[len(ring)-1]) {
+				return errors.New("each ring needs at least four positions and must be closed")
+			}
+		}
+	}
+	return nil
+}
+
+func validLine(line [][]float64, minPositions int) bool {
+	if len(line) < minPositions {
+		return false
+	}
+	for _, position := range line {
+		if len(position) < 2 {
+			return false
+		}
+	}
+	return true
+}
+
+func samePosition(a, b []float64) bool {
+	return a[0] == b[0] && a[1] == b[1]
+}
+
+// GetLotLayout возвращает схему парковки и время ее изменения или ErrLayoutNotFound
+func GetLotLayout(db Queryer, lotID int) (*Layout, time.Time, error) {
+	return scanLayout(db.QueryRow(`SELECT geojson, updated_at FROM lot_layouts WHERE lot_id = $1`, lotID))
+}
+
+// LockLotLayout возвращает схему и блокирует ее до конца транзакции для изменения по частям
+func LockLotLayout(tx *sql.Tx, lotID int) (*Layout, time.Time, error) {
+	return scanLayout(tx.QueryRow(`SELECT geojson, updated_at FROM lot_layouts WHERE lot_id = $1 FOR UPDATE`, lotID))
+}
+
+func scanLayout(row *sql.Row) (*Layout, time.Time, error) {
+	var raw []byte
+	var updatedAt time.Time
+	err := row.Scan(&raw, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, updatedAt, ErrLayoutNotFound
+	}
+	if err != nil {
+		return nil, updatedAt, err
+	}
+
+	var layout Layout
+	if err := json.Unmarshal(raw, &layout); err != nil {
+		return nil, updatedAt, err
+	}
+	return &layout, updatedAt, nil
+}
+
+// SaveLotLayout сохраняет схему парковки, заменяя предыдущую
+func SaveLotLayout(db Queryer, lotID int, layout *Layout) error {
+	raw, err := json.Marshal(layout)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO lot_layouts (lot_id, geojson, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (lot_id) DO UPDATE SET geojson = EXCLUDED.geojson, updated_at = NOW()
+	`, lotID, raw)
+	return err
+}
+
+// DeleteLotLayout удаляет схему парковки
+func DeleteLotLayout(db Queryer, lotID int) error {
+	result, err := db.Exec(`DELETE FROM lot_layouts WHERE lot_id = $1`, lotID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrLayoutNotFound
+	}
+	return nil
+}
+
+// GetSpotStatuses возвращает текущее состояние каждого места парковки
+func GetSpotStatuses(db Queryer, lotID int) (map[int]string, error) {
+	rows, err := db.Query(`
+		SELECT s.number,
+			CASE
+				WHEN s.retired_at IS NOT NULL THEN $2
+				WHEN EXISTS (
+					SELECT 1 FROM blocked_spots b
+					WHERE b.spot_number = s.number AND b.is_blocked = true
+				) THEN $3
+				WHEN EXISTS (
+					SELECT 1 FROM bookings
+					WHERE parking_spot = s.number
+					AND period @> NOW()
+					AND `+OccupyingStatusCondition+`
+				) THEN $4
+				ELSE $5
+			END
+		FROM spots s
+		WHERE s.lot_id = $1
+	`, lotID, SpotStatusRetired, SpotStatusBlocked, SpotStatusOccupied, SpotStatusFree)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := map[int]string{}
+	for rows.Next() {
+		var number int
+		var status string
+		if err := rows.Scan(&number, &status); err != nil {
+			return nil, err
+		}
+		statuses[number] = status
+	}
+
+	return statuses, rows.Err()
+}
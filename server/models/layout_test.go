@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLayoutValidate(t *testing.T) {
+	const (
+		square = `{"type": "Polygon", "coordinates": [[[0, 0], [0, 1], [1, 1], [1, 0], [0, 0]]]}`
+		point  = `{"type": "Point", "coordinates": [5, 5]}`
+		lane   = `{"type": "LineString", "coordinates": [[0, 0], [10, 0]]}`
+	)
+	feature := func(kind, geometry string) string {
+		return `{"type": "Feature", "geometry": ` + geometry + `, "properties": {"kind": "` + kind + `"}}`
+	}
+	spot := func(number string) string {
+		return `{"type": "Feature", "geometry": ` + square + `, "properties": {"kind": "spot", "spot": ` + number + `}}`
+	}
+	collection := func(features ...string) string {
+		return `{"type": "FeatureCollection", "features": [` + strings.Join(features, ", ") + `]}`
+	}
+
+	tests := []struct {
+		name    string
+		geojson string
+		// wantErr — часть текста ошибки; пустая строка означает корректную схему
+		wantErr string
+	}{
+		{name: "empty collection", geojson: collection()},
+		{name: "spots, entrance and lane", geojson: collection(spot("1"), spot("2"), feature(LayoutEntrance, point), feature(LayoutLane, lane))},
+		{name: "spot as a point", geojson: collection(`{"type": "Feature", "geometry": ` + point + `, "properties": {"kind": "spot", "spot": 3}}`)},
+		{
+			name:    "multi line lane",
+			geojson: collection(feature(LayoutLane, `{"type": "MultiLineString", "coordinates": [[[0, 0], [1, 0]], [[1, 0], [1, 1]]]}`)),
+		},
+		{name: "not a feature collection", geojson: `{"type": "Feature", "features": []}`, wantErr: "FeatureCollection"},
+		{name: "feature of a wrong type", geojson: collection(`{"type": "Point", "geometry": ` + point + `, "properties": {"kind": "entrance"}}`), wantErr: "type must be Feature"},
+		{name: "unknown kind", geojson: collection(feature("tree", point)), wantErr: "properties.kind"},
+		{name: "entrance as a polygon", geojson: collection(feature(LayoutEntrance, square)), wantErr: "geometry of a entrance"},
+		{name: "point without y", geojson: collection(feature(LayoutEntrance, `{"type": "Point", "coordinates": [5]}`)), wantErr: "[x, y]"},
+		{name: "lane with one position", geojson: collection(feature(LayoutLane, `{"type": "LineString", "coordinates": [[0, 0]]}`)), wantErr: "at least two positions"},
+		{
+			name:    "open polygon ring",
+			geojson: collection(`{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [0, 1], [1, 1], [1, 0]]]}, "properties": {"kind": "spot", "spot": 1}}`),
+			wantErr: "must be closed",
+		},
+		{name: "spot of another lot", geojson: collection(spot("9")), wantErr: "does not belong to this lot"},
+		{name: "spot without a number", geojson: collection(spot(`"one"`)), wantErr: "does not belong to this lot"},
+		{name: "fractional spot number", geojson: collection(spot("1.5")), wantErr: "does not belong to this lot"},
+		{name: "duplicate spot", geojson: collection(spot("1"), spot("2"), spot("1")), wantErr: "Feature 2: spot 1 appears more than once"},
+	}
+
+	lotSpots := map[int]bool{1: true, 2: true, 3: true}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var layout Layout
+			if err := json.Unmarshal([]byte(tt.geojson), &layout); err != nil {
+				t.Fatalf("unmarshal layout: %v", err)
+			}
+			err := layout.Validate(lotSpots)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			var layoutErr *LayoutError
+			if !errors.As(err, &layoutErr) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate error %v, want a layout error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
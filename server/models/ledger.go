@@ -0,0 +1,231 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// LedgerKind — вид операции журнала
+type LedgerKind string
+
+const (
+	// LedgerCharge — начисление стоимости брони
+	LedgerCharge LedgerKind = "charge"
+	// LedgerPayment — поступление оплаты от провайдера
+	LedgerPayment LedgerKind = "payment"
+	// LedgerRefund — возврат денег пользователю через провайдера или на его баланс
+	LedgerRefund LedgerKind = "refund"
+	// LedgerAdjustment — ручная корректировка баланса администратором
+	LedgerAdjustment LedgerKind = "adjustment"
+	// LedgerGrant — зачисление средств организации на баланс пользователя
+	LedgerGrant LedgerKind = "grant"
+)
+
+// LedgerAccount — счет журнала
+type LedgerAccount string
+
+const (
+	// AccountUser — расчеты с пользователем: дебет — его долг, кредит — его предоплата
+	AccountUser LedgerAccount = "user"
+	// AccountRevenue — выручка парковки
+	AccountRevenue LedgerAccount = "revenue"
+	// AccountProvider — деньги, поступившие через платежного провайдера
+	AccountProvider LedgerAccount = "provider"
+	// AccountGrants — средства, выделенные организацией на балансы пользователей
+	AccountGrants LedgerAccount = "grants"
+)
+
+// ErrUnbalancedTransaction возвращается для операции, сумма проводок которой не равна нулю
+var ErrUnbalancedTransaction = errors.New("ledger transaction is not balanced")
+
+// LedgerEntry — проводка по счету: плюс — дебет, минус — кредит
+type LedgerEntry struct {
+	Account LedgerAccount `json:"account"`
+	UserID  *int          `json:"userId,omitempty"`
+	Amount  int64         `json:"amount"`
+}
+
+// LedgerTransaction — операция журнала из проводок с нулевой суммой
+type LedgerTransaction struct {
+	ID          int        `json:"id"`
+	Kind        LedgerKind `json:"kind"`
+	UserID      int        `json:"userId"`
+	BookingID   *int       `json:"bookingId,omitempty"`
+	PaymentID   *int       `json:"paymentId,omitempty"`
+	Currency    string     `json:"currency"`
+	Description string     `json:"description,omitempty"`
+	CreatedBy   *int       `json:"createdBy,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	// Amount — изменение баланса пользователя: плюс — зачисление, минус — списание
+	Amount  int64         `json:"amount"`
+	Entries []LedgerEntry `json:"-"`
+}
+
+// userEntry — проводка по счету пользователя
+func userEntry(userID int, amount int64) LedgerEntry {
+	return LedgerEntry{Account: AccountUser, UserID: &userID, Amount: amount}
+}
+
+// PostTransaction записывает операцию и ее проводки. Баланс проверяется здесь
+// и еще раз отложенным триггером базы при фиксации транзакции, поэтому запись идет только в транзакции.
+// Для несуществующего пользователя возвращает ErrUserNotFound
+func PostTransaction(tx *sql.Tx, transaction *LedgerTransaction) error {
+	var sum int64
+	for _, entry := range transaction.Entries {
+		sum += entry.Amount
+	}
+	if sum != 0 || len(transaction.Entries) == 0 {
+		return ErrUnbalancedTransaction
+	}
+
+	err := tx.QueryRow(`
+		INSERT INTO ledger_transactions (kind, user_id, booking_id, payment_id, currency, description, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, string(transaction.Kind), transaction.UserID, transaction.BookingID, transaction.PaymentID,
+		transaction.Currency, transaction.Description, transaction.CreatedBy).
+		Scan(&transaction.ID, &transaction.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	transaction.Amount = 0
+	for _, entry := range transaction.Entries {
+		_, err := tx.Exec(`
+			INSERT INTO ledger_entries (transaction_id, account, user_id, amount)
+			VALUES ($1, $2, $3, $4)
+		`, transaction.ID, string(entry.Account), entry.UserID, entry.Amount)
+		if err != nil {
+			return err
+		}
+		if entry.Account == AccountUser {
+			transaction.Amount -= entry.Amount
+		}
+	}
+	return nil
+}
+
+// PostCharge начисляет пользователю стоимость брони; нулевая сумма не записывается
+func PostCharge(tx *sql.Tx, userID, bookingID int, amount int64, currency string) error {
+	if amount == 0 {
+		return nil
+	}
+	return PostTransaction(tx, &LedgerTransaction{
+		Kind:      LedgerCharge,
+		UserID:    userID,
+		BookingID: &bookingID,
+		Currency:  currency,
+		Entries: []LedgerEntry{
+			userEntry(userID, amount),
+			{Account: AccountRevenue, Amount: -amount},
+		},
+	})
+}
+
+// PostPayment зачисляет пользователю подтвержденный платеж
+func PostPayment(tx *sql.Tx, payment Payment) error {
+	return PostTransaction(tx, &LedgerTransaction{
+		Kind:      LedgerPayment,
+		UserID:    payment.UserID,
+		PaymentID: &payment.ID,
+		Currency:  payment.Currency,
+		Entries: []LedgerEntry{
+			{Account: AccountProvider, Amount: payment.Amount},
+			userEntry(payment.UserID, -payment.Amount),
+		},
+	})
+}
+
+// PostRefund записывает возврат amount по платежу. Если указана бронь, возврат сначала
+// сторнирует ее начисление; иначе деньги возвращаются из предоплаты пользователя
+func PostRefund(tx *sql.Tx, payment Payment, amount int64, bookingID, createdBy *int) error {
+	entries := []LedgerEntry{}
+	if bookingID != nil {
+		entries = append(entries,
+			LedgerEntry{Account: AccountRevenue, Amount: amount},
+			userEntry(payment.UserID, -amount))
+	}
+	entries = append(entries,
+		userEntry(payment.UserID, amount),
+		LedgerEntry{Account: AccountProvider, Amount: -amount})
+
+	return PostTransaction(tx, &LedgerTransaction{
+		Kind:      LedgerRefund,
+		UserID:    payment.UserID,
+		BookingID: bookingID,
+		PaymentID: &payment.ID,
+		Currency:  payment.Currency,
+		CreatedBy: createdBy,
+		Entries:   entries,
+	})
+}
+
+// PostAdjustment корректирует баланс пользователя: положительная сумма зачисляется ему за счет выручки,
+// отрицательная списывается в выручку
+func PostAdjustment(tx *sql.Tx, userID int, amount int64, currency, description string, createdBy int) (*LedgerTransaction, error) {
+	transaction := &LedgerTransaction{
+		Kind:        LedgerAdjustment,
+		UserID:      userID,
+		Currency:    currency,
+		Description: description,
+		CreatedBy:   &createdBy,
+		Entries: []LedgerEntry{
+			userEntry(userID, -amount),
+			{Account: AccountRevenue, Amount: amount},
+		},
+	}
+	if err := PostTransaction(tx, transaction); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// UserBalance возвращает баланс пользователя в валюте: плюс — предоплата, минус — долг
+func UserBalance(db Queryer, userID int, currency string) (int64, error) {
+	var balance int64
+	err := db.QueryRow(`
+		SELECT -COALESCE(SUM(e.amount), 0)
+		FROM ledger_entries e
+		JOIN ledger_transactions t ON t.id = e.transaction_id
+		WHERE e.account = 'user' AND e.user_id = $1 AND t.currency = $2
+	`, userID, currency).Scan(&balance)
+	return balance, err
+}
+
+// ListUserTransactions возвращает последние limit операций пользователя, начиная с последней
+func ListUserTransactions(db Queryer, userID, limit int) ([]LedgerTransaction, error) {
+	rows, err := db.Query(`
+		SELECT t.id, t.kind, t.user_id, t.booking_id, t.payment_id, t.currency, t.description, t.created_by, t.created_at,
+			-COALESCE(SUM(e.amount) FILTER (WHERE e.account = 'user'), 0)
+		FROM ledger_transactions t
+		JOIN ledger_entries e ON e.transaction_id = t.id
+		WHERE t.user_id = $1
+		GROUP BY t.id
+		ORDER BY t.created_at DESC, t.id DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []LedgerTransaction{}
+	for rows.Next() {
+		var transaction LedgerTransaction
+		err := rows.Scan(&transaction.ID, &transaction.Kind, &transaction.UserID, &transaction.BookingID,
+			&transaction.PaymentID, &transaction.Currency, &transaction.Description, &transaction.CreatedBy,
+			&transaction.CreatedAt, &transaction.Amount)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
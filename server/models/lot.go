@@ -0,0 +1,235 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Формат времени суток в часах работы парковки
+const ClockLayout = "15:04"
+
+var (
+	ErrLotNotFound  = errors.New("parking lot not found")
+	ErrLotExists    = errors.New("parking lot with this name already exists")
+	ErrZoneNotFound = errors.New("zone not found")
+	ErrZoneExists   = errors.New("zone with this name already exists")
+	ErrLotClosed    = errors.New("booking is outside the lot's opening hours")
+)
+
+// Lot — парковка со своим часовым поясом и часами работы
+type Lot struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	// Timezone — часовой пояс IANA; пустой означает часовой пояс сервиса по умолчанию
+	Timezone string `json:"timezone,omitempty"`
+	// OpensAt и ClosesAt — часы работы в формате 15:04; nil — круглосуточно
+	OpensAt   *string   `json:"opensAt,omitempty"`
+	ClosesAt  *string   `json:"closesAt,omitempty"`
+	Zones     []Zone    `json:"zones"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Zone — зона внутри парковки (этаж, сектор)
+type Zone struct {
+	ID    int    `json:"id"`
+	LotID int    `json:"lotId"`
+	Name  string `json:"name"`
+}
+
+// Location возвращает часовой пояс парковки или def, если он не задан
+func (l Lot) Location(def *time.Location) (*time.Location, error) {
+	if l.Timezone == "" {
+		return def, nil
+	}
+	return time.LoadLocation(l.Timezone)
+}
+
+// CheckOpen проверяет, что интервал [start, end) целиком попадает в часы работы одного дня
+func (l Lot) CheckOpen(start, end time.Time, def *time.Location) error {
+	if l.OpensAt == nil || l.ClosesAt == nil {
+		return nil
+	}
+	loc, err := l.Location(def)
+	if err != nil {
+		return err
+	}
+	opens, err := time.Parse(ClockLayout, *l.OpensAt)
+	if err != nil {
+		return err
+	}
+	closes, err := time.Parse(ClockLayout, *l.ClosesAt)
+	if err != nil {
+		return err
+	}
+
+	local := start.In(loc)
+	dayOpens := time.Date(local.Year(), local.Month(), local.Day(), opens.Hour(), opens.Minute(), 0, 0, loc)
+	dayCloses := time.Date(local.Year(), local.Month(), local.Day(), closes.Hour(), closes.Minute(), 0, 0, loc)
+	if start.Before(dayOpens) || end.After(dayCloses) {
+		return ErrLotClosed
+	}
+	return nil
+}
+
+const lotColumns = `id, name, address, COALESCE(timezone, ''),
+	to_char(opens_at, 'HH24:MI'), to_char(closes_at, 'HH24:MI'), created_at`
+
+func scanLotRow(row rowScanner, lot *Lot) error {
+	return row.Scan(&lot.ID, &lot.Name, &lot.Address, &lot.Timezone, &lot.OpensAt, &lot.ClosesAt, &lot.CreatedAt)
+}
+
+// ListLots возвращает все парковки вместе с зонами
+func ListLots(db Queryer) ([]Lot, error) {
+	rows, err := db.Query(`SELECT ` + lotColumns + ` FROM lots ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots := []Lot{}
+	index := map[int]int{}
+	for rows.Next() {
+		lot := Lot{Zones: []Zone{}}
+		if err := scanLotRow(rows, &lot); err != nil {
+			return nil, err
+		}
+		index[lot.ID] = len(lots)
+		lots = append(lots, lot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	zones, err := listZones(db, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, zone := range zones {
+		if i, ok := index[zone.LotID]; ok {
+			lots[i].Zones = append(lots[i].Zones, zone)
+		}
+	}
+
+	return lots, nil
+}
+
+// GetLot возвращает парковку с зонами или ErrLotNotFound
+func GetLot(db Queryer, lotID int) (*Lot, error) {
+	lot := Lot{Zones: []Zone{}}
+	err := scanLotRow(db.QueryRow(`SELECT `+lotColumns+` FROM lots WHERE id = $1`, lotID), &lot)
+	if err == sql.ErrNoRows {
+		return nil, ErrLotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lot.Zones, err = listZones(db, lotID)
+	if err != nil {
+		return nil, err
+	}
+	return &lot, nil
+}
+
+// GetSpotLot возвращает парковку, к которой относится место
+func GetSpotLot(db Queryer, spotNumber int) (*Lot, error) {
+	var lotID int
+	err := db.QueryRow(`SELECT lot_id FROM spots WHERE number = $1`, spotNumber).Scan(&lotID)
+	if err == sql.ErrNoRows {
+		return nil, ErrSpotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetLot(db, lotID)
+}
+
+// CreateLot добавляет парковку
+func CreateLot(db Queryer, lot *Lot) (int, error) {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO lots (name, address, timezone, opens_at, closes_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		RETURNING id
+	`, lot.Name, lot.Address, lot.Timezone, lot.OpensAt, lot.ClosesAt).Scan(&id)
+	return id, translateUniqueError(err, ErrLotExists)
+}
+
+// UpdateLot сохраняет изменения парковки
+func UpdateLot(db Queryer, lot *Lot) error {
+	result, err := db.Exec(`
+		UPDATE lots
+		SET name = $2, address = $3, timezone = NULLIF($4, ''), opens_at = $5, closes_at = $6
+		WHERE id = $1
+	`, lot.ID, lot.Name, lot.Address, lot.Timezone, lot.OpensAt, lot.ClosesAt)
+	if err != nil {
+		return translateUniqueError(err, ErrLotExists)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrLotNotFound
+	}
+	return nil
+}
+
+func listZones(db Queryer, lotID int) ([]Zone, error) {
+	rows, err := db.Query(`
+		SELECT id, lot_id, name
+		FROM zones
+		WHERE $1 = 0 OR lot_id = $1
+		ORDER BY lot_id, name
+	`, lotID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	zones := []Zone{}
+	for rows.Next() {
+		var zone Zone
+		if err := rows.Scan(&zone.ID, &zone.LotID, &zone.Name); err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+
+	return zones, rows.Err()
+}
+
+// GetZone возвращает зону или ErrZoneNotFound
+func GetZone(db Queryer, zoneID int) (*Zone, error) {
+	var zone Zone
+	err := db.QueryRow(`SELECT id, lot_id, name FROM zones WHERE id = $1`, zoneID).
+		Scan(&zone.ID, &zone.LotID, &zone.Name)
+	if err == sql.ErrNoRows {
+		return nil, ErrZoneNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+// CreateZone добавляет зону в парковку
+func CreateZone(db Queryer, zone *Zone) (int, error) {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO zones (lot_id, name)
+		VALUES ($1, $2)
+		RETURNING id
+	`, zone.LotID, zone.Name).Scan(&id)
+	return id, translateUniqueError(err, ErrZoneExists)
+}
+
+// DeleteZone удаляет зону; места зоны остаются в парковке без зоны
+func DeleteZone(db Queryer, lotID, zoneID int) error {
+	result, err := db.Exec(`DELETE FROM zones WHERE id = $1 AND lot_id = $2`, zoneID, lotID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrZoneNotFound
+	}
+	return nil
+}
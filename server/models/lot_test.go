@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLotCheckOpen(t *testing.T) {
+	moscow := mustLoadLocation(t, "Europe/Moscow")
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	clock := func(value string) *string { return &value }
+
+	daytime := Lot{OpensAt: clock("08:00"), ClosesAt: clock("20:00")}
+	yekaterinburg := Lot{Timezone: "Asia/Yekaterinburg", OpensAt: clock("08:00"), ClosesAt: clock("20:00")}
+	berlinLot := Lot{Timezone: "Europe/Berlin", OpensAt: clock("06:00"), ClosesAt: clock("22:00")}
+
+	tests := []struct {
+		name       string
+		lot        Lot
+		start, end time.Time
+		wantErr    error
+	}{
+		{
+			name:  "open around the clock",
+			lot:   Lot{},
+			start: time.Date(2026, 3, 2, 23, 0, 0, 0, moscow),
+			end:   time.Date(2026, 3, 3, 2, 0, 0, 0, moscow),
+		},
+		{
+			name:  "within opening hours",
+			lot:   daytime,
+			start: time.Date(2026, 3, 2, 9, 0, 0, 0, moscow),
+			end:   time.Date(2026, 3, 2, 11, 0, 0, 0, moscow),
+		},
+		{
+			name:  "exactly the opening hours",
+			lot:   daytime,
+			start: time.Date(2026, 3, 2, 8, 0, 0, 0, moscow),
+			end:   time.Date(2026, 3, 2, 20, 0, 0, 0, moscow),
+		},
+		{
+			name:    "starts before opening",
+			lot:     daytime,
+			start:   time.Date(2026, 3, 2, 7, 45, 0, 0, moscow),
+			end:     time.Date(2026, 3, 2, 9, 0, 0, 0, moscow),
+			wantErr: ErrLotClosed,
+		},
+		{
+			name:    "ends after closing",
+			lot:     daytime,
+			start:   time.Date(2026, 3, 2, 19, 0, 0, 0, moscow),
+			end:     time.Date(2026, 3, 2, 20, 15, 0, 0, moscow),
+			wantErr: ErrLotClosed,
+		},
+		{
+			name:    "overnight booking spans two days",
+			lot:     daytime,
+			start:   time.Date(2026, 3, 2, 18, 0, 0, 0, moscow),
+			end:     time.Date(2026, 3, 3, 9, 0, 0, 0, moscow),
+			wantErr: ErrLotClosed,
+		},
+		{
+			name:  "hours are taken in the lot's timezone",
+			lot:   yekaterinburg,
+			start: time.Date(2026, 3, 2, 7, 0, 0, 0, moscow),
+			end:   time.Date(2026, 3, 2, 9, 0, 0, 0, moscow),
+		},
+		{
+			name:    "late evening in the default timezone is past closing in the lot's one",
+			lot:     yekaterinburg,
+			start:   time.Date(2026, 3, 2, 17, 0, 0, 0, moscow),
+			end:     time.Date(2026, 3, 2, 19, 0, 0, 0, moscow),
+			wantErr: ErrLotClosed,
+		},
+		{
+			name:  "whole day with the DST switch",
+			lot:   berlinLot,
+			start: time.Date(2026, 3, 29, 6, 0, 0, 0, berlin),
+			end:   time.Date(2026, 3, 29, 22, 0, 0, 0, berlin),
+		},
+		{
+			name:    "after closing on the day with the DST switch",
+			lot:     berlinLot,
+			start:   time.Date(2026, 3, 29, 21, 0, 0, 0, berlin),
+			end:     time.Date(2026, 3, 29, 22, 30, 0, 0, berlin),
+			wantErr: ErrLotClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.lot.CheckOpen(tt.start, tt.end, moscow)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckOpen error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLotCheckOpenInvalidTimezone(t *testing.T) {
+	clock := "08:00"
+	lot := Lot{Timezone: "Mars/Olympus", OpensAt: &clock, ClosesAt: &clock}
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	if err := lot.CheckOpen(start, start.Add(time.Hour), time.UTC); err == nil || errors.Is(err, ErrLotClosed) {
+		t.Fatalf("expected a timezone error, got %v", err)
+	}
+}
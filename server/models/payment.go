@@ -0,0 +1,264 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PaymentStatus — состояние платежа у провайдера
+type PaymentStatus string
+
+const (
+	PaymentPending   PaymentStatus = "pending"
+	PaymentSucceeded PaymentStatus = "succeeded"
+	PaymentFailed    PaymentStatus = "failed"
+	PaymentExpired   PaymentStatus = "expired"
+)
+
+var (
+	ErrPaymentNotFound      = errors.New("payment not found")
+	ErrRefundExceedsPayment = errors.New("refund exceeds the paid amount")
+)
+
+// Payment — платеж пользователя через провайдера; суммы в копейках
+type Payment struct {
+	ID       int    `json:"id"`
+	UserID   int    `json:"userId"`
+	Provider string `json:"provider"`
+	// Reference — идентификатор платежа у провайдера
+	Reference *string `json:"reference,omitempty"`
+	// ConfirmationURL — страница провайдера, на которой пользователь подтверждает оплату
+	ConfirmationURL *string       `json:"confirmationUrl,omitempty"`
+	Amount          int64         `json:"amount"`
+	RefundedAmount  int64         `json:"refundedAmount"`
+	Currency        string        `json:"currency"`
+	Status          PaymentStatus `json:"status"`
+	ExpiresAt       time.Time     `json:"expiresAt"`
+	ConfirmedAt     *time.Time    `json:"confirmedAt,omitempty"`
+	CreatedAt       time.Time     `json:"createdAt"`
+}
+
+const paymentColumns = "id, user_id, provider, reference, confirmation_url, amount, refunded_amount, currency, status, expires_at, confirmed_at, created_at"
+
+func scanPaymentRow(row rowScanner, payment *Payment) error {
+	return row.Scan(&payment.ID, &payment.UserID, &payment.Provider, &payment.Reference, &payment.ConfirmationURL,
+		&payment.Amount, &payment.RefundedAmount, &payment.Currency, &payment.Status, &payment.ExpiresAt,
+		&payment.ConfirmedAt, &payment.CreatedAt)
+}
+
+func scanPayment(row *sql.Row) (*Payment, error) {
+	var payment Payment
+	err := scanPaymentRow(row, &payment)
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// CreatePayment сохраняет платеж в статусе pending и привязывает к нему оплачиваемые брони
+func CreatePayment(db Queryer, payment *Payment, bookingIDs []int) (int, error) {
+	err := db.QueryRow(`
+		INSERT INTO payments (user_id, provider, amount, currency, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at
+	`, payment.UserID, payment.Provider, payment.Amount, payment.Currency, payment.ExpiresAt).
+		Scan(&payment.ID, &payment.Status, &payment.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(bookingIDs) > 0 {
+		_, err = db.Exec(`UPDATE bookings SET payment_id = $1 WHERE id = ANY($2)`, payment.ID, pq.Array(bookingIDs))
+	}
+	return payment.ID, err
+}
+
+// SetPaymentCheckout сохраняет идентификатор платежа у провайдера и адрес страницы оплаты
+func SetPaymentCheckout(db Queryer, paymentID int, reference, confirmationURL string) error {
+	_, err := db.Exec(`
+		UPDATE payments SET reference = $2, confirmation_url = NULLIF($3, '') WHERE id = $1
+	`, paymentID, reference, confirmationURL)
+	return err
+}
+
+// GetPaymentByReference возвращает платеж по его идентификатору у провайдера
+func GetPaymentByReference(db Queryer, provider, reference string) (*Payment, error) {
+	return scanPayment(db.QueryRow(`
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE provider = $1 AND reference = $2
+	`, provider, reference))
+}
+
+// LockPayment возвращает платеж по ID и блокирует его строку до конца транзакции
+func LockPayment(tx *sql.Tx, paymentID int) (*Payment, error) {
+	return scanPayment(tx.QueryRow(`
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE id = $1
+		FOR UPDATE
+	`, paymentID))
+}
+
+// LockPaymentByReference блокирует платеж по его идентификатору у провайдера
+func LockPaymentByReference(tx *sql.Tx, provider, reference string) (*Payment, error) {
+	return scanPayment(tx.QueryRow(`
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE provider = $1 AND reference = $2
+		FOR UPDATE
+	`, provider, reference))
+}
+
+// ListUserPayments возвращает платежи пользователя, начиная с последнего
+func ListUserPayments(db Queryer, userID int) ([]Payment, error) {
+	rows, err := db.Query(`
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []Payment{}
+	for rows.Next() {
+		var payment Payment
+		if err := scanPaymentRow(rows, &payment); err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
+
+// SetPaymentStatus меняет статус платежа; для succeeded запоминает время подтверждения
+func SetPaymentStatus(db Queryer, paymentID int, status PaymentStatus) error {
+	_, err := db.Exec(`
+		UPDATE payments
+		SET status = $2,
+			confirmed_at = CASE WHEN $2 = 'succeeded' THEN NOW() ELSE confirmed_at END
+		WHERE id = $1
+	`, paymentID, string(status))
+	return err
+}
+
+// AddPaymentRefund увеличивает возвращенную по платежу сумму; вернуть больше оплаченного нельзя
+func AddPaymentRefund(db Queryer, paymentID int, amount int64) error {
+	result, err := db.Exec(`
+		UPDATE payments
+		SET refunded_amount = refunded_amount + $2
+		WHERE id = $1 AND refunded_amount + $2 <= amount
+	`, paymentID, amount)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRefundExceedsPayment
+	}
+	return nil
+}
+
+// LockPaymentBookings возвращает брони, оплачиваемые платежом, и блокирует их строки
+func LockPaymentBookings(tx *sql.Tx, paymentID int) ([]Booking, error) {
+	rows, err := tx.Query(`
+		SELECT `+bookingColumns+`
+		FROM bookings
+		WHERE payment_id = $1
+		ORDER BY id
+		FOR UPDATE
+	`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookings := []Booking{}
+	for rows.Next() {
+		var booking Booking
+		if err := scanBookingRow(rows, &booking); err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, booking)
+	}
+	return bookings, rows.Err()
+}
+
+// CancelUnpaidBookings отменяет неоплаченные брони платежа и возвращает освобожденные места
+func CancelUnpaidBookings(tx *sql.Tx, paymentID int, reason string) ([]int, error) {
+	bookings, err := LockPaymentBookings(tx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	spots := []int{}
+	for _, booking := range bookings {
+		if booking.Status != StatusPending {
+			continue
+		}
+		if err := CancelBooking(tx, booking.ID, 0, reason); err != nil {
+			return nil, err
+		}
+		spots = append(spots, booking.ParkingSpot)
+	}
+	return spots, nil
+}
+
+// ExpirePayments помечает просроченными платежи, не подтвержденные вовремя,
+// отменяет их брони и возвращает освобожденные места
+func ExpirePayments(db *sql.DB) ([]int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id
+		FROM payments
+		WHERE status = 'pending' AND expires_at <= NOW()
+		FOR UPDATE SKIP LOCKED
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var expired []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		expired = append(expired, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	spots := []int{}
+	for _, paymentID := range expired {
+		if err := SetPaymentStatus(tx, paymentID, PaymentExpired); err != nil {
+			return nil, err
+		}
+		released, err := CancelUnpaidBookings(tx, paymentID, "Payment was not completed in time")
+		if err != nil {
+			return nil, err
+		}
+		spots = append(spots, released...)
+	}
+
+	return spots, tx.Commit()
+}
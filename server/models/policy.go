@@ -0,0 +1,187 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Коды нарушенных правил бронирования, которые возвращаются клиенту
+const (
+	PolicyMaxConcurrent = "max_concurrent_bookings"
+	PolicyMaxPerCar     = "max_bookings_per_car"
+	PolicyMaxDuration   = "max_booking_duration"
+	PolicyMaxWeekly     = "max_weekly_hours"
+	PolicyMinGap        = "min_gap_between_bookings"
+)
+
+// Классы рекомендательных блокировок, под которыми проверяются правила
+const (
+	policyLockUser = 1
+	policyLockCar  = 2
+)
+
+// BookingPolicy — ограничения на брони одного пользователя; нулевое значение отключает правило
+type BookingPolicy struct {
+	// MaxConcurrent — сколько броней пользователя может пересекаться по времени
+	MaxConcurrent int
+	// MaxPerCar — сколько пересекающихся броней может быть на один номер машины
+	MaxPerCar int
+	// MaxDuration — максимальная длительность одной брони
+	MaxDuration time.Duration
+	// MaxWeekly — суммарная длительность броней пользователя за календарную неделю
+	MaxWeekly time.Duration
+	// MinGap — минимальный перерыв между соседними бронями пользователя
+	MinGap time.Duration
+	// Location — часовой пояс для парковок без собственного; неделя считается в поясе парковки места
+	Location *time.Location
+}
+
+// PolicyViolation описывает нарушенное правило
+type PolicyViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (v *PolicyViolation) Error() string {
+	return v.Message
+}
+
+// CheckDuration проверяет длительность одной брони
+func (p BookingPolicy) CheckDuration(duration time.Duration) error {
+	if p.MaxDuration > 0 && duration > p.MaxDuration {
+		return &PolicyViolation{
+			Code:    PolicyMaxDuration,
+			Message: fmt.Sprintf("Booking exceeds the maximum duration of %v", p.MaxDuration),
+		}
+	}
+	return nil
+}
+
+// CheckBookingPolicy проверяет новую или измененную бронь на соответствие правилам.
+// Бронь с ненулевым ID исключается из подсчетов, чтобы продление не конфликтовало само с собой.
+// Блокирует пользователя и номер машины до конца транзакции, чтобы параллельные
+// запросы не обошли лимиты. Сначала проверяется, подходит ли место пользователю и машине;
+// нарушение возвращается как *PolicyViolation
+func CheckBookingPolicy(tx *sql.Tx, policy BookingPolicy, booking Booking) error {
+	start, end := booking.ReservedAt, booking.PlannedEndsAt
+	if err := policy.CheckDuration(end.Sub(start)); err != nil {
+		return err
+	}
+	if err := CheckSpotEligibility(tx, booking); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, policyLockUser, booking.UserID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, hashtext(upper($2)))`, policyLockCar, booking.CarNumber); err != nil {
+		return err
+	}
+
+	if policy.MaxConcurrent > 0 {
+		var count int
+		err := tx.QueryRow(`
+			SELECT COUNT(*)
+			FROM bookings
+			WHERE user_id = $1 AND id <> $2
+			AND period && tstzrange($3, $4, '[)')
+			AND `+OccupyingStatusCondition+`
+		`, booking.UserID, booking.ID, start, end).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count >= policy.MaxConcurrent {
+			return &PolicyViolation{
+				Code:    PolicyMaxConcurrent,
+				Message: fmt.Sprintf("You may hold at most %d bookings at the same time", policy.MaxConcurrent),
+			}
+		}
+	}
+
+	if policy.MaxPerCar > 0 {
+		var count int
+		err := tx.QueryRow(`
+			SELECT COUNT(*)
+			FROM bookings
+			WHERE upper(car_number) = upper($1) AND id <> $2
+			AND period && tstzrange($3, $4, '[)')
+			AND `+OccupyingStatusCondition+`
+		`, booking.CarNumber, booking.ID, start, end).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count >= policy.MaxPerCar {
+			return &PolicyViolation{
+				Code:    PolicyMaxPerCar,
+				Message: fmt.Sprintf("Car %s may have at most %d bookings at the same time", booking.CarNumber, policy.MaxPerCar),
+			}
+		}
+	}
+
+	if policy.MaxWeekly > 0 {
+		lot, err := GetSpotLot(tx, booking.ParkingSpot)
+		if err != nil {
+			return err
+		}
+		loc, err := lot.Location(policy.Location)
+		if err != nil {
+			return err
+		}
+		weekStart, weekEnd := weekBounds(start, loc)
+		var seconds float64
+		err = tx.QueryRow(`
+			SELECT COALESCE(SUM(EXTRACT(EPOCH FROM upper(period) - lower(period))), 0)
+			FROM bookings
+			WHERE user_id = $1 AND id <> $2
+			AND reserved_at >= $3 AND reserved_at < $4
+			AND (`+OccupyingStatusCondition+` OR status = 'completed')
+		`, booking.UserID, booking.ID, weekStart, weekEnd).Scan(&seconds)
+		if err != nil {
+			return err
+		}
+		if time.Duration(seconds*float64(time.Second))+end.Sub(start) > policy.MaxWeekly {
+			return &PolicyViolation{
+				Code:    PolicyMaxWeekly,
+				Message: fmt.Sprintf("Bookings may not exceed %v per week", policy.MaxWeekly),
+			}
+		}
+	}
+
+	if policy.MinGap > 0 {
+		// Пересекающиеся брони ограничивает MaxConcurrent, здесь важны только соседние
+		var tooClose bool
+		err := tx.QueryRow(`
+			SELECT EXISTS(
+				SELECT 1
+				FROM bookings
+				WHERE user_id = $1 AND id <> $2
+				AND period && tstzrange($3, $4, '[)')
+				AND NOT period && tstzrange($5, $6, '[)')
+				AND `+OccupyingStatusCondition+`
+			)
+		`, booking.UserID, booking.ID, start.Add(-policy.MinGap), end.Add(policy.MinGap), start, end).Scan(&tooClose)
+		if err != nil {
+			return err
+		}
+		if tooClose {
+			return &PolicyViolation{
+				Code:    PolicyMinGap,
+				Message: fmt.Sprintf("Bookings must be at least %v apart", policy.MinGap),
+			}
+		}
+	}
+
+	return nil
+}
+
+// weekBounds возвращает начало и конец календарной недели (с понедельника), содержащей t
+func weekBounds(t time.Time, loc *time.Location) (time.Time, time.Time) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	offset := (int(local.Weekday()) + 6) % 7
+	start := time.Date(local.Year(), local.Month(), local.Day()-offset, 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 0, 7)
+}
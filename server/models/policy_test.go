@@ -0,0 +1,254 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"server/testutil"
+)
+
+func TestWeekBounds(t *testing.T) {
+	moscow := mustLoadLocation(t, "Europe/Moscow")
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+
+	tests := []struct {
+		name      string
+		t         time.Time
+		loc       *time.Location
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			name:      "midweek",
+			t:         time.Date(2026, 3, 4, 15, 0, 0, 0, moscow),
+			loc:       moscow,
+			wantStart: time.Date(2026, 3, 2, 0, 0, 0, 0, moscow),
+			wantEnd:   time.Date(2026, 3, 9, 0, 0, 0, 0, moscow),
+		},
+		{
+			name:      "monday midnight starts the week",
+			t:         time.Date(2026, 3, 9, 0, 0, 0, 0, moscow),
+			loc:       moscow,
+			wantStart: time.Date(2026, 3, 9, 0, 0, 0, 0, moscow),
+			wantEnd:   time.Date(2026, 3, 16, 0, 0, 0, 0, moscow),
+		},
+		{
+			name:      "sunday late evening belongs to the ending week",
+			t:         time.Date(2026, 3, 8, 23, 59, 0, 0, moscow),
+			loc:       moscow,
+			wantStart: time.Date(2026, 3, 2, 0, 0, 0, 0, moscow),
+			wantEnd:   time.Date(2026, 3, 9, 0, 0, 0, 0, moscow),
+		},
+		{
+			name:      "week is taken in the given location, not in UTC",
+			t:         time.Date(2026, 3, 8, 22, 0, 0, 0, time.UTC),
+			loc:       moscow,
+			wantStart: time.Date(2026, 3, 9, 0, 0, 0, 0, moscow),
+			wantEnd:   time.Date(2026, 3, 16, 0, 0, 0, 0, moscow),
+		},
+		{
+			name:      "nil location means UTC",
+			t:         time.Date(2026, 3, 8, 22, 0, 0, 0, time.UTC),
+			loc:       nil,
+			wantStart: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "week with the DST switch",
+			t:         time.Date(2026, 3, 29, 12, 0, 0, 0, berlin),
+			loc:       berlin,
+			wantStart: time.Date(2026, 3, 23, 0, 0, 0, 0, berlin),
+			wantEnd:   time.Date(2026, 3, 30, 0, 0, 0, 0, berlin),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := weekBounds(tt.t, tt.loc)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Fatalf("weekBounds = [%v, %v), want [%v, %v)", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+
+	// Неделя с переходом на летнее время на час короче
+	start, end := weekBounds(time.Date(2026, 3, 29, 12, 0, 0, 0, berlin), berlin)
+	if got := end.Sub(start); got != 167*time.Hour {
+		t.Fatalf("DST week lasts %v, want 167h", got)
+	}
+}
+
+func TestCheckDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		max      time.Duration
+		duration time.Duration
+		wantCode string
+	}{
+		{name: "rule disabled", max: 0, duration: 48 * time.Hour},
+		{name: "exactly the maximum", max: 4 * time.Hour, duration: 4 * time.Hour},
+		{name: "over the maximum", max: 4 * time.Hour, duration: 4*time.Hour + time.Minute, wantCode: PolicyMaxDuration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := BookingPolicy{MaxDuration: tt.max}.CheckDuration(tt.duration)
+			if code := violationCode(t, err); code != tt.wantCode {
+				t.Fatalf("got code %q, want %q", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+// violationCode возвращает код нарушения из ошибки или пустую строку, если ошибки нет
+func violationCode(t *testing.T, err error) string {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	var violation *PolicyViolation
+	if !errors.As(err, &violation) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return violation.Code
+}
+
+func TestCheckBookingPolicy(t *testing.T) {
+	moscow := mustLoadLocation(t, "Europe/Moscow")
+	// Понедельник через две недели, чтобы все брони были в будущем
+	monday, _ := weekBounds(time.Now().AddDate(0, 0, 14), moscow)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(monday.Year(), monday.Month(), monday.Day()+day, hour, minute, 0, 0, moscow)
+	}
+
+	type window struct {
+		spot       int
+		car        string
+		start, end time.Time
+	}
+	tests := []struct {
+		name     string
+		policy   BookingPolicy
+		existing []window
+		// otherUser — существующие брони принадлежат другому пользователю
+		otherUser bool
+		// extend — проверяется продление первой существующей брони
+		extend   bool
+		booking  window
+		wantCode string
+	}{
+		{
+			name:     "concurrent bookings over the limit",
+			policy:   BookingPolicy{MaxConcurrent: 1},
+			existing: []window{{1, "A111AA77", at(0, 10, 0), at(0, 12, 0)}},
+			booking:  window{2, "B222BB77", at(0, 11, 0), at(0, 13, 0)},
+			wantCode: PolicyMaxConcurrent,
+		},
+		{
+			name:     "concurrent bookings within the limit",
+			policy:   BookingPolicy{MaxConcurrent: 2},
+			existing: []window{{1, "A111AA77", at(0, 10, 0), at(0, 12, 0)}},
+			booking:  window{2, "B222BB77", at(0, 11, 0), at(0, 13, 0)},
+		},
+		{
+			name:     "adjacent bookings do not overlap",
+			policy:   BookingPolicy{MaxConcurrent: 1},
+			existing: []window{{1, "A111AA77", at(0, 10, 0), at(0, 12, 0)}},
+			booking:  window{2, "B222BB77", at(0, 12, 0), at(0, 13, 0)},
+		},
+		{
+			name:     "extension does not conflict with itself",
+			policy:   BookingPolicy{MaxConcurrent: 1},
+			existing: []window{{1, "A111AA77", at(0, 10, 0), at(0, 12, 0)}},
+			extend:   true,
+			booking:  window{1, "A111AA77", at(0, 10, 0), at(0, 14, 0)},
+		},
+		{
+			name:      "same car of another user, plate case ignored",
+			policy:    BookingPolicy{MaxPerCar: 1},
+			existing:  []window{{1, "A111AA77", at(0, 10, 0), at(0, 12, 0)}},
+			otherUser: true,
+			booking:   window{2, "a111aa77", at(0, 11, 0), at(0, 13, 0)},
+			wantCode:  PolicyMaxPerCar,
+		},
+		{
+			name:     "weekly hours over the limit",
+			policy:   BookingPolicy{MaxWeekly: 5 * time.Hour, Location: moscow},
+			existing: []window{{1, "A111AA77", at(0, 9, 0), at(0, 12, 0)}},
+			booking:  window{2, "A111AA77", at(6, 20, 0), at(6, 23, 0)},
+			wantCode: PolicyMaxWeekly,
+		},
+		{
+			name:     "weekly hours at the limit",
+			policy:   BookingPolicy{MaxWeekly: 5 * time.Hour, Location: moscow},
+			existing: []window{{1, "A111AA77", at(0, 9, 0), at(0, 12, 0)}},
+			booking:  window{2, "A111AA77", at(6, 20, 0), at(6, 22, 0)},
+		},
+		{
+			name:     "weekly hours of the previous week are not counted",
+			policy:   BookingPolicy{MaxWeekly: 5 * time.Hour, Location: moscow},
+			existing: []window{{1, "A111AA77", at(-1, 20, 0), at(-1, 23, 0)}},
+			booking:  window{2, "A111AA77", at(0, 0, 0), at(0, 3, 0)},
+		},
+		{
+			name:     "gap between bookings too short",
+			policy:   BookingPolicy{MinGap: 30 * time.Minute},
+			existing: []window{{1, "A111AA77", at(0, 10, 0), at(0, 12, 0)}},
+			booking:  window{2, "A111AA77", at(0, 12, 15), at(0, 13, 0)},
+			wantCode: PolicyMinGap,
+		},
+		{
+			name:     "gap between bookings long enough",
+			policy:   BookingPolicy{MinGap: 30 * time.Minute},
+			existing: []window{{1, "A111AA77", at(0, 10, 0), at(0, 12, 0)}},
+			booking:  window{2, "A111AA77", at(0, 12, 30), at(0, 13, 0)},
+		},
+		{
+			name:     "duration over the limit",
+			policy:   BookingPolicy{MaxDuration: 2 * time.Hour},
+			booking:  window{1, "A111AA77", at(0, 10, 0), at(0, 13, 0)},
+			wantCode: PolicyMaxDuration,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			userID := testutil.CreateUser(t, db, "policy@example.com")
+			ownerID := userID
+			if tt.otherUser {
+				ownerID = testutil.CreateUser(t, db, "other@example.com")
+			}
+
+			var existingIDs []int
+			for _, w := range tt.existing {
+				id, err := CreateBooking(db, &Booking{
+					UserID: ownerID, ParkingSpot: w.spot, CarNumber: w.car,
+					ReservedAt: w.start, PlannedEndsAt: w.end,
+				})
+				if err != nil {
+					t.Fatalf("create booking: %v", err)
+				}
+				existingIDs = append(existingIDs, id)
+			}
+
+			booking := Booking{
+				UserID: userID, ParkingSpot: tt.booking.spot, CarNumber: tt.booking.car,
+				ReservedAt: tt.booking.start, PlannedEndsAt: tt.booking.end,
+			}
+			if tt.extend {
+				booking.ID = existingIDs[0]
+			}
+
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatalf("begin: %v", err)
+			}
+			defer tx.Rollback()
+			err = CheckBookingPolicy(tx, tt.policy, booking)
+			if code := violationCode(t, err); code != tt.wantCode {
+				t.Fatalf("got code %q, want %q", code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,414 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Коды нарушений при применении промокода
+const (
+	PolicyPromoCodeInvalid       = "promo_code_invalid"
+	PolicyPromoCodeExhausted     = "promo_code_exhausted"
+	PolicyPromoCodeNotApplicable = "promo_code_not_applicable"
+)
+
+// Класс рекомендательной блокировки, под которой считаются применения промокода
+const policyLockPromo = 3
+
+var (
+	ErrPromoCodeNotFound = errors.New("promo code not found")
+	ErrPromoCodeExists   = errors.New("promo code already exists")
+	ErrPromoCodeRedeemed = errors.New("promo code has redemptions")
+)
+
+// PromoCode — промокод на скидку. Скидка задается либо процентом, либо суммой в копейках
+type PromoCode struct {
+	ID          int    `json:"id"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	// DiscountPercent и DiscountAmount — процентная или фиксированная скидка; задана ровно одна
+	DiscountPercent *int   `json:"discountPercent,omitempty"`
+	DiscountAmount  *int64 `json:"discountAmount,omitempty"`
+	// ValidFrom и ValidUntil ограничивают время применения; nil — без ограничения
+	ValidFrom  *time.Time `json:"validFrom,omitempty"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+	// MaxUses и MaxUsesPerUser — сколько раз промокод можно применить всего и одному пользователю
+	MaxUses        *int `json:"maxUses,omitempty"`
+	MaxUsesPerUser *int `json:"maxUsesPerUser,omitempty"`
+	// LotIDs и SpotFeatures ограничивают места: пустой список — любые парковки и места
+	LotIDs       []int     `json:"lotIds"`
+	SpotFeatures []string  `json:"spotFeatures"`
+	Active       bool      `json:"active"`
+	CreatedAt    time.Time `json:"createdAt"`
+	// Uses — сколько раз промокод применен к неотмененным броням
+	Uses int `json:"uses"`
+}
+
+// PromoRedemption — применение промокода к брони
+type PromoRedemption struct {
+	ID        int           `json:"id"`
+	UserID    int           `json:"userId"`
+	BookingID int           `json:"bookingId"`
+	Status    BookingStatus `json:"status"`
+	Discount  int64         `json:"discount"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+// PromoDailyStats — применения промокода за один день
+type PromoDailyStats struct {
+	Date        string `json:"date"`
+	Redemptions int    `json:"redemptions"`
+	Discount    int64  `json:"discount"`
+}
+
+// PromoCodeStats — статистика применений промокода; отмененные брони считаются отдельно
+type PromoCodeStats struct {
+	Redemptions int `json:"redemptions"`
+	Cancelled   int `json:"cancelled"`
+	Users       int `json:"users"`
+	// TotalDiscount — сумма скидок, Revenue — стоимость броней после скидки
+	TotalDiscount int64             `json:"totalDiscount"`
+	Revenue       int64             `json:"revenue"`
+	Daily         []PromoDailyStats `json:"daily"`
+	Recent        []PromoRedemption `json:"recent"`
+}
+
+// Сколько последних применений возвращается в статистике
+const promoRecentRedemptions = 50
+
+// Условие для применений, которые занимают лимит: брони не отменены
+const promoUsedCondition = "EXISTS (SELECT 1 FROM bookings b WHERE b.id = r.booking_id AND b.status <> 'cancelled')"
+
+const promoCodeColumns = `p.id, p.code, p.description, p.discount_percent, p.discount_amount, p.valid_from, p.valid_until,
+	p.max_uses, p.max_uses_per_user, p.lot_ids, p.spot_features, p.active, p.created_at,
+	(SELECT COUNT(*) FROM promo_redemptions r WHERE r.promo_code_id = p.id AND ` + promoUsedCondition + `)`
+
+func scanPromoCodeRow(row rowScanner, promo *PromoCode) error {
+	var lotIDs []int64
+	features := pq.StringArray{}
+	err := row.Scan(&promo.ID, &promo.Code, &promo.Description, &promo.DiscountPercent, &promo.DiscountAmount,
+		&promo.ValidFrom, &promo.ValidUntil, &promo.MaxUses, &promo.MaxUsesPerUser, pq.Array(&lotIDs),
+		&features, &promo.Active, &promo.CreatedAt, &promo.Uses)
+	promo.LotIDs = make([]int, len(lotIDs))
+	for i, id := range lotIDs {
+		promo.LotIDs[i] = int(id)
+	}
+	promo.SpotFeatures = features
+	return err
+}
+
+func scanPromoCode(row *sql.Row) (*PromoCode, error) {
+	var promo PromoCode
+	err := scanPromoCodeRow(row, &promo)
+	if err == sql.ErrNoRows {
+		return nil, ErrPromoCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &promo, nil
+}
+
+// Discount возвращает скидку промокода для суммы amount; скидка не превышает сумму
+func (p PromoCode) Discount(amount int64) int64 {
+	var discount int64
+	if p.DiscountPercent != nil {
+		discount = amount - ApplyDiscount(amount, *p.DiscountPercent)
+	} else if p.DiscountAmount != nil {
+		discount = *p.DiscountAmount
+	}
+	if discount > amount {
+		discount = amount
+	}
+	return discount
+}
+
+// AppliesTo сообщает, действует ли промокод для места с характеристиками features на парковке lotID
+func (p PromoCode) AppliesTo(lotID int, features []string) bool {
+	if len(p.LotIDs) > 0 && !containsInt(p.LotIDs, lotID) {
+		return false
+	}
+	if len(p.SpotFeatures) == 0 {
+		return true
+	}
+	for _, feature := range features {
+		for _, allowed := range p.SpotFeatures {
+			if feature == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// notApplicable — нарушение для места, к которому промокод не относится
+func (p PromoCode) notApplicable() *PolicyViolation {
+	return &PolicyViolation{
+		Code:    PolicyPromoCodeNotApplicable,
+		Message: fmt.Sprintf("Promo code %s does not apply to this parking spot", p.Code),
+	}
+}
+
+// Check проверяет, что пользователь может применить промокод в момент now:
+// промокод активен, действует и не исчерпал лимиты. Нарушение возвращается как *PolicyViolation.
+// Чтобы параллельные брони не превысили лимит, в транзакции перед проверкой вызывается LockPromoCodeUses
+func (p PromoCode) Check(db Queryer, userID int, now time.Time) error {
+	if !p.Active || (p.ValidFrom != nil && now.Before(*p.ValidFrom)) || (p.ValidUntil != nil && !now.Before(*p.ValidUntil)) {
+		return &PolicyViolation{
+			Code:    PolicyPromoCodeInvalid,
+			Message: fmt.Sprintf("Promo code %s is not valid now", p.Code),
+		}
+	}
+
+	var total, own int
+	err := db.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE r.user_id = $2)
+		FROM promo_redemptions r
+		WHERE r.promo_code_id = $1 AND `+promoUsedCondition, p.ID, userID).Scan(&total, &own)
+	if err != nil {
+		return err
+	}
+	if p.MaxUses != nil && total >= *p.MaxUses {
+		return &PolicyViolation{
+			Code:    PolicyPromoCodeExhausted,
+			Message: fmt.Sprintf("Promo code %s has been used the maximum number of times", p.Code),
+		}
+	}
+	if p.MaxUsesPerUser != nil && own >= *p.MaxUsesPerUser {
+		return &PolicyViolation{
+			Code:    PolicyPromoCodeExhausted,
+			Message: fmt.Sprintf("You have already used promo code %s the maximum number of times", p.Code),
+		}
+	}
+	return nil
+}
+
+// LockPromoCodeUses блокирует подсчет применений промокода до конца транзакции
+func LockPromoCodeUses(tx *sql.Tx, promoID int) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, policyLockPromo, promoID)
+	return err
+}
+
+// ApplyPromoCode уменьшает стоимость расчета на скидку промокода
+func ApplyPromoCode(quote *Quote, promo *PromoCode) {
+	quote.PromoCode = promo.Code
+	quote.PromoDiscount = promo.Discount(quote.Amount)
+	quote.Amount -= quote.PromoDiscount
+}
+
+// FilterPromoSpots оставляет из мест spots те, к которым относится промокод, сохраняя порядок
+func FilterPromoSpots(db Queryer, promo *PromoCode, spots []int) ([]int, error) {
+	filtered := []int{}
+	for _, number := range spots {
+		spot, err := GetSpot(db, number)
+		if err != nil {
+			return nil, err
+		}
+		if promo.AppliesTo(spot.LotID, spot.Features) {
+			filtered = append(filtered, number)
+		}
+	}
+	return filtered, nil
+}
+
+// GetPromoCodeByCode возвращает промокод по коду без учета регистра или ErrPromoCodeNotFound
+func GetPromoCodeByCode(db Queryer, code string) (*PromoCode, error) {
+	return scanPromoCode(db.QueryRow(`
+		SELECT `+promoCodeColumns+`
+		FROM promo_codes p
+		WHERE p.code = upper($1)
+	`, code))
+}
+
+// GetPromoCode возвращает промокод по ID или ErrPromoCodeNotFound
+func GetPromoCode(db Queryer, promoID int) (*PromoCode, error) {
+	return scanPromoCode(db.QueryRow(`
+		SELECT `+promoCodeColumns+`
+		FROM promo_codes p
+		WHERE p.id = $1
+	`, promoID))
+}
+
+// GetBookingPromoCode возвращает промокод, примененный к брони; nil, если его нет
+func GetBookingPromoCode(db Queryer, bookingID int) (*PromoCode, error) {
+	promo, err := scanPromoCode(db.QueryRow(`
+		SELECT `+promoCodeColumns+`
+		FROM promo_codes p
+		JOIN promo_redemptions pr ON pr.promo_code_id = p.id
+		WHERE pr.booking_id = $1
+	`, bookingID))
+	if errors.Is(err, ErrPromoCodeNotFound) {
+		return nil, nil
+	}
+	return promo, err
+}
+
+// ListPromoCodes возвращает все промокоды, начиная с последнего
+func ListPromoCodes(db Queryer) ([]PromoCode, error) {
+	rows, err := db.Query(`SELECT ` + promoCodeColumns + ` FROM promo_codes p ORDER BY p.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promos := []PromoCode{}
+	for rows.Next() {
+		var promo PromoCode
+		if err := scanPromoCodeRow(rows, &promo); err != nil {
+			return nil, err
+		}
+		promos = append(promos, promo)
+	}
+	return promos, rows.Err()
+}
+
+// promoLotIDs переводит ID парковок в массив для PostgreSQL
+func promoLotIDs(promo *PromoCode) interface{} {
+	lotIDs := make([]int64, len(promo.LotIDs))
+	for i, id := range promo.LotIDs {
+		lotIDs[i] = int64(id)
+	}
+	return pq.Array(lotIDs)
+}
+
+// CreatePromoCode сохраняет промокод; код хранится в верхнем регистре
+func CreatePromoCode(db Queryer, promo *PromoCode) (int, error) {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO promo_codes (code, description, discount_percent, discount_amount, valid_from, valid_until,
+			max_uses, max_uses_per_user, lot_ids, spot_features, active)
+		VALUES (upper($1), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`, promo.Code, promo.Description, promo.DiscountPercent, promo.DiscountAmount, promo.ValidFrom, promo.ValidUntil,
+		promo.MaxUses, promo.MaxUsesPerUser, promoLotIDs(promo), pq.Array(promo.SpotFeatures), promo.Active).Scan(&id)
+	return id, translateUniqueError(err, ErrPromoCodeExists)
+}
+
+// UpdatePromoCode заменяет промокод; уже примененные скидки не пересчитываются
+func UpdatePromoCode(db Queryer, promo *PromoCode) error {
+	result, err := db.Exec(`
+		UPDATE promo_codes
+		SET code = upper($2), description = $3, discount_percent = $4, discount_amount = $5,
+			valid_from = $6, valid_until = $7, max_uses = $8, max_uses_per_user = $9,
+			lot_ids = $10, spot_features = $11, active = $12
+		WHERE id = $1
+	`, promo.ID, promo.Code, promo.Description, promo.DiscountPercent, promo.DiscountAmount, promo.ValidFrom,
+		promo.ValidUntil, promo.MaxUses, promo.MaxUsesPerUser, promoLotIDs(promo), pq.Array(promo.SpotFeatures),
+		promo.Active)
+	if err != nil {
+		return translateUniqueError(err, ErrPromoCodeExists)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrPromoCodeNotFound
+	}
+	return nil
+}
+
+// DeletePromoCode удаляет промокод; примененный промокод удалить нельзя — ErrPromoCodeRedeemed
+func DeletePromoCode(db Queryer, promoID int) error {
+	result, err := db.Exec(`DELETE FROM promo_codes WHERE id = $1`, promoID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		return ErrPromoCodeRedeemed
+	}
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrPromoCodeNotFound
+	}
+	return nil
+}
+
+// RedeemPromoCode записывает применение промокода к брони
+func RedeemPromoCode(db Queryer, promoID, userID, bookingID int, discount int64) error {
+	_, err := db.Exec(`
+		INSERT INTO promo_redemptions (promo_code_id, user_id, booking_id, discount)
+		VALUES ($1, $2, $3, $4)
+	`, promoID, userID, bookingID, discount)
+	return err
+}
+
+// SetRedemptionDiscount обновляет скидку по брони после пересчета ее стоимости
+func SetRedemptionDiscount(db Queryer, bookingID int, discount int64) error {
+	_, err := db.Exec(`UPDATE promo_redemptions SET discount = $2 WHERE booking_id = $1`, bookingID, discount)
+	return err
+}
+
+// GetPromoCodeStats считает применения промокода; дни группируются в часовом поясе loc
+func GetPromoCodeStats(db Queryer, promoID int, loc *time.Location) (*PromoCodeStats, error) {
+	stats := &PromoCodeStats{Daily: []PromoDailyStats{}, Recent: []PromoRedemption{}}
+	err := db.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE b.status <> 'cancelled'),
+			COUNT(*) FILTER (WHERE b.status = 'cancelled'),
+			COUNT(DISTINCT r.user_id) FILTER (WHERE b.status <> 'cancelled'),
+			COALESCE(SUM(r.discount) FILTER (WHERE b.status <> 'cancelled'), 0),
+			COALESCE(SUM(b.price) FILTER (WHERE b.status <> 'cancelled'), 0)
+		FROM promo_redemptions r
+		JOIN bookings b ON b.id = r.booking_id
+		WHERE r.promo_code_id = $1
+	`, promoID).Scan(&stats.Redemptions, &stats.Cancelled, &stats.Users, &stats.TotalDiscount, &stats.Revenue)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT to_char(r.created_at AT TIME ZONE $2, 'YYYY-MM-DD') AS day, COUNT(*), SUM(r.discount)
+		FROM promo_redemptions r
+		JOIN bookings b ON b.id = r.booking_id
+		WHERE r.promo_code_id = $1 AND b.status <> 'cancelled'
+		GROUP BY day
+		ORDER BY day
+	`, promoID, loc.String())
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var day PromoDailyStats
+		if err := rows.Scan(&day.Date, &day.Redemptions, &day.Discount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.Daily = append(stats.Daily, day)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`
+		SELECT r.id, r.user_id, r.booking_id, b.status, r.discount, r.created_at
+		FROM promo_redemptions r
+		JOIN bookings b ON b.id = r.booking_id
+		WHERE r.promo_code_id = $1
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT $2
+	`, promoID, promoRecentRedemptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var redemption PromoRedemption
+		err := rows.Scan(&redemption.ID, &redemption.UserID, &redemption.BookingID, &redemption.Status,
+			&redemption.Discount, &redemption.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		stats.Recent = append(stats.Recent, redemption)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"server/testutil"
+)
+
+func TestPromoCodeDiscount(t *testing.T) {
+	percent := func(value int) *int { return &value }
+	amount := func(value int64) *int64 { return &value }
+
+	tests := []struct {
+		name   string
+		promo  PromoCode
+		amount int64
+		want   int64
+	}{
+		{name: "percent", promo: PromoCode{DiscountPercent: percent(20)}, amount: 10000, want: 2000},
+		{name: "percent of an odd amount", promo: PromoCode{DiscountPercent: percent(50)}, amount: 9999, want: 9999 - ApplyDiscount(9999, 50)},
+		{name: "full percent", promo: PromoCode{DiscountPercent: percent(100)}, amount: 10000, want: 10000},
+		{name: "fixed", promo: PromoCode{DiscountAmount: amount(3000)}, amount: 10000, want: 3000},
+		{name: "fixed equal to the amount", promo: PromoCode{DiscountAmount: amount(10000)}, amount: 10000, want: 10000},
+		{name: "fixed is capped at the amount", promo: PromoCode{DiscountAmount: amount(15000)}, amount: 10000, want: 10000},
+		{name: "free booking", promo: PromoCode{DiscountAmount: amount(3000)}, amount: 0, want: 0},
+		{name: "no discount", promo: PromoCode{}, amount: 10000, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.promo.Discount(tt.amount); got != tt.want {
+				t.Fatalf("Discount(%d) = %d, want %d", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromoCodeAppliesTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		promo    PromoCode
+		lotID    int
+		features []string
+		want     bool
+	}{
+		{name: "empty lists apply everywhere", promo: PromoCode{LotIDs: []int{}, SpotFeatures: []string{}}, lotID: 1, want: true},
+		{name: "nil lists apply everywhere", promo: PromoCode{}, lotID: 2, features: []string{"ev"}, want: true},
+		{name: "listed lot", promo: PromoCode{LotIDs: []int{1, 3}}, lotID: 3, want: true},
+		{name: "other lot", promo: PromoCode{LotIDs: []int{1, 3}}, lotID: 2, want: false},
+		{name: "spot has a listed feature", promo: PromoCode{SpotFeatures: []string{"ev", "covered"}}, lotID: 1, features: []string{"wide", "covered"}, want: true},
+		{name: "spot has no listed feature", promo: PromoCode{SpotFeatures: []string{"ev"}}, lotID: 1, features: []string{"covered"}, want: false},
+		{name: "spot without features", promo: PromoCode{SpotFeatures: []string{"ev"}}, lotID: 1, want: false},
+		{name: "lot and feature both match", promo: PromoCode{LotIDs: []int{1}, SpotFeatures: []string{"ev"}}, lotID: 1, features: []string{"ev"}, want: true},
+		{name: "feature matches in another lot", promo: PromoCode{LotIDs: []int{1}, SpotFeatures: []string{"ev"}}, lotID: 2, features: []string{"ev"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.promo.AppliesTo(tt.lotID, tt.features); got != tt.want {
+				t.Fatalf("AppliesTo(%d, %v) = %v, want %v", tt.lotID, tt.features, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromoCodeCheck(t *testing.T) {
+	now := time.Now()
+	hourAgo, inHour := now.Add(-time.Hour), now.Add(time.Hour)
+	limit := func(value int) *int { return &value }
+
+	tests := []struct {
+		name  string
+		promo PromoCode
+		// own и others — сколько броней с промокодом уже у пользователя и у других
+		own, others int
+		// cancelled — брони пользователя с промокодом отменены и не занимают лимит
+		cancelled bool
+		wantCode  string
+	}{
+		{name: "active without limits", promo: PromoCode{Active: true}, own: 2, others: 2},
+		{name: "inactive", promo: PromoCode{Active: false}, wantCode: PolicyPromoCodeInvalid},
+		{name: "not started yet", promo: PromoCode{Active: true, ValidFrom: &inHour}, wantCode: PolicyPromoCodeInvalid},
+		{name: "expired", promo: PromoCode{Active: true, ValidUntil: &hourAgo}, wantCode: PolicyPromoCodeInvalid},
+		{name: "within the validity window", promo: PromoCode{Active: true, ValidFrom: &hourAgo, ValidUntil: &inHour}},
+		{name: "total limit reached", promo: PromoCode{Active: true, MaxUses: limit(2)}, others: 2, wantCode: PolicyPromoCodeExhausted},
+		{name: "total limit not reached", promo: PromoCode{Active: true, MaxUses: limit(2)}, others: 1},
+		{name: "per user limit reached", promo: PromoCode{Active: true, MaxUsesPerUser: limit(1)}, own: 1, wantCode: PolicyPromoCodeExhausted},
+		{name: "per user limit ignores other users", promo: PromoCode{Active: true, MaxUsesPerUser: limit(1)}, others: 3},
+		{name: "cancelled bookings free the limit", promo: PromoCode{Active: true, MaxUses: limit(1)}, own: 1, cancelled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			userID := testutil.CreateUser(t, db, "promo@example.com")
+			otherID := testutil.CreateUser(t, db, "other@example.com")
+
+			promo := tt.promo
+			promo.Code = "SPRING"
+			id, err := CreatePromoCode(db, &promo)
+			if err != nil {
+				t.Fatalf("create promo code: %v", err)
+			}
+			promo.ID = id
+
+			// Каждая бронь занимает свое место, чтобы брони не пересекались
+			spot := 0
+			redeem := func(owner int, status BookingStatus) {
+				spot++
+				start := now.Add(24 * time.Hour).Truncate(time.Hour)
+				bookingID, err := CreateBooking(db, &Booking{
+					UserID: owner, ParkingSpot: spot, CarNumber: "AA123BB",
+					ReservedAt: start, PlannedEndsAt: start.Add(time.Hour), Status: status,
+				})
+				if err != nil {
+					t.Fatalf("create booking: %v", err)
+				}
+				if err := RedeemPromoCode(db, promo.ID, owner, bookingID, 0); err != nil {
+					t.Fatalf("redeem promo code: %v", err)
+				}
+			}
+			ownStatus := StatusActive
+			if tt.cancelled {
+				ownStatus = StatusCancelled
+			}
+			for i := 0; i < tt.own; i++ {
+				redeem(userID, ownStatus)
+			}
+			for i := 0; i < tt.others; i++ {
+				redeem(otherID, StatusActive)
+			}
+
+			err = promo.Check(db, userID, now)
+			if code := violationCode(t, err); code != tt.wantCode {
+				t.Fatalf("got code %q, want %q", code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Статусы возврата через провайдера
+const (
+	ProviderRefundPending   = "pending"
+	ProviderRefundSucceeded = "succeeded"
+	ProviderRefundFailed    = "failed"
+)
+
+// ErrProviderRefundNotFound возвращается, если возврата нет в очереди
+var ErrProviderRefundNotFound = errors.New("provider refund not found")
+
+// Сколько раз возврат отправляется провайдеру, прежде чем он помечается неудавшимся
+const maxProviderRefundAttempts = 10
+
+// ProviderRefund — возврат по платежу, который нужно отправить провайдеру; сумма в копейках
+type ProviderRefund struct {
+	ID        int
+	PaymentID int
+	BookingID *int
+	Provider  string
+	Reference string
+	Amount    int64
+	Currency  string
+	Status    string
+	Attempts  int
+	LastError *string
+}
+
+// RefundSender отправляет возврат провайдеру provider по его платежу reference;
+// refundID служит ключом идемпотентности
+type RefundSender func(provider string, refundID int, reference string, amount int64, currency string) error
+
+// QueueProviderRefund записывает возврат по платежу в очередь на отправку провайдеру.
+// Запись создается в той же транзакции, что и проводки возврата, а отправляется после ее фиксации
+func QueueProviderRefund(tx *sql.Tx, payment Payment, bookingID *int, amount int64) (int, error) {
+	var id int
+	err := tx.QueryRow(`
+		INSERT INTO provider_refunds (payment_id, booking_id, amount, currency)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, payment.ID, bookingID, amount, payment.Currency).Scan(&id)
+	return id, err
+}
+
+// GetProviderRefund возвращает возврат из очереди по его ID
+func GetProviderRefund(db Queryer, id int) (*ProviderRefund, error) {
+	var refund ProviderRefund
+	err := db.QueryRow(`
+		SELECT r.id, r.payment_id, r.booking_id, p.provider, COALESCE(p.reference, ''),
+			r.amount, r.currency, r.status, r.attempts, r.last_error
+		FROM provider_refunds r
+		JOIN payments p ON p.id = r.payment_id
+		WHERE r.id = $1
+	`, id).Scan(&refund.ID, &refund.PaymentID, &refund.BookingID, &refund.Provider, &refund.Reference,
+		&refund.Amount, &refund.Currency, &refund.Status, &refund.Attempts, &refund.LastError)
+	if err == sql.ErrNoRows {
+		return nil, ErrProviderRefundNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// ProcessProviderRefunds отправляет провайдеру возвраты из очереди, время попытки которых наступило.
+// Возврат остается заблокированным, пока провайдер отвечает, поэтому параллельные вызовы не отправят
+// его дважды. После ошибки пауза до следующей попытки удваивается, начиная с минуты, а после
+// maxProviderRefundAttempts попыток возврат помечается failed. Возвращает число отправленных
+// и неудавшихся попыток; ошибки провайдера сохраняются в last_error
+func ProcessProviderRefunds(db *sql.DB, send RefundSender) (sent, failed int, err error) {
+	return processProviderRefunds(db, send, nil)
+}
+
+// SendProviderRefunds отправляет провайдеру только перечисленные возвраты из очереди,
+// как ProcessProviderRefunds. Так запрос, создавший возвраты, не ждет чужую очередь
+func SendProviderRefunds(db *sql.DB, send RefundSender, ids []int) (sent, failed int, err error) {
+	if len(ids) == 0 {
+		return 0, 0, nil
+	}
+	refundIDs := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		refundIDs[i] = int64(id)
+	}
+	return processProviderRefunds(db, send, refundIDs)
+}
+
+// processProviderRefunds отправляет возвраты по одному, пока в очереди есть подходящие; ids == nil — любые
+func processProviderRefunds(db *sql.DB, send RefundSender, ids pq.Int64Array) (sent, failed int, err error) {
+	for {
+		found, ok, err := processNextProviderRefund(db, send, ids)
+		if err != nil || !found {
+			return sent, failed, err
+		}
+		if ok {
+			sent++
+		} else {
+			failed++
+		}
+	}
+}
+
+// processNextProviderRefund отправляет один возврат из очереди. found — нашелся ли возврат, ok — принял ли его провайдер
+func processNextProviderRefund(db *sql.DB, send RefundSender, ids pq.Int64Array) (found, ok bool, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, false, err
+	}
+	defer tx.Rollback()
+
+	var refund ProviderRefund
+	err = tx.QueryRow(`
+		SELECT r.id, p.provider, COALESCE(p.reference, ''), r.amount, r.currency, r.attempts
+		FROM provider_refunds r
+		JOIN payments p ON p.id = r.payment_id
+		WHERE r.status = 'pending' AND r.next_attempt_at <= NOW()
+			AND ($1::INTEGER[] IS NULL OR r.id = ANY($1))
+		ORDER BY r.next_attempt_at, r.id
+		LIMIT 1
+		FOR UPDATE OF r SKIP LOCKED
+	`, ids).Scan(&refund.ID, &refund.Provider, &refund.Reference, &refund.Amount, &refund.Currency, &refund.Attempts)
+	if err == sql.ErrNoRows {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	sendErr := send(refund.Provider, refund.ID, refund.Reference, refund.Amount, refund.Currency)
+	if sendErr == nil {
+		_, err = tx.Exec(`
+			UPDATE provider_refunds
+			SET status = $2, attempts = attempts + 1, last_error = NULL, completed_at = NOW()
+			WHERE id = $1
+		`, refund.ID, ProviderRefundSucceeded)
+	} else {
+		status := ProviderRefundPending
+		if refund.Attempts+1 >= maxProviderRefundAttempts {
+			status = ProviderRefundFailed
+		}
+		_, err = tx.Exec(`
+			UPDATE provider_refunds
+			SET status = $2, attempts = attempts + 1, last_error = $3,
+				next_attempt_at = NOW() + INTERVAL '1 minute' * POWER(2, attempts)
+			WHERE id = $1
+		`, refund.ID, status, sendErr.Error())
+	}
+	if err != nil {
+		return true, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return true, false, err
+	}
+	return true, sendErr == nil, nil
+}
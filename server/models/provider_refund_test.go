@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"server/testutil"
+)
+
+func TestProcessProviderRefunds(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userID := testutil.CreateUser(t, db, "payer@example.com")
+
+	payment := Payment{UserID: userID, Provider: "fake", Amount: 10000, Currency: "RUB", ExpiresAt: time.Now().Add(time.Hour)}
+	if _, err := CreatePayment(db, &payment, nil); err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	if err := SetPaymentCheckout(db, payment.ID, "fake_1_abc", ""); err != nil {
+		t.Fatalf("set checkout: %v", err)
+	}
+
+	queue := func(amount int64) int {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("begin: %v", err)
+		}
+		defer tx.Rollback()
+		id, err := QueueProviderRefund(tx, payment, nil, amount)
+		if err != nil {
+			t.Fatalf("queue refund: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+		return id
+	}
+	get := func(id int) *ProviderRefund {
+		refund, err := GetProviderRefund(db, id)
+		if err != nil {
+			t.Fatalf("get refund: %v", err)
+		}
+		return refund
+	}
+
+	var calls []int
+	reject := func(provider string, refundID int, reference string, amount int64, currency string) error {
+		calls = append(calls, refundID)
+		return errors.New("provider is down")
+	}
+	accept := func(provider string, refundID int, reference string, amount int64, currency string) error {
+		calls = append(calls, refundID)
+		if provider != "fake" || reference != "fake_1_abc" || currency != "RUB" {
+			t.Errorf("refund sent as %s %s %s", provider, reference, currency)
+		}
+		return nil
+	}
+
+	first := queue(3000)
+	second := queue(2000)
+
+	// Отправляются только перечисленные возвраты
+	sent, failed, err := SendProviderRefunds(db, accept, []int{first})
+	if err != nil || sent != 1 || failed != 0 {
+		t.Fatalf("SendProviderRefunds = %d, %d, %v, want 1 sent", sent, failed, err)
+	}
+	if refund := get(first); refund.Status != ProviderRefundSucceeded || refund.Attempts != 1 {
+		t.Fatalf("first refund is %s after %d attempts, want succeeded after 1", refund.Status, refund.Attempts)
+	}
+	if refund := get(second); refund.Status != ProviderRefundPending {
+		t.Fatalf("second refund is %s, want pending", refund.Status)
+	}
+
+	// Ошибка провайдера откладывает следующую попытку
+	calls = nil
+	sent, failed, err = ProcessProviderRefunds(db, reject)
+	if err != nil || sent != 0 || failed != 1 {
+		t.Fatalf("ProcessProviderRefunds = %d, %d, %v, want 1 failed", sent, failed, err)
+	}
+	refund := get(second)
+	if refund.Status != ProviderRefundPending || refund.Attempts != 1 || refund.LastError == nil {
+		t.Fatalf("rejected refund is %s after %d attempts, error %v", refund.Status, refund.Attempts, refund.LastError)
+	}
+	if sent, failed, _ = ProcessProviderRefunds(db, accept); sent+failed != 0 {
+		t.Fatal("refund was retried before its next attempt time")
+	}
+	if len(calls) != 1 || calls[0] != second {
+		t.Fatalf("provider was called for %v, want only refund %d", calls, second)
+	}
+
+	// Когда время попытки наступает, возврат уходит повторно
+	if _, err := db.Exec(`UPDATE provider_refunds SET next_attempt_at = NOW() WHERE id = $1`, second); err != nil {
+		t.Fatalf("reschedule: %v", err)
+	}
+	if sent, _, err = ProcessProviderRefunds(db, accept); err != nil || sent != 1 {
+		t.Fatalf("retry sent %d, %v, want 1", sent, err)
+	}
+	if refund := get(second); refund.Status != ProviderRefundSucceeded || refund.LastError != nil {
+		t.Fatalf("retried refund is %s, error %v", refund.Status, refund.LastError)
+	}
+
+	// После последней попытки возврат больше не отправляется
+	third := queue(1000)
+	if _, err := db.Exec(`UPDATE provider_refunds SET attempts = $2 WHERE id = $1`, third, maxProviderRefundAttempts-1); err != nil {
+		t.Fatalf("set attempts: %v", err)
+	}
+	if _, failed, err = ProcessProviderRefunds(db, reject); err != nil || failed != 1 {
+		t.Fatalf("last attempt failed %d, %v, want 1", failed, err)
+	}
+	if refund := get(third); refund.Status != ProviderRefundFailed {
+		t.Fatalf("refund after the last attempt is %s, want failed", refund.Status)
+	}
+}
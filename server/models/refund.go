@@ -0,0 +1,99 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RefundRule — правило отмены, по которому рассчитан возврат
+type RefundRule string
+
+const (
+	// RefundFull — отмена заранее, возвращается вся стоимость
+	RefundFull RefundRule = "full"
+	// RefundPartial — поздняя отмена до начала брони, возвращается часть стоимости
+	RefundPartial RefundRule = "partial"
+	// RefundNone — отмена после начала брони, деньги не возвращаются
+	RefundNone RefundRule = "none"
+	// RefundAdminOverride — отмена администратором, всегда полный возврат
+	RefundAdminOverride RefundRule = "admin_override"
+)
+
+// RefundPolicy — правила возврата при отмене брони
+type RefundPolicy struct {
+	// FullBefore — при отмене не позже чем за это время до начала возвращается вся стоимость
+	FullBefore time.Duration
+	// LatePercent — сколько процентов возвращается при более поздней отмене до начала брони
+	LatePercent int
+}
+
+// Refund — возврат за отмененную бронь; суммы в копейках
+type Refund struct {
+	BookingID int        `json:"bookingId"`
+	Rule      RefundRule `json:"rule"`
+	Percent   int        `json:"percent"`
+	// Charged — сколько было начислено за бронь к моменту отмены
+	Charged  int64  `json:"charged"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	// ProviderAmount — часть возврата, отправленная обратно через платежного провайдера,
+	// WalletAmount — часть, зачисленная на баланс пользователя
+	ProviderAmount int64 `json:"providerAmount"`
+	WalletAmount   int64 `json:"walletAmount"`
+	PaymentID      *int  `json:"paymentId,omitempty"`
+	// ProviderRefundID — возврат в очереди на отправку провайдеру
+	ProviderRefundID *int `json:"providerRefundId,omitempty"`
+}
+
+// Apply рассчитывает возврат за бронь, начинающуюся в startsAt и отменяемую в now;
+// admin — отмена администратором
+func (p RefundPolicy) Apply(charged int64, startsAt, now time.Time, admin bool) Refund {
+	refund := Refund{Charged: charged}
+	switch {
+	case admin:
+		refund.Rule, refund.Percent = RefundAdminOverride, 100
+	case !now.Before(startsAt):
+		refund.Rule, refund.Percent = RefundNone, 0
+	case startsAt.Sub(now) >= p.FullBefore:
+		refund.Rule, refund.Percent = RefundFull, 100
+	default:
+		refund.Rule, refund.Percent = RefundPartial, p.LatePercent
+	}
+	refund.Amount = charged * int64(refund.Percent) / 100
+	return refund
+}
+
+// BookingCharged возвращает, сколько начислено за бронь с учетом уже сделанных возвратов
+func BookingCharged(db Queryer, bookingID int) (int64, error) {
+	var charged int64
+	err := db.QueryRow(`
+		SELECT -COALESCE(SUM(e.amount), 0)
+		FROM ledger_entries e
+		JOIN ledger_transactions t ON t.id = e.transaction_id
+		WHERE e.account = 'revenue' AND t.booking_id = $1
+	`, bookingID).Scan(&charged)
+	return charged, err
+}
+
+// PostBookingRefund возвращает на баланс пользователя часть начисленной стоимости брони
+func PostBookingRefund(tx *sql.Tx, userID, bookingID int, amount int64, currency string, createdBy *int) error {
+	return PostTransaction(tx, &LedgerTransaction{
+		Kind:      LedgerRefund,
+		UserID:    userID,
+		BookingID: &bookingID,
+		Currency:  currency,
+		CreatedBy: createdBy,
+		Entries: []LedgerEntry{
+			{Account: AccountRevenue, Amount: amount},
+			userEntry(userID, -amount),
+		},
+	})
+}
+
+// SetBookingRefund сохраняет рассчитанный при отмене возврат
+func SetBookingRefund(db Queryer, bookingID int, refund Refund) error {
+	_, err := db.Exec(`
+		UPDATE bookings SET refund_amount = $2, refund_rule = $3 WHERE id = $1
+	`, bookingID, refund.Amount, string(refund.Rule))
+	return err
+}
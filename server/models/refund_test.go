@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefundPolicyApply(t *testing.T) {
+	startsAt := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	policy := RefundPolicy{FullBefore: time.Hour, LatePercent: 50}
+
+	tests := []struct {
+		name       string
+		policy     RefundPolicy
+		charged    int64
+		now        time.Time
+		admin      bool
+		wantRule   RefundRule
+		wantAmount int64
+	}{
+		{name: "well in advance", policy: policy, charged: 10000, now: startsAt.Add(-2 * time.Hour), wantRule: RefundFull, wantAmount: 10000},
+		{name: "exactly at the full refund boundary", policy: policy, charged: 10000, now: startsAt.Add(-time.Hour), wantRule: RefundFull, wantAmount: 10000},
+		{name: "just after the full refund boundary", policy: policy, charged: 10000, now: startsAt.Add(-time.Hour + time.Second), wantRule: RefundPartial, wantAmount: 5000},
+		{name: "just before the start", policy: policy, charged: 10000, now: startsAt.Add(-time.Second), wantRule: RefundPartial, wantAmount: 5000},
+		{name: "exactly at the start", policy: policy, charged: 10000, now: startsAt, wantRule: RefundNone, wantAmount: 0},
+		{name: "after the start", policy: policy, charged: 10000, now: startsAt.Add(time.Minute), wantRule: RefundNone, wantAmount: 0},
+		{name: "admin after the start", policy: policy, charged: 10000, now: startsAt.Add(time.Minute), admin: true, wantRule: RefundAdminOverride, wantAmount: 10000},
+		{name: "partial amount is rounded down", policy: policy, charged: 9999, now: startsAt.Add(-time.Minute), wantRule: RefundPartial, wantAmount: 4999},
+		{name: "no full refund window", policy: RefundPolicy{LatePercent: 50}, charged: 10000, now: startsAt.Add(-time.Second), wantRule: RefundFull, wantAmount: 10000},
+		{name: "nothing charged", policy: policy, charged: 0, now: startsAt.Add(-2 * time.Hour), wantRule: RefundFull, wantAmount: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refund := tt.policy.Apply(tt.charged, startsAt, tt.now, tt.admin)
+			if refund.Rule != tt.wantRule || refund.Amount != tt.wantAmount {
+				t.Fatalf("Apply = %s %d, want %s %d", refund.Rule, refund.Amount, tt.wantRule, tt.wantAmount)
+			}
+			if refund.Charged != tt.charged {
+				t.Fatalf("Charged = %d, want %d", refund.Charged, tt.charged)
+			}
+		})
+	}
+}
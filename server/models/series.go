@@ -0,0 +1,224 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Статусы повторяющейся брони
+const (
+	SeriesActive    = "active"
+	SeriesCancelled = "cancelled"
+)
+
+// Формат даты вхождения серии
+const DateLayout = "2006-01-02"
+
+var ErrSeriesNotFound = errors.New("booking series not found")
+
+// Series — повторяющаяся бронь по дням недели, из которой создаются отдельные брони
+type Series struct {
+	ID          int    `json:"id"`
+	UserID      int    `json:"userId"`
+	ParkingSpot int    `json:"parkingSpot"`
+	CarNumber   string `json:"carNumber"`
+	// Weekdays — дни недели по ISO 8601: 1 — понедельник, 7 — воскресенье
+	Weekdays []int `json:"weekdays"`
+	// StartTime и EndTime — время суток в формате 15:04 в часовом поясе парковки
+	StartTime  string    `json:"startTime"`
+	EndTime    string    `json:"endTime"`
+	StartsOn   string    `json:"startsOn"`
+	EndsOn     string    `json:"endsOn"`
+	Exceptions []string  `json:"exceptions"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Occurrence — одно вхождение серии
+type Occurrence struct {
+	Date     string
+	StartsAt time.Time
+	EndsAt   time.Time
+}
+
+// Occurrences возвращает вхождения серии, начинающиеся не раньше notBefore, кроме дат-исключений
+func (s Series) Occurrences(loc *time.Location, notBefore time.Time) ([]Occurrence, error) {
+	startsOn, err := time.ParseInLocation(DateLayout, s.StartsOn, loc)
+	if err != nil {
+		return nil, err
+	}
+	endsOn, err := time.ParseInLocation(DateLayout, s.EndsOn, loc)
+	if err != nil {
+		return nil, err
+	}
+	startTime, err := time.Parse("15:04", s.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := time.Parse("15:04", s.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	weekdays := map[time.Weekday]bool{}
+	for _, day := range s.Weekdays {
+		weekdays[time.Weekday(day%7)] = true
+	}
+	skipped := map[string]bool{}
+	for _, date := range s.Exceptions {
+		skipped[date] = true
+	}
+
+	var occurrences []Occurrence
+	for day := startsOn; !day.After(endsOn); day = day.AddDate(0, 0, 1) {
+		date := day.Format(DateLayout)
+		if !weekdays[day.Weekday()] || skipped[date] {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), startTime.Hour(), startTime.Minute(), 0, 0, loc)
+		if start.Before(notBefore) {
+			continue
+		}
+		end := time.Date(day.Year(), day.Month(), day.Day(), endTime.Hour(), endTime.Minute(), 0, 0, loc)
+		occurrences = append(occurrences, Occurrence{Date: date, StartsAt: start, EndsAt: end})
+	}
+
+	return occurrences, nil
+}
+
+// CreateSeries сохраняет серию вместе с датами-исключениями
+func CreateSeries(db Queryer, series *Series) (int, error) {
+	weekdays := make([]int64, len(series.Weekdays))
+	for i, day := range series.Weekdays {
+		weekdays[i] = int64(day)
+	}
+
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO booking_series (user_id, parking_spot, car_number, weekdays, start_time, end_time, starts_on, ends_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, series.UserID, series.ParkingSpot, series.CarNumber, pq.Array(weekdays),
+		series.StartTime, series.EndTime, series.StartsOn, series.EndsOn).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, date := range series.Exceptions {
+		if err := AddSeriesException(db, id, date); err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// AddSeriesException исключает дату из серии
+func AddSeriesException(db Queryer, seriesID int, date string) error {
+	_, err := db.Exec(`
+		INSERT INTO booking_series_exceptions (series_id, occurs_on)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, seriesID, date)
+	return err
+}
+
+const seriesColumns = `id, user_id, parking_spot, car_number, weekdays,
+	to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI'),
+	to_char(starts_on, 'YYYY-MM-DD'), to_char(ends_on, 'YYYY-MM-DD'), status, created_at,
+	ARRAY(SELECT to_char(occurs_on, 'YYYY-MM-DD') FROM booking_series_exceptions e
+	      WHERE e.series_id = booking_series.id ORDER BY occurs_on)`
+
+func scanSeriesRow(row rowScanner, series *Series) error {
+	var weekdays []int64
+	err := row.Scan(&series.ID, &series.UserID, &series.ParkingSpot, &series.CarNumber, pq.Array(&weekdays),
+		&series.StartTime, &series.EndTime, &series.StartsOn, &series.EndsOn, &series.Status, &series.CreatedAt,
+		pq.Array(&series.Exceptions))
+	if err != nil {
+		return err
+	}
+	series.Weekdays = make([]int, len(weekdays))
+	for i, day := range weekdays {
+		series.Weekdays[i] = int(day)
+	}
+	if series.Exceptions == nil {
+		series.Exceptions = []string{}
+	}
+	return nil
+}
+
+// GetUserSeries возвращает серию пользователя или ErrSeriesNotFound
+func GetUserSeries(db Queryer, seriesID, userID int) (*Series, error) {
+	var series Series
+	err := scanSeriesRow(db.QueryRow(`
+		SELECT `+seriesColumns+`
+		FROM booking_series
+		WHERE id = $1 AND user_id = $2
+	`, seriesID, userID), &series)
+	if err == sql.ErrNoRows {
+		return nil, ErrSeriesNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// ListUserSeries возвращает все серии пользователя, новые первыми
+func ListUserSeries(db Queryer, userID int) ([]Series, error) {
+	rows, err := db.Query(`
+		SELECT `+seriesColumns+`
+		FROM booking_series
+		WHERE user_id = $1
+		ORDER BY id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seriesList := []Series{}
+	for rows.Next() {
+		var series Series
+		if err := scanSeriesRow(rows, &series); err != nil {
+			return nil, err
+		}
+		seriesList = append(seriesList, series)
+	}
+
+	return seriesList, rows.Err()
+}
+
+// ListSeriesBookings возвращает брони, созданные из серии, в порядке начала
+func ListSeriesBookings(db Queryer, seriesID int) ([]Booking, error) {
+	rows, err := db.Query(`
+		SELECT `+bookingColumns+`
+		FROM bookings
+		WHERE series_id = $1
+		ORDER BY reserved_at
+	`, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookings := []Booking{}
+	for rows.Next() {
+		var booking Booking
+		if err := scanBookingRow(rows, &booking); err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, booking)
+	}
+
+	return bookings, rows.Err()
+}
+
+// SetSeriesStatus меняет статус серии
+func SetSeriesStatus(db Queryer, seriesID int, status string) error {
+	_, err := db.Exec(`UPDATE booking_series SET status = $2 WHERE id = $1`, seriesID, status)
+	return err
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load location %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestSeriesOccurrences(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+
+	tests := []struct {
+		name      string
+		series    Series
+		notBefore time.Time
+		want      []string
+	}{
+		{
+			name: "weekdays within range",
+			series: Series{
+				Weekdays: []int{1, 3, 5}, StartTime: "09:00", EndTime: "18:00",
+				StartsOn: "2026-03-02", EndsOn: "2026-03-08",
+			},
+			want: []string{"2026-03-02", "2026-03-04", "2026-03-06"},
+		},
+		{
+			name: "ISO sunday is 7",
+			series: Series{
+				Weekdays: []int{7}, StartTime: "10:00", EndTime: "11:00",
+				StartsOn: "2026-03-01", EndsOn: "2026-03-15",
+			},
+			want: []string{"2026-03-01", "2026-03-08", "2026-03-15"},
+		},
+		{
+			name: "exceptions are skipped",
+			series: Series{
+				Weekdays: []int{2}, StartTime: "09:00", EndTime: "10:00",
+				StartsOn: "2026-03-03", EndsOn: "2026-03-24", Exceptions: []string{"2026-03-10", "2026-03-17"},
+			},
+			want: []string{"2026-03-03", "2026-03-24"},
+		},
+		{
+			name: "occurrences before notBefore are dropped",
+			series: Series{
+				Weekdays: []int{1, 2, 3}, StartTime: "09:00", EndTime: "10:00",
+				StartsOn: "2026-03-02", EndsOn: "2026-03-04",
+			},
+			notBefore: time.Date(2026, 3, 3, 9, 0, 0, 0, berlin),
+			want:      []string{"2026-03-03", "2026-03-04"},
+		},
+		{
+			name: "week boundary from sunday to monday",
+			series: Series{
+				Weekdays: []int{7, 1}, StartTime: "22:00", EndTime: "23:00",
+				StartsOn: "2026-03-07", EndsOn: "2026-03-09",
+			},
+			want: []string{"2026-03-08", "2026-03-09"},
+		},
+		{
+			name: "empty range",
+			series: Series{
+				Weekdays: []int{1}, StartTime: "09:00", EndTime: "10:00",
+				StartsOn: "2026-03-03", EndsOn: "2026-03-08",
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			occurrences, err := tt.series.Occurrences(berlin, tt.notBefore)
+			if err != nil {
+				t.Fatalf("Occurrences: %v", err)
+			}
+			var dates []string
+			for _, occurrence := range occurrences {
+				dates = append(dates, occurrence.Date)
+			}
+			if !reflect.DeepEqual(dates, tt.want) {
+				t.Fatalf("got dates %v, want %v", dates, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeriesOccurrencesAcrossDST(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	// В ночь на 29 марта 2026 в Берлине часы переводятся с UTC+1 на UTC+2
+	series := Series{
+		Weekdays: []int{6, 7, 1}, StartTime: "08:00", EndTime: "09:30",
+		StartsOn: "2026-03-28", EndsOn: "2026-03-30",
+	}
+
+	occurrences, err := series.Occurrences(berlin, time.Time{})
+	if err != nil {
+		t.Fatalf("Occurrences: %v", err)
+	}
+	wantUTC := []time.Time{
+		time.Date(2026, 3, 28, 7, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 29, 6, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 30, 6, 0, 0, 0, time.UTC),
+	}
+	if len(occurrences) != len(wantUTC) {
+		t.Fatalf("got %d occurrences, want %d", len(occurrences), len(wantUTC))
+	}
+	for i, occurrence := range occurrences {
+		if !occurrence.StartsAt.Equal(wantUTC[i]) {
+			t.Errorf("%s: starts at %v, want %v", occurrence.Date, occurrence.StartsAt.UTC(), wantUTC[i])
+		}
+		if got := occurrence.EndsAt.Sub(occurrence.StartsAt); got != 90*time.Minute {
+			t.Errorf("%s: lasts %v, want 1h30m", occurrence.Date, got)
+		}
+	}
+}
+
+func TestSeriesOccurrencesInvalid(t *testing.T) {
+	tests := []Series{
+		{Weekdays: []int{1}, StartTime: "09:00", EndTime: "10:00", StartsOn: "2026-13-01", EndsOn: "2026-03-08"},
+		{Weekdays: []int{1}, StartTime: "09:00", EndTime: "10:00", StartsOn: "2026-03-01", EndsOn: "tomorrow"},
+		{Weekdays: []int{1}, StartTime: "9am", EndTime: "10:00", StartsOn: "2026-03-01", EndsOn: "2026-03-08"},
+		{Weekdays: []int{1}, StartTime: "09:00", EndTime: "25:00", StartsOn: "2026-03-01", EndsOn: "2026-03-08"},
+	}
+	for _, series := range tests {
+		if _, err := series.Occurrences(time.UTC, time.Time{}); err == nil {
+			t.Errorf("expected an error for %+v", series)
+		}
+	}
+}
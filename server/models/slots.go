@@ -0,0 +1,56 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Режимы выравнивания границ брони по слотам
+const (
+	// RoundExpand расширяет интервал: начало округляется вниз, окончание вверх
+	RoundExpand = "expand"
+	// RoundNearest округляет обе границы до ближайшего слота
+	RoundNearest = "nearest"
+	// RoundStrict отклоняет границы, не совпадающие с началом слота
+	RoundStrict = "strict"
+)
+
+var ErrUnalignedTime = errors.New("time is not aligned to the slot granularity")
+
+// AlignStart выравнивает начало брони по слоту; сетка слотов отсчитывается от полуночи в часовом поясе loc
+func AlignStart(t time.Time, slot time.Duration, mode string, loc *time.Location) (time.Time, error) {
+	return align(t, slot, mode, loc, false)
+}
+
+// AlignEnd выравнивает окончание брони по слоту; сетка слотов отсчитывается от полуночи в часовом поясе loc
+func AlignEnd(t time.Time, slot time.Duration, mode string, loc *time.Location) (time.Time, error) {
+	return align(t, slot, mode, loc, true)
+}
+
+func align(t time.Time, slot time.Duration, mode string, loc *time.Location, up bool) (time.Time, error) {
+	if slot <= 0 {
+		return t, nil
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	// Truncate и Round считают от нулевого момента UTC, поэтому время сдвигается на смещение пояса
+	_, offset := t.In(loc).Zone()
+	shift := time.Duration(offset) * time.Second
+	floor := t.Add(shift).Truncate(slot).Add(-shift)
+	if floor.Equal(t) {
+		return t, nil
+	}
+
+	switch mode {
+	case RoundStrict:
+		return t, ErrUnalignedTime
+	case RoundNearest:
+		return t.Add(shift).Round(slot).Add(-shift), nil
+	default:
+		if up {
+			return floor.Add(slot), nil
+		}
+		return floor, nil
+	}
+}
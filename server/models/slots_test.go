@@ -0,0 +1,148 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAlign(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 3, 2, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		t         time.Time
+		slot      time.Duration
+		mode      string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   error
+	}{
+		{name: "aligned time is kept", t: at(9, 30), slot: 15 * time.Minute, mode: RoundStrict, wantStart: at(9, 30), wantEnd: at(9, 30)},
+		{name: "zero slot disables alignment", t: at(9, 7), slot: 0, mode: RoundStrict, wantStart: at(9, 7), wantEnd: at(9, 7)},
+		{name: "expand rounds start down and end up", t: at(9, 7), slot: 15 * time.Minute, mode: RoundExpand, wantStart: at(9, 0), wantEnd: at(9, 15)},
+		{name: "unknown mode expands", t: at(9, 7), slot: 15 * time.Minute, mode: "", wantStart: at(9, 0), wantEnd: at(9, 15)},
+		{name: "nearest rounds down below half", t: at(9, 7), slot: 15 * time.Minute, mode: RoundNearest, wantStart: at(9, 0), wantEnd: at(9, 0)},
+		{name: "nearest rounds half up", t: at(9, 30), slot: time.Hour, mode: RoundNearest, wantStart: at(10, 0), wantEnd: at(10, 0)},
+		{name: "expand crosses midnight", t: at(23, 50), slot: 30 * time.Minute, mode: RoundExpand, wantStart: at(23, 30), wantEnd: at(0, 0).AddDate(0, 0, 1)},
+		{name: "strict rejects unaligned time", t: at(9, 7), slot: 15 * time.Minute, mode: RoundStrict, wantErr: ErrUnalignedTime},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, err := AlignStart(tt.t, tt.slot, tt.mode, time.UTC)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AlignStart error %v, want %v", err, tt.wantErr)
+			}
+			end, err := AlignEnd(tt.t, tt.slot, tt.mode, time.UTC)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AlignEnd error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !start.Equal(tt.wantStart) {
+				t.Errorf("AlignStart = %v, want %v", start, tt.wantStart)
+			}
+			if !end.Equal(tt.wantEnd) {
+				t.Errorf("AlignEnd = %v, want %v", end, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestAlignAcrossDST(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	// 29 марта 2026 в 02:00 часы в Берлине переводятся сразу на 03:00
+	before := time.Date(2026, 3, 29, 1, 50, 0, 0, berlin)
+
+	end, err := AlignEnd(before, 30*time.Minute, RoundExpand, berlin)
+	if err != nil {
+		t.Fatalf("AlignEnd: %v", err)
+	}
+	if want := time.Date(2026, 3, 29, 3, 0, 0, 0, berlin); !end.Equal(want) {
+		t.Fatalf("AlignEnd = %v, want %v", end.In(berlin), want)
+	}
+	if got := end.Sub(before); got != 10*time.Minute {
+		t.Fatalf("slot end is %v after the start, want 10m", got)
+	}
+}
+
+func TestAlignInLotTimezone(t *testing.T) {
+	kolkata := mustLoadLocation(t, "Asia/Kolkata")
+	moscow := mustLoadLocation(t, "Europe/Moscow")
+
+	tests := []struct {
+		name      string
+		t         time.Time
+		slot      time.Duration
+		mode      string
+		loc       *time.Location
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			name:      "hourly slots start on the local hour with a half-hour offset",
+			t:         time.Date(2026, 3, 2, 10, 10, 0, 0, kolkata),
+			slot:      time.Hour,
+			mode:      RoundExpand,
+			loc:       kolkata,
+			wantStart: time.Date(2026, 3, 2, 10, 0, 0, 0, kolkata),
+			wantEnd:   time.Date(2026, 3, 2, 11, 0, 0, 0, kolkata),
+		},
+		{
+			name:      "nearest uses the local grid",
+			t:         time.Date(2026, 3, 2, 10, 40, 0, 0, kolkata),
+			slot:      time.Hour,
+			mode:      RoundNearest,
+			loc:       kolkata,
+			wantStart: time.Date(2026, 3, 2, 11, 0, 0, 0, kolkata),
+			wantEnd:   time.Date(2026, 3, 2, 11, 0, 0, 0, kolkata),
+		},
+		{
+			name:      "daily slots start at local midnight",
+			t:         time.Date(2026, 3, 2, 1, 30, 0, 0, moscow),
+			slot:      24 * time.Hour,
+			mode:      RoundExpand,
+			loc:       moscow,
+			wantStart: time.Date(2026, 3, 2, 0, 0, 0, 0, moscow),
+			wantEnd:   time.Date(2026, 3, 3, 0, 0, 0, 0, moscow),
+		},
+		{
+			name:      "nil location means UTC",
+			t:         time.Date(2026, 3, 2, 10, 10, 0, 0, kolkata),
+			slot:      time.Hour,
+			mode:      RoundExpand,
+			loc:       nil,
+			wantStart: time.Date(2026, 3, 2, 4, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 3, 2, 5, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, err := AlignStart(tt.t, tt.slot, tt.mode, tt.loc)
+			if err != nil {
+				t.Fatalf("AlignStart: %v", err)
+			}
+			end, err := AlignEnd(tt.t, tt.slot, tt.mode, tt.loc)
+			if err != nil {
+				t.Fatalf("AlignEnd: %v", err)
+			}
+			if !start.Equal(tt.wantStart) {
+				t.Errorf("AlignStart = %v, want %v", start, tt.wantStart)
+			}
+			if !end.Equal(tt.wantEnd) {
+				t.Errorf("AlignEnd = %v, want %v", end, tt.wantEnd)
+			}
+		})
+	}
+
+	// Время, выровненное по сетке парковки, в строгом режиме принимается как есть
+	aligned := time.Date(2026, 3, 2, 10, 0, 0, 0, kolkata)
+	if _, err := AlignStart(aligned, time.Hour, RoundStrict, kolkata); err != nil {
+		t.Fatalf("AlignStart rejected a time aligned in the lot's timezone: %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Характеристики места
+const (
+	FeatureEVCharger  = "ev_charger"
+	FeatureAccessible = "accessible"
+	FeatureCovered    = "covered"
+	FeatureMotorcycle = "motorcycle"
+)
+
+var (
+	ErrSpotNotFound = errors.New("parking spot not found")
+	ErrSpotExists   = errors.New("parking spot with this number or label already exists")
+	ErrSpotInUse    = errors.New("parking spot has upcoming bookings")
+)
+
+// Spot — парковочное место. Номер уникален на всех парковках и неизменен, подпись можно менять
+type Spot struct {
+	Number    int        `json:"number"`
+	Label     string     `json:"label"`
+	LotID     int        `json:"lotId"`
+	ZoneID    *int       `json:"zoneId,omitempty"`
+	Features  []string   `json:"features"`
+	RetiredAt *time.Time `json:"retiredAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+const spotColumns = "number, label, lot_id, zone_id, features, retired_at, created_at"
+
+func scanSpotRow(row rowScanner, spot *Spot) error {
+	features := pq.StringArray{}
+	err := row.Scan(&spot.Number, &spot.Label, &spot.LotID, &spot.ZoneID, &features, &spot.RetiredAt, &spot.CreatedAt)
+	spot.Features = features
+	return err
+}
+
+// IsValidFeature проверяет, что характеристика места известна
+func IsValidFeature(feature string) bool {
+	switch feature {
+	case FeatureEVCharger, FeatureAccessible, FeatureCovered, FeatureMotorcycle:
+		return true
+	}
+	return false
+}
+
+// HasFeature проверяет, есть ли у места характеристика
+func (s Spot) HasFeature(feature string) bool {
+	for _, f := range s.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidSpot проверяет, что место существует и не выведено из эксплуатации
+func IsValidSpot(db Queryer, spotNumber int) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM spots WHERE number = $1 AND retired_at IS NULL)
+	`, spotNumber).Scan(&exists)
+	return exists, err
+}
+
+// ActiveSpotNumbers возвращает номера действующих мест парковки lotID (0 — всех парковок),
+// у которых есть все характеристики features, по порядку
+func ActiveSpotNumbers(db Queryer, lotID int, features []string) ([]int, error) {
+	rows, err := db.Query(`
+		SELECT number
+		FROM spots
+		WHERE retired_at IS NULL AND ($1 = 0 OR lot_id = $1) AND features @> COALESCE($2::TEXT[], '{}')
+		ORDER BY number
+	`, lotID, pq.Array(features))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spots := []int{}
+	for rows.Next() {
+		var spot int
+		if err := rows.Scan(&spot); err != nil {
+			return nil, err
+		}
+		spots = append(spots, spot)
+	}
+
+	return spots, rows.Err()
+}
+
+// ListSpots возвращает места парковки lotID (0 — всех парковок) по номеру;
+// выведенные из эксплуатации — только по запросу
+func ListSpots(db Queryer, lotID int, includeRetired bool) ([]Spot, error) {
+	rows, err := db.Query(`
+		SELECT `+spotColumns+`
+		FROM spots
+		WHERE ($1 = 0 OR lot_id = $1) AND ($2 OR retired_at IS NULL)
+		ORDER BY number
+	`, lotID, includeRetired)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spots := []Spot{}
+	for rows.Next() {
+		var spot Spot
+		if err := scanSpotRow(rows, &spot); err != nil {
+			return nil, err
+		}
+		spots = append(spots, spot)
+	}
+
+	return spots, rows.Err()
+}
+
+// GetSpot возвращает место по номеру, включая выведенные из эксплуатации, или ErrSpotNotFound
+func GetSpot(db Queryer, spotNumber int) (*Spot, error) {
+	var spot Spot
+	err := scanSpotRow(db.QueryRow(`
+		SELECT `+spotColumns+`
+		FROM spots
+		WHERE number = $1
+	`, spotNumber), &spot)
+	if err == sql.ErrNoRows {
+		return nil, ErrSpotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &spot, nil
+}
+
+// CreateSpot добавляет место. Нулевой номер означает следующий свободный, пустая подпись — номер,
+// нулевая парковка — парковку по умолчанию (первую созданную)
+func CreateSpot(db Queryer, spot *Spot) (int, error) {
+	var number int
+	err := db.QueryRow(`
+		INSERT INTO spots (number, label, lot_id, zone_id, features)
+		SELECT n, COALESCE(NULLIF($2, ''), n::text), COALESCE(NULLIF($3, 0), (SELECT MIN(id) FROM lots)), $4,
+			COALESCE($5::TEXT[], '{}')
+		FROM (SELECT COALESCE(NULLIF($1, 0), (SELECT COALESCE(MAX(number), 0) + 1 FROM spots)) AS n) AS next
+		RETURNING number
+	`, spot.Number, spot.Label, spot.LotID, spot.ZoneID, pq.Array(spot.Features)).Scan(&number)
+	return number, translateUniqueError(err, ErrSpotExists)
+}
+
+// UpdateSpot меняет подпись, зону и характеристики места
+func UpdateSpot(db Queryer, spot *Spot) error {
+	result, err := db.Exec(`
+		UPDATE spots
+		SET label = $2, zone_id = $3, features = COALESCE($4::TEXT[], '{}')
+		WHERE number = $1
+	`, spot.Number, spot.Label, spot.ZoneID, pq.Array(spot.Features))
+	if err != nil {
+		return translateUniqueError(err, ErrSpotExists)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrSpotNotFound
+	}
+	return nil
+}
+
+// RetireSpot выводит место из эксплуатации. Место с действующими или будущими бронями
+// не выводится: сначала их нужно отменить
+func RetireSpot(tx *sql.Tx, spotNumber int) error {
+	spot, err := lockSpot(tx, spotNumber)
+	if err != nil {
+		return err
+	}
+	if spot.RetiredAt != nil {
+		return nil
+	}
+
+	var inUse bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1
+			FROM bookings
+			WHERE parking_spot = $1
+			AND upper(period) > NOW()
+			AND `+OccupyingStatusCondition+`
+		)
+	`, spotNumber).Scan(&inUse)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrSpotInUse
+	}
+
+	_, err = tx.Exec(`UPDATE spots SET retired_at = NOW() WHERE number = $1`, spotNumber)
+	return err
+}
+
+// RestoreSpot возвращает выведенное место в эксплуатацию
+func RestoreSpot(db Queryer, spotNumber int) error {
+	result, err := db.Exec(`UPDATE spots SET retired_at = NULL WHERE number = $1`, spotNumber)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrSpotNotFound
+	}
+	return nil
+}
+
+// lockSpot возвращает место и блокирует его строку до конца транзакции,
+// чтобы параллельная бронь не появилась во время вывода места
+func lockSpot(tx *sql.Tx, spotNumber int) (*Spot, error) {
+	var spot Spot
+	err := scanSpotRow(tx.QueryRow(`
+		SELECT `+spotColumns+`
+		FROM spots
+		WHERE number = $1
+		FOR UPDATE
+	`, spotNumber), &spot)
+	if err == sql.ErrNoRows {
+		return nil, ErrSpotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &spot, nil
+}
+
+// IsSpotBlocked проверяет, заблокировано ли место администратором
+func IsSpotBlocked(db Queryer, spotNumber int) (bool, error) {
+	var isBlocked bool
+	err := db.QueryRow(`
+		SELECT is_blocked
+		FROM blocked_spots
+		WHERE spot_number = $1 AND is_blocked = true
+	`, spotNumber).Scan(&isBlocked)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return isBlocked, err
+}
+
+// IsSpotAvailable проверяет, что место не заблокировано и свободно на всем интервале [start, end)
+func IsSpotAvailable(db Queryer, spotNumber int, start, end time.Time) (bool, error) {
+	blocked, err := IsSpotBlocked(db, spotNumber)
+	if err != nil || blocked {
+		return false, err
+	}
+
+	occupied, err := HasOverlappingBooking(db, spotNumber, start, end)
+	if err != nil {
+		return false, err
+	}
+
+	return !occupied, nil
+}
@@ -0,0 +1,370 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrTariffNotFound = errors.New("tariff not found")
+	ErrTariffExists   = errors.New("tariff with this name already exists")
+)
+
+// TariffRate — особая почасовая ставка в окне [StartsAt, EndsAt) по дням недели
+type TariffRate struct {
+	ID int `json:"id"`
+	// Weekdays — дни недели по ISO 8601: 1 — понедельник, 7 — воскресенье
+	Weekdays []int `json:"weekdays"`
+	// StartsAt и EndsAt — время суток в формате 15:04; EndsAt может быть 24:00
+	StartsAt   string `json:"startsAt"`
+	EndsAt     string `json:"endsAt"`
+	HourlyRate int64  `json:"hourlyRate"`
+}
+
+// Tariff — правила расчета стоимости брони. Все суммы — в копейках
+type Tariff struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// LotID и SpotFeature ограничивают места, к которым применяется тариф; nil — любые
+	LotID       *int    `json:"lotId,omitempty"`
+	SpotFeature *string `json:"spotFeature,omitempty"`
+	// HourlyRate — ставка вне особых окон Rates
+	HourlyRate int64 `json:"hourlyRate"`
+	// DailyCap — максимальная стоимость за календарные сутки парковки; nil — без ограничения
+	DailyCap *int64 `json:"dailyCap,omitempty"`
+	// FreeMinutes — сколько первых минут брони не оплачиваются
+	FreeMinutes int          `json:"freeMinutes"`
+	Active      bool         `json:"active"`
+	Rates       []TariffRate `json:"rates"`
+	CreatedAt   time.Time    `json:"createdAt"`
+}
+
+// Quote — расчет стоимости брони
+type Quote struct {
+	// Amount — стоимость к оплате в копейках с учетом скидки группы и промокода
+	Amount          int64  `json:"amount"`
+	BaseAmount      int64  `json:"baseAmount"`
+	DiscountPercent int    `json:"discountPercent"`
+	Currency        string `json:"currency"`
+	TariffID        int    `json:"tariffId,omitempty"`
+	TariffName      string `json:"tariffName,omitempty"`
+	// PromoDiscount — скидка по промокоду PromoCode, считается после скидки группы
+	PromoCode     string `json:"promoCode,omitempty"`
+	PromoDiscount int64  `json:"promoDiscount,omitempty"`
+}
+
+// ClockMinutes переводит время суток 15:04 в минуты от полуночи; допускает 24:00 как конец суток
+func ClockMinutes(clock string) (int, error) {
+	if clock == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse(ClockLayout, clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// rateWindow — разобранная особая ставка
+type rateWindow struct {
+	weekdays   map[time.Weekday]bool
+	start, end int
+	hourlyRate int64
+}
+
+func (t Tariff) rateWindows() []rateWindow {
+	windows := make([]rateWindow, 0, len(t.Rates))
+	for _, rate := range t.Rates {
+		start, err1 := ClockMinutes(rate.StartsAt)
+		end, err2 := ClockMinutes(rate.EndsAt)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		window := rateWindow{weekdays: map[time.Weekday]bool{}, start: start, end: end, hourlyRate: rate.HourlyRate}
+		for _, day := range rate.Weekdays {
+			window.weekdays[time.Weekday(day%7)] = true
+		}
+		windows = append(windows, window)
+	}
+	return windows
+}
+
+// rateAt возвращает почасовую ставку в момент local; из пересекающихся окон действует первое
+func (t Tariff) rateAt(windows []rateWindow, local time.Time) int64 {
+	minute := local.Hour()*60 + local.Minute()
+	for _, window := range windows {
+		if window.weekdays[local.Weekday()] && minute >= window.start && minute < window.end {
+			return window.hourlyRate
+		}
+	}
+	return t.HourlyRate
+}
+
+// Price считает стоимость интервала [start, end) без скидок. Ставка берется поминутно
+// по местному времени парковки loc, стоимость округляется и ограничивается DailyCap
+// отдельно для каждых календарных суток
+func (t Tariff) Price(start, end time.Time, loc *time.Location) int64 {
+	windows := t.rateWindows()
+	start = start.Add(time.Duration(t.FreeMinutes) * time.Minute)
+
+	var total int64
+	for start.Before(end) {
+		local := start.In(loc)
+		dayEnd := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+		if end.Before(dayEnd) {
+			dayEnd = end
+		}
+
+		var rateMinutes int64
+		for minute := start; minute.Before(dayEnd); minute = minute.Add(time.Minute) {
+			rateMinutes += t.rateAt(windows, minute.In(loc))
+		}
+		charge := (rateMinutes + 30) / 60
+		if t.DailyCap != nil && charge > *t.DailyCap {
+			charge = *t.DailyCap
+		}
+
+		total += charge
+		start = dayEnd
+	}
+	return total
+}
+
+// ApplyDiscount уменьшает сумму на percent процентов с округлением до копейки
+func ApplyDiscount(amount int64, percent int) int64 {
+	return (amount*int64(100-percent) + 50) / 100
+}
+
+const tariffColumns = "id, name, lot_id, spot_feature, hourly_rate, daily_cap, free_minutes, active, created_at"
+
+func scanTariffRow(row rowScanner, tariff *Tariff) error {
+	return row.Scan(&tariff.ID, &tariff.Name, &tariff.LotID, &tariff.SpotFeature, &tariff.HourlyRate,
+		&tariff.DailyCap, &tariff.FreeMinutes, &tariff.Active, &tariff.CreatedAt)
+}
+
+// listTariffRates возвращает особые ставки тарифа tariffID (0 — всех тарифов), сгруппированные по тарифу
+func listTariffRates(db Queryer, tariffID int) (map[int][]TariffRate, error) {
+	rows, err := db.Query(`
+		SELECT id, tariff_id, weekdays, to_char(starts_at, 'HH24:MI'), to_char(ends_at, 'HH24:MI'), hourly_rate
+		FROM tariff_rates
+		WHERE $1 = 0 OR tariff_id = $1
+		ORDER BY tariff_id, id
+	`, tariffID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := map[int][]TariffRate{}
+	for rows.Next() {
+		var rate TariffRate
+		var owner int
+		var weekdays []int64
+		if err := rows.Scan(&rate.ID, &owner, pq.Array(&weekdays), &rate.StartsAt, &rate.EndsAt, &rate.HourlyRate); err != nil {
+			return nil, err
+		}
+		rate.Weekdays = make([]int, len(weekdays))
+		for i, day := range weekdays {
+			rate.Weekdays[i] = int(day)
+		}
+		rates[owner] = append(rates[owner], rate)
+	}
+
+	return rates, rows.Err()
+}
+
+// loadTariff читает тариф из строки вместе с особыми ставками
+func loadTariff(db Queryer, row *sql.Row) (*Tariff, error) {
+	var tariff Tariff
+	err := scanTariffRow(row, &tariff)
+	if err == sql.ErrNoRows {
+		return nil, ErrTariffNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rates, err := listTariffRates(db, tariff.ID)
+	if err != nil {
+		return nil, err
+	}
+	tariff.Rates = rates[tariff.ID]
+	if tariff.Rates == nil {
+		tariff.Rates = []TariffRate{}
+	}
+	return &tariff, nil
+}
+
+// ListTariffs возвращает все тарифы с особыми ставками
+func ListTariffs(db Queryer) ([]Tariff, error) {
+	rows, err := db.Query(`SELECT ` + tariffColumns + ` FROM tariffs ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tariffs := []Tariff{}
+	for rows.Next() {
+		var tariff Tariff
+		if err := scanTariffRow(rows, &tariff); err != nil {
+			return nil, err
+		}
+		tariffs = append(tariffs, tariff)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rates, err := listTariffRates(db, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tariffs {
+		tariffs[i].Rates = rates[tariffs[i].ID]
+		if tariffs[i].Rates == nil {
+			tariffs[i].Rates = []TariffRate{}
+		}
+	}
+	return tariffs, nil
+}
+
+// GetTariff возвращает тариф или ErrTariffNotFound
+func GetTariff(db Queryer, tariffID int) (*Tariff, error) {
+	return loadTariff(db, db.QueryRow(`SELECT `+tariffColumns+` FROM tariffs WHERE id = $1`, tariffID))
+}
+
+// FindTariff выбирает действующий тариф для места: сначала тариф парковки, затем тариф
+// характеристики места, при равенстве — созданный последним. ErrTariffNotFound, если подходящих нет
+func FindTariff(db Queryer, lotID int, features []string) (*Tariff, error) {
+	return loadTariff(db, db.QueryRow(`
+		SELECT `+tariffColumns+`
+		FROM tariffs
+		WHERE active
+		AND (lot_id IS NULL OR lot_id = $1)
+		AND (spot_feature IS NULL OR spot_feature = ANY($2))
+		ORDER BY (lot_id IS NOT NULL) DESC, (spot_feature IS NOT NULL) DESC, id DESC
+		LIMIT 1
+	`, lotID, pq.Array(features)))
+}
+
+// replaceTariffRates заменяет особые ставки тарифа
+func replaceTariffRates(db Queryer, tariffID int, rates []TariffRate) error {
+	if _, err := db.Exec(`DELETE FROM tariff_rates WHERE tariff_id = $1`, tariffID); err != nil {
+		return err
+	}
+	for _, rate := range rates {
+		weekdays := make([]int64, len(rate.Weekdays))
+		for i, day := range rate.Weekdays {
+			weekdays[i] = int64(day)
+		}
+		_, err := db.Exec(`
+			INSERT INTO tariff_rates (tariff_id, weekdays, starts_at, ends_at, hourly_rate)
+			VALUES ($1, $2, $3, $4, $5)
+		`, tariffID, pq.Array(weekdays), rate.StartsAt, rate.EndsAt, rate.HourlyRate)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateTariff сохраняет тариф вместе с особыми ставками
+func CreateTariff(tx *sql.Tx, tariff *Tariff) (int, error) {
+	var id int
+	err := tx.QueryRow(`
+		INSERT INTO tariffs (name, lot_id, spot_feature, hourly_rate, daily_cap, free_minutes, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, tariff.Name, tariff.LotID, tariff.SpotFeature, tariff.HourlyRate, tariff.DailyCap,
+		tariff.FreeMinutes, tariff.Active).Scan(&id)
+	if err != nil {
+		return 0, translateUniqueError(err, ErrTariffExists)
+	}
+	return id, replaceTariffRates(tx, id, tariff.Rates)
+}
+
+// UpdateTariff заменяет тариф и его особые ставки; уже созданные брони сохраняют свою стоимость
+func UpdateTariff(tx *sql.Tx, tariff *Tariff) error {
+	result, err := tx.Exec(`
+		UPDATE tariffs
+		SET name = $2, lot_id = $3, spot_feature = $4, hourly_rate = $5, daily_cap = $6,
+			free_minutes = $7, active = $8
+		WHERE id = $1
+	`, tariff.ID, tariff.Name, tariff.LotID, tariff.SpotFeature, tariff.HourlyRate, tariff.DailyCap,
+		tariff.FreeMinutes, tariff.Active)
+	if err != nil {
+		return translateUniqueError(err, ErrTariffExists)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrTariffNotFound
+	}
+	return replaceTariffRates(tx, tariff.ID, tariff.Rates)
+}
+
+// DeleteTariff удаляет тариф
+func DeleteTariff(db Queryer, tariffID int) error {
+	result, err := db.Exec(`DELETE FROM tariffs WHERE id = $1`, tariffID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrTariffNotFound
+	}
+	return nil
+}
+
+// QuoteBooking считает стоимость брони места spotNumber пользователем userID по тарифу места,
+// скидке группы пользователя и промокоду promo (nil — без промокода). def — часовой пояс для парковок без своего
+func QuoteBooking(db Queryer, userID, spotNumber int, start, end time.Time, def *time.Location, currency string, promo *PromoCode) (*Quote, error) {
+	spot, err := GetSpot(db, spotNumber)
+	if err != nil {
+		return nil, err
+	}
+	return QuoteLot(db, userID, spot.LotID, spot.Features, start, end, def, currency, promo)
+}
+
+// QuoteLot считает стоимость брони места с характеристиками features на парковке lotID.
+// Если подходящего тарифа нет, парковка бесплатна. Промокод, который не относится к месту,
+// возвращается как *PolicyViolation; лимиты промокода здесь не проверяются
+func QuoteLot(db Queryer, userID, lotID int, features []string, start, end time.Time, def *time.Location, currency string, promo *PromoCode) (*Quote, error) {
+	if promo != nil && !promo.AppliesTo(lotID, features) {
+		return nil, promo.notApplicable()
+	}
+	lot, err := GetLot(db, lotID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := lot.Location(def)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &Quote{Currency: currency}
+	if promo != nil {
+		quote.PromoCode = promo.Code
+	}
+	tariff, err := FindTariff(db, lotID, features)
+	if errors.Is(err, ErrTariffNotFound) {
+		return quote, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	quote.TariffID = tariff.ID
+	quote.TariffName = tariff.Name
+	quote.BaseAmount = tariff.Price(start, end, loc)
+
+	quote.DiscountPercent, err = GetUserDiscount(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	quote.Amount = ApplyDiscount(quote.BaseAmount, quote.DiscountPercent)
+	if promo != nil {
+		ApplyPromoCode(quote, promo)
+	}
+	return quote, nil
+}
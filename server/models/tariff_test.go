@@ -0,0 +1,95 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTariffPrice(t *testing.T) {
+	moscow := mustLoadLocation(t, "Europe/Moscow")
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	// 2 марта 2026 — понедельник
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, moscow)
+	}
+	dailyCap := func(amount int64) *int64 { return &amount }
+
+	base := Tariff{HourlyRate: 10000}
+	peak := Tariff{HourlyRate: 10000, Rates: []TariffRate{
+		{Weekdays: []int{1, 2, 3, 4, 5}, StartsAt: "08:00", EndsAt: "10:00", HourlyRate: 20000},
+		{Weekdays: []int{1, 2, 3, 4, 5, 6, 7}, StartsAt: "22:00", EndsAt: "24:00", HourlyRate: 5000},
+	}}
+	overlapping := Tariff{HourlyRate: 10000, Rates: []TariffRate{
+		{Weekdays: []int{1}, StartsAt: "08:00", EndsAt: "10:00", HourlyRate: 20000},
+		{Weekdays: []int{1}, StartsAt: "09:00", EndsAt: "12:00", HourlyRate: 30000},
+	}}
+
+	tests := []struct {
+		name       string
+		tariff     Tariff
+		start, end time.Time
+		loc        *time.Location
+		want       int64
+	}{
+		{name: "one hour", tariff: base, start: at(2, 10, 0), end: at(2, 11, 0), want: 10000},
+		{name: "ten minutes round up", tariff: base, start: at(2, 10, 0), end: at(2, 10, 10), want: 1667},
+		{name: "one minute", tariff: base, start: at(2, 10, 0), end: at(2, 10, 1), want: 167},
+		{name: "empty interval", tariff: base, start: at(2, 10, 0), end: at(2, 10, 0), want: 0},
+		{name: "half in the peak window", tariff: peak, start: at(2, 9, 30), end: at(2, 10, 30), want: 15000},
+		{name: "minute before and after the window start", tariff: peak, start: at(2, 7, 59), end: at(2, 8, 1), want: 500},
+		{name: "window end is exclusive", tariff: peak, start: at(2, 9, 59), end: at(2, 10, 1), want: 500},
+		{name: "first of overlapping windows wins", tariff: overlapping, start: at(2, 9, 0), end: at(2, 11, 0), want: 50000},
+		{name: "weekday window does not apply on saturday", tariff: peak, start: at(7, 8, 0), end: at(7, 9, 0), want: 10000},
+		{name: "window until midnight", tariff: peak, start: at(2, 23, 0), end: at(3, 1, 0), want: 15000},
+		{name: "daily cap", tariff: Tariff{HourlyRate: 10000, DailyCap: dailyCap(50000)}, start: at(2, 10, 0), end: at(2, 18, 0), want: 50000},
+		{
+			name:   "daily cap applies per calendar day",
+			tariff: Tariff{HourlyRate: 10000, DailyCap: dailyCap(50000)},
+			start:  at(2, 20, 0), end: at(3, 20, 0),
+			want: 40000 + 50000,
+		},
+		{name: "free minutes", tariff: Tariff{HourlyRate: 10000, FreeMinutes: 15}, start: at(2, 10, 0), end: at(2, 11, 0), want: 7500},
+		{name: "free minutes cover the booking", tariff: Tariff{HourlyRate: 10000, FreeMinutes: 90}, start: at(2, 10, 0), end: at(2, 11, 0), want: 0},
+		{
+			name:   "day with the DST switch has 23 hours",
+			tariff: base,
+			start:  time.Date(2026, 3, 29, 0, 0, 0, 0, berlin),
+			end:    time.Date(2026, 3, 30, 0, 0, 0, 0, berlin),
+			loc:    berlin,
+			want:   230000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := tt.loc
+			if loc == nil {
+				loc = moscow
+			}
+			if got := tt.tariff.Price(tt.start, tt.end, loc); got != tt.want {
+				t.Fatalf("Price = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyDiscount(t *testing.T) {
+	tests := []struct {
+		amount  int64
+		percent int
+		want    int64
+	}{
+		{amount: 1000, percent: 10, want: 900},
+		{amount: 999, percent: 15, want: 849},
+		{amount: 1, percent: 50, want: 1},
+		{amount: 3, percent: 50, want: 2},
+		{amount: 12345, percent: 0, want: 12345},
+		{amount: 12345, percent: 100, want: 0},
+		{amount: 0, percent: 30, want: 0},
+	}
+	for _, tt := range tests {
+		if got := ApplyDiscount(tt.amount, tt.percent); got != tt.want {
+			t.Errorf("ApplyDiscount(%d, %d) = %d, want %d", tt.amount, tt.percent, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// Виды отрезков расписания места
+const (
+	TimelineBooking = "booking"
+	TimelineBlocked = "blocked"
+	TimelineFree    = "free"
+)
+
+// TimelineEntry — отрезок расписания места: бронь, блокировка или свободный промежуток
+type TimelineEntry struct {
+	Kind  string    `json:"kind"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Поля брони; владелец скрывается от посторонних пользователей
+	BookingID *int          `json:"bookingId,omitempty"`
+	Status    BookingStatus `json:"status,omitempty"`
+	UserID    *int          `json:"userId,omitempty"`
+	CarNumber string        `json:"carNumber,omitempty"`
+}
+
+// GetSpotTimeline возвращает расписание места в окне [from, to), отсортированное по началу.
+// Завершенные брони показываются по фактическому интервалу, отмененные и неявки не показываются
+func GetSpotTimeline(db Queryer, spotNumber int, from, to time.Time) ([]TimelineEntry, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, car_number, status, GREATEST(lower(period), $2), LEAST(upper(period), $3)
+		FROM bookings
+		WHERE parking_spot = $1
+		AND period && tstzrange($2, $3, '[)')
+		AND (`+OccupyingStatusCondition+` OR status = 'completed')
+		ORDER BY lower(period), id
+	`, spotNumber, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	timeline := []TimelineEntry{}
+	busy := []Interval{}
+	for rows.Next() {
+		var bookingID, userID int
+		entry := TimelineEntry{Kind: TimelineBooking}
+		if err := rows.Scan(&bookingID, &userID, &entry.CarNumber, &entry.Status, &entry.Start, &entry.End); err != nil {
+			return nil, err
+		}
+		entry.BookingID = &bookingID
+		entry.UserID = &userID
+		timeline = append(timeline, entry)
+		busy = append(busy, Interval{Start: entry.Start, End: entry.End})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Блокировка действует с момента blocked_at до снятия
+	var blockedFrom time.Time
+	err = db.QueryRow(`
+		SELECT GREATEST(blocked_at::timestamptz, $2)
+		FROM blocked_spots
+		WHERE spot_number = $1 AND is_blocked = true AND blocked_at::timestamptz < $3
+	`, spotNumber, from, to).Scan(&blockedFrom)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		timeline = append(timeline, TimelineEntry{Kind: TimelineBlocked, Start: blockedFrom, End: to})
+		busy = append(busy, Interval{Start: blockedFrom, End: to})
+	}
+
+	sort.SliceStable(busy, func(i, j int) bool { return busy[i].Start.Before(busy[j].Start) })
+	for _, gap := range FreeIntervals(busy, from, to) {
+		timeline = append(timeline, TimelineEntry{Kind: TimelineFree, Start: gap.Start, End: gap.End})
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].Start.Before(timeline[j].Start) })
+	return timeline, nil
+}
@@ -2,8 +2,11 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 )
 
+var ErrUserNotFound = errors.New("user not found")
+
 type User struct {
 	ID           int    `json:"id"`
 	Email        string `json:"email"`
@@ -41,3 +44,25 @@ func FindUserByEmail(db *sql.DB, email string) (*User, error) {
 	}
 	return &user, nil
 }
+
+// HasAccessibilityPermit проверяет, выдано ли пользователю разрешение на места для людей с инвалидностью
+func HasAccessibilityPermit(db Queryer, userID int) (bool, error) {
+	var permit bool
+	err := db.QueryRow(`SELECT accessibility_permit FROM users WHERE id = $1`, userID).Scan(&permit)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return permit, err
+}
+
+// SetAccessibilityPermit выдает или отзывает разрешение на места для людей с инвалидностью
+func SetAccessibilityPermit(db Queryer, userID int, permit bool) error {
+	result, err := db.Exec(`UPDATE users SET accessibility_permit = $2 WHERE id = $1`, userID, permit)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
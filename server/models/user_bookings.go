@@ -0,0 +1,138 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Фазы брони относительно текущего времени
+const (
+	PhaseCurrent  = "current"
+	PhaseUpcoming = "upcoming"
+	PhasePast     = "past"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// BookingCursor — позиция в списке броней, отсортированном по (reserved_at, id) по убыванию
+type BookingCursor struct {
+	ReservedAt time.Time
+	ID         int
+}
+
+// Encode возвращает непрозрачное строковое представление курсора
+func (c BookingCursor) Encode() string {
+	raw := c.ReservedAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeBookingCursor разбирает курсор, полученный от клиента
+func DecodeBookingCursor(value string) (*BookingCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	reservedAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &BookingCursor{ReservedAt: reservedAt, ID: id}, nil
+}
+
+// UserBookingFilter описывает выборку броней одного пользователя
+type UserBookingFilter struct {
+	UserID   int
+	Statuses []BookingStatus
+	// From/To ограничивают выборку бронями, пересекающими интервал [From, To)
+	From  *time.Time
+	To    *time.Time
+	Phase string
+	After *BookingCursor
+	Limit int
+}
+
+// ListUserBookings возвращает брони пользователя, новые первыми
+func ListUserBookings(db Queryer, filter UserBookingFilter) ([]Booking, error) {
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{filter.UserID}
+	addArg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		conditions = append(conditions, "status = ANY("+addArg(pq.Array(statuses))+")")
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "upper(period) > "+addArg(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "lower(period) < "+addArg(*filter.To))
+	}
+	switch filter.Phase {
+	case PhaseCurrent:
+		conditions = append(conditions, "period @> NOW()")
+	case PhaseUpcoming:
+		conditions = append(conditions, "lower(period) > NOW()")
+	case PhasePast:
+		conditions = append(conditions, "upper(period) <= NOW()")
+	}
+	if filter.After != nil {
+		conditions = append(conditions, fmt.Sprintf("(reserved_at, id) < (%s, %s)",
+			addArg(filter.After.ReservedAt), addArg(filter.After.ID)))
+	}
+
+	query := `
+		SELECT ` + bookingColumns + `
+		FROM bookings
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY reserved_at DESC, id DESC
+		LIMIT ` + addArg(filter.Limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookings := []Booking{}
+	for rows.Next() {
+		var booking Booking
+		if err := scanBookingRow(rows, &booking); err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, booking)
+	}
+
+	return bookings, rows.Err()
+}
+
+// Phase возвращает фазу брони относительно момента now
+func (b Booking) Phase(now time.Time) string {
+	switch {
+	case now.Before(b.ReservedAt):
+		return PhaseUpcoming
+	case now.Before(b.EndsAt):
+		return PhaseCurrent
+	default:
+		return PhasePast
+	}
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Код ошибки PostgreSQL foreign_key_violation
+const foreignKeyViolation = "23503"
+
+var ErrUserGroupNotFound = errors.New("user group not found")
+
+// UserGroup — группа пользователей со своей скидкой на бронирование
+type UserGroup struct {
+	Name            string `json:"name"`
+	DiscountPercent int    `json:"discountPercent"`
+	// MonthlyAllowance — сумма в копейках, которая каждый месяц зачисляется на баланс участникам
+	MonthlyAllowance int64     `json:"monthlyAllowance"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+// ListUserGroups возвращает группы пользователей по имени
+func ListUserGroups(db Queryer) ([]UserGroup, error) {
+	rows, err := db.Query(`SELECT name, discount_percent, monthly_allowance, created_at FROM user_groups ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []UserGroup{}
+	for rows.Next() {
+		var group UserGroup
+		if err := rows.Scan(&group.Name, &group.DiscountPercent, &group.MonthlyAllowance, &group.CreatedAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
+}
+
+// SaveUserGroup создает группу или меняет ее скидку и ежемесячную сумму
+func SaveUserGroup(db Queryer, group *UserGroup) error {
+	return db.QueryRow(`
+		INSERT INTO user_groups (name, discount_percent, monthly_allowance)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE
+		SET discount_percent = EXCLUDED.discount_percent, monthly_allowance = EXCLUDED.monthly_allowance
+		RETURNING created_at
+	`, group.Name, group.DiscountPercent, group.MonthlyAllowance).Scan(&group.CreatedAt)
+}
+
+// DeleteUserGroup удаляет группу; ее пользователи остаются без группы
+func DeleteUserGroup(db Queryer, name string) error {
+	result, err := db.Exec(`DELETE FROM user_groups WHERE name = $1`, name)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrUserGroupNotFound
+	}
+	return nil
+}
+
+// SetUserGroup включает пользователя в группу; nil исключает его из группы
+func SetUserGroup(db Queryer, userID int, group *string) error {
+	result, err := db.Exec(`UPDATE users SET user_group = $2 WHERE id = $1`, userID, group)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		return ErrUserGroupNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// GetUserDiscount возвращает скидку группы пользователя в процентах; 0, если группы нет
+func GetUserDiscount(db Queryer, userID int) (int, error) {
+	var discount int
+	err := db.QueryRow(`
+		SELECT COALESCE(g.discount_percent, 0)
+		FROM users u
+		LEFT JOIN user_groups g ON g.name = u.user_group
+		WHERE u.id = $1
+	`, userID).Scan(&discount)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return discount, err
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Классы размера машины
+const (
+	SizeMotorcycle = "motorcycle"
+	SizeSmall      = "small"
+	SizeStandard   = "standard"
+	SizeLarge      = "large"
+)
+
+// Код ошибки PostgreSQL unique_violation
+const uniqueViolation = "23505"
+
+var (
+	ErrVehicleNotFound = errors.New("vehicle not found")
+	ErrVehicleExists   = errors.New("vehicle with this plate is already registered")
+)
+
+// Vehicle — машина пользователя; номер хранится нормализованным
+type Vehicle struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"userId"`
+	Plate     string    `json:"plate"`
+	Country   string    `json:"country"`
+	Make      string    `json:"make"`
+	Model     string    `json:"model"`
+	Colour    string    `json:"colour"`
+	SizeClass string    `json:"sizeClass"`
+	IsEV      bool      `json:"isEv"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IsValidSizeClass проверяет класс размера машины
+func IsValidSizeClass(size string) bool {
+	switch size {
+	case SizeMotorcycle, SizeSmall, SizeStandard, SizeLarge:
+		return true
+	}
+	return false
+}
+
+const vehicleColumns = "id, user_id, plate, country, make, model, colour, size_class, is_ev, created_at"
+
+func scanVehicleRow(row rowScanner, vehicle *Vehicle) error {
+	return row.Scan(&vehicle.ID, &vehicle.UserID, &vehicle.Plate, &vehicle.Country, &vehicle.Make,
+		&vehicle.Model, &vehicle.Colour, &vehicle.SizeClass, &vehicle.IsEV, &vehicle.CreatedAt)
+}
+
+// translateUniqueError превращает нарушение ограничения уникальности в exists
+func translateUniqueError(err, exists error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return exists
+	}
+	return err
+}
+
+// CreateVehicle регистрирует машину пользователя
+func CreateVehicle(db Queryer, vehicle *Vehicle) (int, error) {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO vehicles (user_id, plate, country, make, model, colour, size_class, is_ev)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, vehicle.UserID, vehicle.Plate, vehicle.Country, vehicle.Make, vehicle.Model,
+		vehicle.Colour, vehicle.SizeClass, vehicle.IsEV).Scan(&id)
+	return id, translateUniqueError(err, ErrVehicleExists)
+}
+
+// UpdateVehicle сохраняет изменения машины пользователя
+func UpdateVehicle(db Queryer, vehicle *Vehicle) error {
+	result, err := db.Exec(`
+		UPDATE vehicles
+		SET plate = $3, country = $4, make = $5, model = $6, colour = $7, size_class = $8, is_ev = $9
+		WHERE id = $1 AND user_id = $2
+	`, vehicle.ID, vehicle.UserID, vehicle.Plate, vehicle.Country, vehicle.Make, vehicle.Model,
+		vehicle.Colour, vehicle.SizeClass, vehicle.IsEV)
+	if err != nil {
+		return translateUniqueError(err, ErrVehicleExists)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrVehicleNotFound
+	}
+	return nil
+}
+
+// DeleteVehicle удаляет машину пользователя; брони сохраняют номер, но теряют ссылку на машину
+func DeleteVehicle(db Queryer, vehicleID, userID int) error {
+	result, err := db.Exec(`DELETE FROM vehicles WHERE id = $1 AND user_id = $2`, vehicleID, userID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrVehicleNotFound
+	}
+	return nil
+}
+
+// GetUserVehicle возвращает машину пользователя или ErrVehicleNotFound
+func GetUserVehicle(db Queryer, vehicleID, userID int) (*Vehicle, error) {
+	var vehicle Vehicle
+	err := scanVehicleRow(db.QueryRow(`
+		SELECT `+vehicleColumns+`
+		FROM vehicles
+		WHERE id = $1 AND user_id = $2
+	`, vehicleID, userID), &vehicle)
+	if err == sql.ErrNoRows {
+		return nil, ErrVehicleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &vehicle, nil
+}
+
+// ListUserVehicles возвращает машины пользователя в порядке регистрации
+func ListUserVehicles(db Queryer, userID int) ([]Vehicle, error) {
+	rows, err := db.Query(`
+		SELECT `+vehicleColumns+`
+		FROM vehicles
+		WHERE user_id = $1
+		ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vehicles := []Vehicle{}
+	for rows.Next() {
+		var vehicle Vehicle
+		if err := scanVehicleRow(rows, &vehicle); err != nil {
+			return nil, err
+		}
+		vehicles = append(vehicles, vehicle)
+	}
+
+	return vehicles, rows.Err()
+}
@@ -0,0 +1,364 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Статусы записи в листе ожидания
+const (
+	WaitlistWaiting   = "waiting"
+	WaitlistOffered   = "offered"
+	WaitlistAccepted  = "accepted"
+	WaitlistExpired   = "expired"
+	WaitlistCancelled = "cancelled"
+)
+
+// Сколько записей обрабатывается за один проход FillWaitlist
+const waitlistBatchSize = 100
+
+var ErrWaitlistEntryNotFound = errors.New("waitlist entry not found")
+
+// WaitlistEntry — запрос пользователя на место, когда подходящих свободных мест нет
+type WaitlistEntry struct {
+	ID     int `json:"id"`
+	UserID int `json:"userId"`
+	// ParkingSpot равен nil, если пользователю подходит любое место
+	ParkingSpot *int `json:"parkingSpot"`
+	// LotID ограничивает парковку для записи на любое место; nil — любая парковка
+	LotID     *int      `json:"lotId,omitempty"`
+	CarNumber string    `json:"carNumber"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	Status    string    `json:"status"`
+	// BookingID — удерживаемая бронь в статусе pending, пока предложение не принято
+	BookingID      *int       `json:"bookingId,omitempty"`
+	OfferedAt      *time.Time `json:"offeredAt,omitempty"`
+	OfferExpiresAt *time.Time `json:"offerExpiresAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// offerWindow возвращает интервал, который можно предложить в момент now:
+// если запрошенное время уже началось, предлагается только оставшаяся часть
+func (e WaitlistEntry) offerWindow(now time.Time) (time.Time, time.Time) {
+	if !now.After(e.StartsAt) {
+		return e.StartsAt, e.EndsAt
+	}
+	return now.Truncate(time.Minute), e.EndsAt
+}
+
+const waitlistColumns = `id, user_id, parking_spot, lot_id, car_number, starts_at, ends_at, status,
+	booking_id, offered_at, offer_expires_at, created_at`
+
+func scanWaitlistRow(row rowScanner, entry *WaitlistEntry) error {
+	return row.Scan(&entry.ID, &entry.UserID, &entry.ParkingSpot, &entry.LotID, &entry.CarNumber, &entry.StartsAt,
+		&entry.EndsAt, &entry.Status, &entry.BookingID, &entry.OfferedAt, &entry.OfferExpiresAt, &entry.CreatedAt)
+}
+
+func queryWaitlist(db Queryer, query string, args ...interface{}) ([]WaitlistEntry, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []WaitlistEntry{}
+	for rows.Next() {
+		var entry WaitlistEntry
+		if err := scanWaitlistRow(rows, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// CreateWaitlistEntry ставит пользователя в конец очереди
+func CreateWaitlistEntry(db Queryer, entry *WaitlistEntry) (int, error) {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO waitlist_entries (user_id, parking_spot, lot_id, car_number, starts_at, ends_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, entry.UserID, entry.ParkingSpot, entry.LotID, entry.CarNumber, entry.StartsAt, entry.EndsAt).Scan(&id)
+	return id, err
+}
+
+// GetUserWaitlistEntry возвращает запись пользователя или ErrWaitlistEntryNotFound
+func GetUserWaitlistEntry(db Queryer, entryID, userID int) (*WaitlistEntry, error) {
+	var entry WaitlistEntry
+	err := scanWaitlistRow(db.QueryRow(`
+		SELECT `+waitlistColumns+`
+		FROM waitlist_entries
+		WHERE id = $1 AND user_id = $2
+	`, entryID, userID), &entry)
+	if err == sql.ErrNoRows {
+		return nil, ErrWaitlistEntryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// LockUserWaitlistEntry возвращает запись пользователя и блокирует ее до конца транзакции
+func LockUserWaitlistEntry(tx *sql.Tx, entryID, userID int) (*WaitlistEntry, error) {
+	var entry WaitlistEntry
+	err := scanWaitlistRow(tx.QueryRow(`
+		SELECT `+waitlistColumns+`
+		FROM waitlist_entries
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE
+	`, entryID, userID), &entry)
+	if err == sql.ErrNoRows {
+		return nil, ErrWaitlistEntryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// ListUserWaitlist возвращает записи пользователя в листе ожидания, новые первыми
+func ListUserWaitlist(db Queryer, userID int) ([]WaitlistEntry, error) {
+	return queryWaitlist(db, `
+		SELECT `+waitlistColumns+`
+		FROM waitlist_entries
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+}
+
+// SetWaitlistStatus меняет статус записи
+func SetWaitlistStatus(db Queryer, entryID int, status string) error {
+	_, err := db.Exec(`UPDATE waitlist_entries SET status = $2 WHERE id = $1`, entryID, status)
+	return err
+}
+
+// FillWaitlist предлагает свободные места ожидающим пользователям в порядке очереди.
+// Предложение удерживает место бронью в статусе pending до offerTTL.
+// spots ограничивает проверяемые места; nil — все места парковки. Места закрытых в это время
+// парковок не предлагаются; loc — часовой пояс парковок без собственного
+func FillWaitlist(db *sql.DB, spots []int, offerTTL time.Duration, loc *time.Location) ([]WaitlistEntry, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if spots == nil {
+		if spots, err = ActiveSpotNumbers(tx, 0, nil); err != nil {
+			return nil, err
+		}
+	}
+	spotLots, err := activeSpotLots(tx)
+	if err != nil {
+		return nil, err
+	}
+	lots, err := ListLots(tx)
+	if err != nil {
+		return nil, err
+	}
+	lotByID := map[int]Lot{}
+	for _, lot := range lots {
+		lotByID[lot.ID] = lot
+	}
+
+	entries, err := queryWaitlist(tx, `
+		SELECT `+waitlistColumns+`
+		FROM waitlist_entries
+		WHERE status = 'waiting'
+		AND ends_at > NOW()
+		ORDER BY created_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, waitlistBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	offered := []WaitlistEntry{}
+	for _, entry := range entries {
+		start, end := entry.offerWindow(now)
+		if !end.After(start) {
+			continue
+		}
+
+		for _, spot := range spots {
+			lotID, active := spotLots[spot]
+			if !active {
+				continue
+			}
+			if entry.ParkingSpot != nil && *entry.ParkingSpot != spot {
+				continue
+			}
+			if entry.LotID != nil && *entry.LotID != lotID {
+				continue
+			}
+			err := lotByID[lotID].CheckOpen(start, end, loc)
+			if errors.Is(err, ErrLotClosed) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			// Место, которое пользователь все равно не сможет принять, не удерживается
+			err = CheckSpotEligibility(tx, Booking{UserID: entry.UserID, ParkingSpot: spot, CarNumber: entry.CarNumber})
+			var violation *PolicyViolation
+			if errors.As(err, &violation) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			bookingID, err := holdSpot(tx, entry, spot, start, end)
+			if err != nil {
+				return nil, err
+			}
+			if bookingID == 0 {
+				continue
+			}
+
+			expiresAt := now.Add(offerTTL)
+			_, err = tx.Exec(`
+				UPDATE waitlist_entries
+				SET status = 'offered', booking_id = $2, offered_at = $3, offer_expires_at = $4
+				WHERE id = $1
+			`, entry.ID, bookingID, now, expiresAt)
+			if err != nil {
+				return nil, err
+			}
+
+			entry.Status = WaitlistOffered
+			entry.BookingID = &bookingID
+			entry.OfferedAt = &now
+			entry.OfferExpiresAt = &expiresAt
+			offered = append(offered, entry)
+			break
+		}
+	}
+
+	return offered, tx.Commit()
+}
+
+// activeSpotLots возвращает парковку каждого действующего места
+func activeSpotLots(db Queryer) (map[int]int, error) {
+	rows, err := db.Query(`SELECT number, lot_id FROM spots WHERE retired_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots := map[int]int{}
+	for rows.Next() {
+		var spot, lotID int
+		if err := rows.Scan(&spot, &lotID); err != nil {
+			return nil, err
+		}
+		lots[spot] = lotID
+	}
+
+	return lots, rows.Err()
+}
+
+// holdSpot создает удерживающую бронь в статусе pending; возвращает 0, если место занято
+func holdSpot(tx *sql.Tx, entry WaitlistEntry, spot int, start, end time.Time) (int, error) {
+	available, err := IsSpotAvailable(tx, spot, start, end)
+	if err != nil || !available {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("SAVEPOINT waitlist_hold"); err != nil {
+		return 0, err
+	}
+	bookingID, err := CreateBooking(tx, &Booking{
+		UserID:        entry.UserID,
+		ParkingSpot:   spot,
+		CarNumber:     entry.CarNumber,
+		ReservedAt:    start,
+		PlannedEndsAt: end,
+		Status:        StatusPending,
+	})
+	if errors.Is(err, ErrBookingConflict) {
+		_, err = tx.Exec("ROLLBACK TO SAVEPOINT waitlist_hold")
+		return 0, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("RELEASE SAVEPOINT waitlist_hold")
+	return bookingID, err
+}
+
+// ExpireWaitlist снимает просроченные предложения, освобождая удерживаемые места,
+// и закрывает записи, чье время уже прошло. Возвращает освободившиеся места
+func ExpireWaitlist(db *sql.DB) ([]int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	entries, err := queryWaitlist(tx, `
+		SELECT `+waitlistColumns+`
+		FROM waitlist_entries
+		WHERE status = 'offered' AND offer_expires_at <= NOW()
+		FOR UPDATE SKIP LOCKED
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	spots := []int{}
+	for _, entry := range entries {
+		spot, err := ReleaseWaitlistHold(tx, entry, "Waitlist offer expired")
+		if err != nil {
+			return nil, err
+		}
+		if spot != 0 {
+			spots = append(spots, spot)
+		}
+		if err := SetWaitlistStatus(tx, entry.ID, WaitlistExpired); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE waitlist_entries
+		SET status = 'expired'
+		WHERE status = 'waiting'
+		AND ends_at <= NOW()
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return spots, tx.Commit()
+}
+
+// ReleaseWaitlistHold отменяет удерживающую бронь предложения и возвращает освобожденное место (0, если брони нет)
+func ReleaseWaitlistHold(tx *sql.Tx, entry WaitlistEntry, reason string) (int, error) {
+	if entry.BookingID == nil {
+		return 0, nil
+	}
+	booking, err := LockBooking(tx, *entry.BookingID)
+	if errors.Is(err, ErrBookingNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	err = CancelBooking(tx, booking.ID, 0, reason)
+	if errors.Is(err, ErrInvalidTransition) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return booking.ParkingSpot, nil
+}
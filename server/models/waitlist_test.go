@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"server/testutil"
+)
+
+func TestFillWaitlistSkipsClosedLots(t *testing.T) {
+	db := testutil.OpenDB(t)
+	moscow := mustLoadLocation(t, "Europe/Moscow")
+	userID := testutil.CreateUser(t, db, "waitlist@example.com")
+
+	tomorrow := time.Now().In(moscow).AddDate(0, 0, 1)
+	start := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 21, 0, 0, 0, moscow)
+	spot := 1
+	_, err := CreateWaitlistEntry(db, &WaitlistEntry{
+		UserID: userID, ParkingSpot: &spot, CarNumber: "A123BC77",
+		StartsAt: start, EndsAt: start.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("create waitlist entry: %v", err)
+	}
+
+	if _, err := db.Exec(`UPDATE lots SET opens_at = '08:00', closes_at = '20:00'`); err != nil {
+		t.Fatalf("set opening hours: %v", err)
+	}
+	offered, err := FillWaitlist(db, []int{spot}, 15*time.Minute, moscow)
+	if err != nil {
+		t.Fatalf("FillWaitlist: %v", err)
+	}
+	if len(offered) != 0 {
+		t.Fatalf("offered %d spots outside opening hours", len(offered))
+	}
+
+	if _, err := db.Exec(`UPDATE lots SET closes_at = '23:00'`); err != nil {
+		t.Fatalf("set opening hours: %v", err)
+	}
+	offered, err = FillWaitlist(db, []int{spot}, 15*time.Minute, moscow)
+	if err != nil {
+		t.Fatalf("FillWaitlist: %v", err)
+	}
+	if len(offered) != 1 {
+		t.Fatalf("offered %d spots, want 1", len(offered))
+	}
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PolicyInsufficientBalance — стоимость брони превышает баланс пользователя
+const PolicyInsufficientBalance = "insufficient_balance"
+
+// Allowance — ежемесячное зачисление на баланс участнику группы
+type Allowance struct {
+	UserID int   `json:"userId"`
+	Amount int64 `json:"amount"`
+}
+
+// LockWallet блокирует баланс пользователя до конца транзакции, чтобы параллельные
+// брони не потратили одни и те же средства
+func LockWallet(tx *sql.Tx, userID int) error {
+	var id int
+	err := tx.QueryRow(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// CheckBalance возвращает *PolicyViolation, если amount превышает баланс пользователя
+func CheckBalance(db Queryer, userID int, amount int64, currency string) error {
+	balance, err := UserBalance(db, userID, currency)
+	if err != nil {
+		return err
+	}
+	if amount > balance {
+		return &PolicyViolation{
+			Code:    PolicyInsufficientBalance,
+			Message: fmt.Sprintf("Booking costs %d but only %d is left on the balance", amount, balance),
+		}
+	}
+	return nil
+}
+
+// PostGrant зачисляет на баланс пользователя средства организации
+func PostGrant(tx *sql.Tx, userID int, amount int64, currency, description string, createdBy *int) (*LedgerTransaction, error) {
+	transaction := &LedgerTransaction{
+		Kind:        LedgerGrant,
+		UserID:      userID,
+		Currency:    currency,
+		Description: description,
+		CreatedBy:   createdBy,
+		Entries: []LedgerEntry{
+			{Account: AccountGrants, Amount: amount},
+			userEntry(userID, -amount),
+		},
+	}
+	if err := PostTransaction(tx, transaction); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// GroupMemberIDs возвращает ID пользователей группы или ErrUserGroupNotFound
+func GroupMemberIDs(db Queryer, group string) ([]int, error) {
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM user_groups WHERE name = $1)`, group).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrUserGroupNotFound
+	}
+
+	rows, err := db.Query(`SELECT id FROM users WHERE user_group = $1 ORDER BY id`, group)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetUserAllowance возвращает ежемесячную сумму группы пользователя; 0, если группы нет
+func GetUserAllowance(db Queryer, userID int) (int64, error) {
+	var allowance int64
+	err := db.QueryRow(`
+		SELECT COALESCE(g.monthly_allowance, 0)
+		FROM users u
+		LEFT JOIN user_groups g ON g.name = u.user_group
+		WHERE u.id = $1
+	`, userID).Scan(&allowance)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return allowance, err
+}
+
+// GrantAllowances зачисляет ежемесячную сумму группы всем ее участникам, которые еще
+// не получили ее за месяц month (первое число месяца). Неизрасходованные средства
+// прошлых месяцев остаются на балансе
+func GrantAllowances(db *sql.DB, month time.Time, currency string) ([]Allowance, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		INSERT INTO wallet_allowances (user_id, month, amount)
+		SELECT u.id, $1, g.monthly_allowance
+		FROM users u
+		JOIN user_groups g ON g.name = u.user_group
+		WHERE g.monthly_allowance > 0
+		ON CONFLICT (user_id, month) DO NOTHING
+		RETURNING user_id, amount
+	`, month.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	granted := []Allowance{}
+	for rows.Next() {
+		var allowance Allowance
+		if err := rows.Scan(&allowance.UserID, &allowance.Amount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		granted = append(granted, allowance)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	description := "Monthly allowance for " + month.Format("January 2006")
+	for _, allowance := range granted {
+		transaction, err := PostGrant(tx, allowance.UserID, allowance.Amount, currency, description, nil)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`
+			UPDATE wallet_allowances SET transaction_id = $3 WHERE user_id = $1 AND month = $2
+		`, allowance.UserID, month.Format("2006-01-02"), transaction.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return granted, tx.Commit()
+}
@@ -0,0 +1,105 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// FakeProviderName — имя локального провайдера для разработки и тестов
+const FakeProviderName = "fake"
+
+// FakeSignatureHeader — заголовок с подписью уведомления локального провайдера
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// Максимальный размер тела уведомления
+const maxCallbackBody = 1 << 16
+
+// FakeProvider — провайдер, который никуда не обращается: платеж подтверждается
+// запросом на адрес подтверждения или уведомлением {reference, status, amount, currency},
+// подписанным HMAC-SHA256 с общим секретом (см. Sign)
+type FakeProvider struct {
+	secret []byte
+}
+
+// fakeCallback — тело уведомления локального провайдера
+type fakeCallback struct {
+	Reference string `json:"reference"`
+	// Status — succeeded или failed
+	Status   string `json:"status"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// NewFakeProvider создает локального провайдера с секретом подписи уведомлений
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{secret: []byte(secret)}
+}
+
+func (p *FakeProvider) Name() string {
+	return FakeProviderName
+}
+
+func (p *FakeProvider) CreatePayment(paymentID int, amount int64, currency, description string) (*Checkout, error) {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	reference := fmt.Sprintf("fake_%d_%s", paymentID, hex.EncodeToString(suffix))
+	log.Printf("Fake payment %s created: %d %s, %s", reference, amount, currency, description)
+
+	return &Checkout{
+		Reference:       reference,
+		ConfirmationURL: "/api/payments/fake/" + reference,
+	}, nil
+}
+
+func (p *FakeProvider) ParseCallback(r *http.Request) (*Callback, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackBody))
+	if err != nil {
+		return nil, ErrInvalidCallback
+	}
+	signature, err := hex.DecodeString(r.Header.Get(FakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, p.sign(body)) {
+		return nil, ErrInvalidCallback
+	}
+
+	var callback fakeCallback
+	if err := json.Unmarshal(body, &callback); err != nil || callback.Reference == "" {
+		return nil, ErrInvalidCallback
+	}
+	if callback.Status != "succeeded" && callback.Status != "failed" {
+		return nil, ErrInvalidCallback
+	}
+	return &Callback{
+		Reference: callback.Reference,
+		Succeeded: callback.Status == "succeeded",
+		Amount:    callback.Amount,
+		Currency:  callback.Currency,
+	}, nil
+}
+
+func (p *FakeProvider) Refund(refundID int, reference string, amount int64, currency string) error {
+	if amount <= 0 {
+		return fmt.Errorf("refund amount must be positive, got %d", amount)
+	}
+	log.Printf("Fake payment %s refunded: %d %s (refund %d)", reference, amount, currency, refundID)
+	return nil
+}
+
+// Sign возвращает подпись тела уведомления для заголовка FakeSignatureHeader
+func (p *FakeProvider) Sign(body []byte) string {
+	return hex.EncodeToString(p.sign(body))
+}
+
+func (p *FakeProvider) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,118 @@
+package payments
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFakeParseCallback(t *testing.T) {
+	provider := NewFakeProvider("test-secret")
+	body := `{"reference":"fake_1_abc","status":"succeeded","amount":10000,"currency":"RUB"}`
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		want      *Callback
+	}{
+		{
+			name:      "valid signature",
+			body:      body,
+			signature: provider.Sign([]byte(body)),
+			want:      &Callback{Reference: "fake_1_abc", Succeeded: true, Amount: 10000, Currency: "RUB"},
+		},
+		{
+			name:      "failed payment",
+			body:      `{"reference":"fake_1_abc","status":"failed","amount":10000,"currency":"RUB"}`,
+			signature: provider.Sign([]byte(`{"reference":"fake_1_abc","status":"failed","amount":10000,"currency":"RUB"}`)),
+			want:      &Callback{Reference: "fake_1_abc", Succeeded: false, Amount: 10000, Currency: "RUB"},
+		},
+		{name: "missing signature", body: body},
+		{name: "signature is not hex", body: body, signature: "not-hex"},
+		{name: "signed with another secret", body: body, signature: NewFakeProvider("other-secret").Sign([]byte(body))},
+		{
+			name:      "amount changed after signing",
+			body:      strings.Replace(body, "10000", "1", 1),
+			signature: provider.Sign([]byte(body)),
+		},
+		{
+			name:      "unknown status",
+			body:      `{"reference":"fake_1_abc","status":"refunded","amount":10000,"currency":"RUB"}`,
+			signature: provider.Sign([]byte(`{"reference":"fake_1_abc","status":"refunded","amount":10000,"currency":"RUB"}`)),
+		},
+		{
+			name:      "no reference",
+			body:      `{"status":"succeeded","amount":10000,"currency":"RUB"}`,
+			signature: provider.Sign([]byte(`{"status":"succeeded","amount":10000,"currency":"RUB"}`)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/payments/fake/callback", strings.NewReader(tt.body))
+			if tt.signature != "" {
+				r.Header.Set(FakeSignatureHeader, tt.signature)
+			}
+			callback, err := provider.ParseCallback(r)
+			if tt.want == nil {
+				if !errors.Is(err, ErrInvalidCallback) {
+					t.Fatalf("got %+v, %v, want ErrInvalidCallback", callback, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCallback: %v", err)
+			}
+			if *callback != *tt.want {
+				t.Fatalf("got %+v, want %+v", callback, tt.want)
+			}
+		})
+	}
+}
+
+func TestInit(t *testing.T) {
+	defer func() { current = nil }()
+
+	tests := []struct {
+		name     string
+		provider string
+		secret   string
+		wantErr  error
+	}{
+		{name: "no provider", provider: "", secret: ""},
+		{name: "fake provider", provider: FakeProviderName, secret: "test-secret"},
+		{name: "secret is not set", provider: FakeProviderName, secret: "", wantErr: ErrWeakSecret},
+		{name: "placeholder secret", provider: FakeProviderName, secret: "your-payment-secret", wantErr: ErrWeakSecret},
+		{name: "unknown provider", provider: "acme", secret: "test-secret", wantErr: ErrUnknownProvider},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Init(tt.provider, tt.secret); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Init error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := Init("", ""); err != nil || Current() != nil {
+		t.Fatalf("Init without a provider left %v, %v", Current(), err)
+	}
+}
+
+func TestSendRefund(t *testing.T) {
+	defer func() { current = nil }()
+
+	if err := Init(FakeProviderName, "test-secret"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := SendRefund(FakeProviderName, 1, "fake_1_abc", 500, "RUB"); err != nil {
+		t.Fatalf("SendRefund: %v", err)
+	}
+	if err := SendRefund("acme", 1, "acme_1", 500, "RUB"); !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("refund of another provider's payment: %v, want ErrUnknownProvider", err)
+	}
+	if err := SendRefund(FakeProviderName, 1, "fake_1_abc", 0, "RUB"); err == nil {
+		t.Fatal("expected an error for a zero refund")
+	}
+}
@@ -0,0 +1,81 @@
+package payments
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrInvalidCallback возвращается для уведомления с неверной подписью или неразборчивым телом
+	ErrInvalidCallback = errors.New("invalid payment callback")
+	// ErrUnknownProvider возвращается для провайдера, которого нет среди реализаций
+	ErrUnknownProvider = errors.New("unknown payment provider")
+	// ErrWeakSecret возвращается, если секрет подписи уведомлений не задан или остался примером из документации
+	ErrWeakSecret = errors.New("payment callback secret is not set")
+)
+
+// Секрет из прежних настроек по умолчанию: с ним уведомления может подделать кто угодно
+const placeholderSecret = "your-payment-secret"
+
+// Checkout — платеж, зарегистрированный у провайдера
+type Checkout struct {
+	// Reference — идентификатор платежа у провайдера
+	Reference string
+	// ConfirmationURL — страница, на которой пользователь подтверждает оплату
+	ConfirmationURL string
+}
+
+// Callback — уведомление провайдера о результате платежа
+type Callback struct {
+	Reference string
+	Succeeded bool
+	Amount    int64
+	Currency  string
+}
+
+// Provider — платежный провайдер; суммы передаются в минимальных единицах валюты
+type Provider interface {
+	// Name — имя провайдера в адресе уведомлений и в записях платежей
+	Name() string
+	// CreatePayment регистрирует платеж; paymentID служит ключом идемпотентности
+	CreatePayment(paymentID int, amount int64, currency, description string) (*Checkout, error)
+	// ParseCallback проверяет подлинность уведомления и разбирает его
+	ParseCallback(r *http.Request) (*Callback, error)
+	// Refund возвращает пользователю amount по платежу reference; refundID служит ключом идемпотентности
+	Refund(refundID int, reference string, amount int64, currency string) error
+}
+
+var current Provider
+
+// Init выбирает провайдера по имени из настроек; secret подписывает уведомления провайдера.
+// Пустое имя оставляет сервер без провайдера: платные брони оплачиваются только с баланса
+func Init(name, secret string) error {
+	if name == "" {
+		current = nil
+		return nil
+	}
+	if secret == "" || secret == placeholderSecret {
+		return ErrWeakSecret
+	}
+	switch name {
+	case FakeProviderName:
+		current = NewFakeProvider(secret)
+	default:
+		return ErrUnknownProvider
+	}
+	return nil
+}
+
+// Current возвращает провайдера, выбранного в Init, или nil, если провайдер не настроен
+func Current() Provider {
+	return current
+}
+
+// SendRefund отправляет возврат по платежу провайдера name через текущего провайдера.
+// Возврат по платежу провайдера, который сейчас не настроен, отклоняется с ErrUnknownProvider
+func SendRefund(name string, refundID int, reference string, amount int64, currency string) error {
+	if current == nil || current.Name() != name {
+		return ErrUnknownProvider
+	}
+	return current.Refund(refundID, reference, amount, currency)
+}
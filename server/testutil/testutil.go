@@ -0,0 +1,101 @@
+// Package testutil готовит базу PostgreSQL для тестов, которым нужна настоящая база
+package testutil
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/lib/pq"
+)
+
+// Переменная окружения с адресом тестовой базы; без нее тесты с базой пропускаются
+const databaseURLEnv = "TEST_DATABASE_URL"
+
+var (
+	migrateOnce sync.Once
+	migrateErr  error
+)
+
+// migrationsDir возвращает путь к каталогу миграций сервера
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "migrations")
+}
+
+func migrateUp(url string) error {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return err
+	}
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		db.Close()
+		return err
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://"+migrationsDir(), "postgres", driver)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	defer m.Close()
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+	return nil
+}
+
+// OpenDB подключается к базе из TEST_DATABASE_URL, применяет миграции и удаляет данные
+// предыдущих тестов. Справочники из миграций (места, парковка, тариф Standard) остаются
+func OpenDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv(databaseURLEnv)
+	if url == "" {
+		t.Skipf("%s is not set, skipping database test", databaseURLEnv)
+	}
+
+	migrateOnce.Do(func() { migrateErr = migrateUp(url) })
+	if migrateErr != nil {
+		t.Fatalf("migrate test database: %v", migrateErr)
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`
+		TRUNCATE users, promo_codes RESTART IDENTITY CASCADE;
+		UPDATE blocked_spots SET is_blocked = FALSE;
+		UPDATE lots SET timezone = NULL, opens_at = NULL, closes_at = NULL;
+		UPDATE spots SET features = '{}', retired_at = NULL;
+		DELETE FROM tariffs WHERE name <> 'Standard';
+		UPDATE tariffs SET hourly_rate = 10000, daily_cap = NULL, free_minutes = 0, active = TRUE, lot_id = NULL, spot_feature = NULL;
+		DELETE FROM tariff_rates;
+		DELETE FROM user_groups;
+	`)
+	if err != nil {
+		t.Fatalf("reset test database: %v", err)
+	}
+	return db
+}
+
+// CreateUser добавляет обычного пользователя и возвращает его ID
+func CreateUser(t *testing.T, db *sql.DB, email string) int {
+	t.Helper()
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO users (email, password_hash, account_type) VALUES ($1, '', 'user') RETURNING id
+	`, email).Scan(&id)
+	if err != nil {
+		t.Fatalf("create user %s: %v", email, err)
+	}
+	return id
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// DefaultPlateCountry — страна регистрации, если она не указана
+const DefaultPlateCountry = "GE"
+
+var ErrInvalidPlate = errors.New("invalid licence plate")
+
+var (
+	countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+	// Грузинские номера: AA-123-AA (с 2014 года) и AAA-123 (старый формат)
+	georgianPlatePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^[A-Z]{2}[0-9]{3}[A-Z]{2}$`),
+		regexp.MustCompile(`^[A-Z]{3}[0-9]{3}$`),
+	}
+	// Общий европейский формат: от 2 до 8 латинских букв и цифр, хотя бы одна цифра
+	genericPlatePattern = regexp.MustCompile(`^[A-Z0-9]{2,8}$`)
+	digitPattern        = regexp.MustCompile(`[0-9]`)
+)
+
+// CleanPlate приводит номер к единому виду: заглавные буквы без пробелов, дефисов и точек
+func CleanPlate(plate string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '\t':
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(plate)))
+}
+
+// NormalizePlate нормализует номер и проверяет его формат для страны регистрации.
+// Возвращает номер и код страны в нормализованном виде
+func NormalizePlate(plate, country string) (string, string, error) {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if country == "" {
+		country = DefaultPlateCountry
+	}
+	if !countryCodePattern.MatchString(country) {
+		return "", "", ErrInvalidPlate
+	}
+
+	plate = CleanPlate(plate)
+	if country == "GE" {
+		for _, pattern := range georgianPlatePatterns {
+			if pattern.MatchString(plate) {
+				return plate, country, nil
+			}
+		}
+		return "", "", ErrInvalidPlate
+	}
+	if !genericPlatePattern.MatchString(plate) || !digitPattern.MatchString(plate) {
+		return "", "", ErrInvalidPlate
+	}
+	return plate, country, nil
+}
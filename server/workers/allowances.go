@@ -0,0 +1,44 @@
+package workers
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"server/config"
+	"server/models"
+)
+
+// StartAllowanceWorker запускает фоновое зачисление ежемесячных сумм групп: сразу при старте
+// и затем каждые interval. Повторный запуск в том же месяце ничего не зачисляет.
+// Нулевой interval отключает зачисление
+func StartAllowanceWorker(db *sql.DB, interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Allowance worker is disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		grantAllowances(db)
+		for range ticker.C {
+			grantAllowances(db)
+		}
+	}()
+}
+
+func grantAllowances(db *sql.DB) {
+	now := time.Now().In(config.Booking.Location)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, config.Booking.Location)
+
+	granted, err := models.GrantAllowances(db, month, config.Booking.Currency)
+	if err != nil {
+		log.Printf("Allowance grant error: %v", err)
+		return
+	}
+	if len(granted) > 0 {
+		log.Printf("Granted monthly allowance for %s to %d users", month.Format("2006-01"), len(granted))
+	}
+}
@@ -0,0 +1,45 @@
+package workers
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"server/models"
+)
+
+// StartNoShowWorker запускает фоновую проверку, которая каждые interval
+// отмечает неявкой брони без регистрации спустя grace после начала и освобождает места,
+// сразу предлагая их листу ожидания. Нулевой interval отключает проверку
+func StartNoShowWorker(db *sql.DB, interval, grace, offerTTL time.Duration) {
+	if interval <= 0 {
+		log.Println("No-show worker is disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			releaseNoShows(db, grace, offerTTL)
+		}
+	}()
+}
+
+func releaseNoShows(db *sql.DB, grace, offerTTL time.Duration) {
+	// При ошибке уже отмеченные брони все равно освобождаются
+	released, err := models.MarkNoShows(db, grace)
+	if err != nil {
+		log.Printf("No-show check error: %v", err)
+	}
+
+	spots := []int{}
+	for _, booking := range released {
+		log.Printf("Booking %d marked as no-show, spot %d released", booking.ID, booking.ParkingSpot)
+		spots = append(spots, booking.ParkingSpot)
+	}
+	if len(spots) > 0 {
+		offerSpots(db, spots, offerTTL)
+	}
+}
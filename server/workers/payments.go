@@ -0,0 +1,42 @@
+package workers
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"server/models"
+)
+
+// StartPaymentWorker запускает фоновую проверку, которая каждые interval отменяет
+// платные брони, не оплаченные вовремя, и предлагает их места листу ожидания.
+// Нулевой interval отключает проверку
+func StartPaymentWorker(db *sql.DB, interval, offerTTL time.Duration) {
+	if interval <= 0 {
+		log.Println("Payment worker is disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			expirePayments(db, offerTTL)
+		}
+	}()
+}
+
+func expirePayments(db *sql.DB, offerTTL time.Duration) {
+	released, err := models.ExpirePayments(db)
+	if err != nil {
+		log.Printf("Payment expiry error: %v", err)
+		return
+	}
+	for _, spot := range released {
+		log.Printf("Unpaid booking cancelled, spot %d released", spot)
+	}
+	if len(released) > 0 {
+		offerSpots(db, released, offerTTL)
+	}
+}
@@ -0,0 +1,78 @@
+package workers
+
+import (
+	"testing"
+	"time"
+
+	"server/models"
+	"server/testutil"
+)
+
+func TestExpirePayments(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userID := testutil.CreateUser(t, db, "payer@example.com")
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+
+	// createUnpaid создает бронь места spot, ожидающую оплаты, и ее платеж со сроком expiresAt
+	createUnpaid := func(spot int, expiresAt time.Time) (int, int) {
+		price, currency := int64(10000), "RUB"
+		bookingID, err := models.CreateBooking(db, &models.Booking{
+			UserID: userID, ParkingSpot: spot, CarNumber: "AA123BB",
+			ReservedAt: start, PlannedEndsAt: start.Add(time.Hour),
+			Status: models.StatusPending, Price: &price, Currency: &currency,
+		})
+		if err != nil {
+			t.Fatalf("create booking: %v", err)
+		}
+		paymentID, err := models.CreatePayment(db, &models.Payment{
+			UserID: userID, Provider: "fake", Amount: price, Currency: currency, ExpiresAt: expiresAt,
+		}, []int{bookingID})
+		if err != nil {
+			t.Fatalf("create payment: %v", err)
+		}
+		return bookingID, paymentID
+	}
+
+	expiredBooking, expiredPayment := createUnpaid(1, time.Now().Add(-time.Minute))
+	waitingBooking, waitingPayment := createUnpaid(2, time.Now().Add(time.Hour))
+
+	expirePayments(db, time.Minute)
+
+	tests := []struct {
+		name        string
+		bookingID   int
+		paymentID   int
+		wantBooking models.BookingStatus
+		wantPayment models.PaymentStatus
+	}{
+		{"overdue payment expires and its booking is cancelled", expiredBooking, expiredPayment, models.StatusCancelled, models.PaymentExpired},
+		{"payment within its timeout is kept", waitingBooking, waitingPayment, models.StatusPending, models.PaymentPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			booking, err := models.GetBooking(db, tt.bookingID)
+			if err != nil {
+				t.Fatalf("get booking: %v", err)
+			}
+			if booking.Status != tt.wantBooking {
+				t.Errorf("booking is %s, want %s", booking.Status, tt.wantBooking)
+			}
+			var status models.PaymentStatus
+			if err := db.QueryRow(`SELECT status FROM payments WHERE id = $1`, tt.paymentID).Scan(&status); err != nil {
+				t.Fatalf("get payment: %v", err)
+			}
+			if status != tt.wantPayment {
+				t.Errorf("payment is %s, want %s", status, tt.wantPayment)
+			}
+		})
+	}
+
+	// Повторный проход не трогает уже истекшие платежи
+	released, err := models.ExpirePayments(db)
+	if err != nil {
+		t.Fatalf("expire payments: %v", err)
+	}
+	if len(released) != 0 {
+		t.Fatalf("second pass released spots %v", released)
+	}
+}
@@ -0,0 +1,41 @@
+package workers
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"server/models"
+	"server/payments"
+)
+
+// StartRefundWorker запускает фоновую отправку возвратов провайдеру: сразу при старте
+// и затем каждые interval. Так уходят возвраты, которые провайдер не принял с первой попытки
+// или которые не успели отправить до перезапуска. Нулевой interval отключает отправку
+func StartRefundWorker(db *sql.DB, interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Refund worker is disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		sendRefunds(db)
+		for range ticker.C {
+			sendRefunds(db)
+		}
+	}()
+}
+
+func sendRefunds(db *sql.DB) {
+	sent, failed, err := models.ProcessProviderRefunds(db, payments.SendRefund)
+	if err != nil {
+		log.Printf("Provider refund error: %v", err)
+		return
+	}
+	if sent > 0 || failed > 0 {
+		log.Printf("Provider refunds: %d sent, %d failed", sent, failed)
+	}
+}
@@ -0,0 +1,54 @@
+package workers
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"server/config"
+	"server/models"
+)
+
+// StartWaitlistWorker запускает фоновую обработку листа ожидания: каждые interval
+// снимает просроченные предложения и предлагает свободные места следующим в очереди.
+// Нулевой interval отключает обработку
+func StartWaitlistWorker(db *sql.DB, interval, offerTTL time.Duration) {
+	if interval <= 0 {
+		log.Println("Waitlist worker is disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			processWaitlist(db, offerTTL)
+		}
+	}()
+}
+
+func processWaitlist(db *sql.DB, offerTTL time.Duration) {
+	released, err := models.ExpireWaitlist(db)
+	if err != nil {
+		log.Printf("Waitlist expiry error: %v", err)
+		return
+	}
+	for _, spot := range released {
+		log.Printf("Waitlist offer expired, spot %d passed to the next in line", spot)
+	}
+
+	offerSpots(db, nil, offerTTL)
+}
+
+// offerSpots предлагает места ожидающим пользователям; nil — все места
+func offerSpots(db *sql.DB, spots []int, offerTTL time.Duration) {
+	offered, err := models.FillWaitlist(db, spots, offerTTL, config.Booking.Location)
+	if err != nil {
+		log.Printf("Waitlist offer error: %v", err)
+		return
+	}
+	for _, entry := range offered {
+		log.Printf("Waitlist entry %d offered booking %d", entry.ID, *entry.BookingID)
+	}
+}